@@ -1,31 +1,206 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	VkUserToken      string
 	VkGroupToken     string
 	TgChatToken      string
+	TgAdminID        int64
 	PredictionDBPath string
+	// Чаты, куда публикуются итоги конкурса прогнозов
+	PredictionVkChats []int64
+	PredictionTgChats []int64
+	// Период проверки появления результатов гонки для автоматического подсчёта очков
+	PredictionSettleInterval time.Duration
+	// За сколько до гонки автоматически открывать приём прогнозов
+	PredictionOpenBefore time.Duration
+	// Когда закрывать приём прогнозов: "qualifying" или "race"
+	PredictionCloseAt string
+	// Роли бота из конфигурации (действуют во всех чатах, командами не снимаются)
+	RoleOwners     []PlatformUser
+	RoleAdmins     []PlatformUser
+	RoleModerators []PlatformUser
+	// Чаты, куда отправляются напоминания о сессиях (по умолчанию — чаты конкурса прогнозов)
+	ReminderVkChats []int64
+	ReminderTgChats []int64
+	// За сколько до старта сессии отправлять напоминание
+	ReminderBefore time.Duration
+	// Период проверки появления результатов завершившихся сессий
+	ResultsPollInterval time.Duration
+	// Администратор бота в VK: владелец по умолчанию и получатель ошибок отслеживания трансляций
+	VkAdminID int64
+	// Сообщества VK, трансляции которых отслеживает бот
+	Communities []Community
+	// Вложения VK (фото загружены в сообщество бота)
+	VkMedia VkMedia
 }
 
+// Сообщество VK: группа, трансляции которой отслеживаются, и беседа для уведомлений о них
+type Community struct {
+	Name    string `yaml:"name" toml:"name"`         // название в уведомлениях: "F1 Memes TV"
+	GroupID int64  `yaml:"group_id" toml:"group_id"` // id группы VK (отрицательный)
+	ChatID  int64  `yaml:"chat_id" toml:"chat_id"`   // peer_id беседы для уведомлений
+	AdminID int64  `yaml:"admin_id" toml:"admin_id"` // кому сообщать об ошибках, 0 — администратору бота
+}
+
+// Вложения VK, которые бот прикрепляет к сообщениям
+type VkMedia struct {
+	LiveriesPhoto   string `yaml:"liveries_photo" toml:"liveries_photo"`     // "photo-219009582_457239026"
+	LiveriesCaption string `yaml:"liveries_caption" toml:"liveries_caption"` // подпись к фото ливрей
+	CarouselPhoto   string `yaml:"carousel_photo" toml:"carousel_photo"`     // фото карточек карусели этапов: "-219009582_457239025"
+}
+
+// Пользователь платформы: "vk:147506714", "tg:123456"
+type PlatformUser struct {
+	Platform string
+	UserID   int64
+}
+
+// New читает конфигурацию: файл CONFIG_FILE (YAML или TOML, необязательный), затем переменные
+// окружения — они имеют наивысший приоритет. Администратор, сообщества и вложения VK
+// значений по умолчанию не имеют, их наличие проверяет Validate.
 func New() *Config {
 	dbPath := os.Getenv("PREDICTION_DB_PATH")
 	if dbPath == "" {
 		dbPath = "/data/predictions.db"
 	}
 
+	file := loadFile(os.Getenv("CONFIG_FILE"))
+	predictionVkChats := getEnvInt64List("PREDICTION_VK_CHATS")
+	predictionTgChats := getEnvInt64List("PREDICTION_TG_CHATS")
+	vkAdminID := getEnvInt64Default("VK_ADMIN_ID", file.VkAdminID)
+	defaultOwners := ""
+	if vkAdminID > 0 {
+		defaultOwners = fmt.Sprintf("vk:%d", vkAdminID)
+	}
+
 	return &Config{
 		VkGroupToken:     getEnv("RACEVK_BOT"),
 		VkUserToken:      getEnv("USERTOKEN_VK"),
 		TgChatToken:      getEnv("RACETG_BOT"),
+		TgAdminID:        getEnvInt64("TG_ADMIN_ID"),
 		PredictionDBPath: dbPath,
+
+		PredictionVkChats:        predictionVkChats,
+		PredictionTgChats:        predictionTgChats,
+		PredictionSettleInterval: time.Duration(getEnvInt64Default("PREDICTION_SETTLE_INTERVAL", 10)) * time.Minute,
+		PredictionOpenBefore:     time.Duration(getEnvInt64Default("PREDICTION_OPEN_DAYS", 3)) * 24 * time.Hour,
+		PredictionCloseAt:        getEnvDefault("PREDICTION_CLOSE_AT", "qualifying"),
+
+		ReminderVkChats: getEnvInt64ListDefault("REMINDER_VK_CHATS", predictionVkChats),
+		ReminderTgChats: getEnvInt64ListDefault("REMINDER_TG_CHATS", predictionTgChats),
+		ReminderBefore:  time.Duration(getEnvInt64Default("REMINDER_BEFORE", 60)) * time.Minute,
+
+		ResultsPollInterval: time.Duration(getEnvInt64Default("RESULTS_POLL_INTERVAL", 2)) * time.Minute,
+
+		RoleOwners:     getEnvUserList("ROLE_OWNERS", defaultOwners),
+		RoleAdmins:     getEnvUserList("ROLE_ADMINS", ""),
+		RoleModerators: getEnvUserList("ROLE_MODERATORS", ""),
+
+		VkAdminID:   vkAdminID,
+		Communities: communitiesFromEnv(file.Communities),
+		VkMedia: VkMedia{
+			LiveriesPhoto:   getEnvDefault("VK_LIVERIES_PHOTO", file.VkMedia.LiveriesPhoto),
+			LiveriesCaption: getEnvDefault("VK_LIVERIES_CAPTION", file.VkMedia.LiveriesCaption),
+			CarouselPhoto:   getEnvDefault("VK_CAROUSEL_PHOTO", file.VkMedia.CarouselPhoto),
+		},
+	}
+}
+
+// communitiesFromEnv возвращает сообщества: одно из VK_COMMUNITY_* (если задано),
+// иначе из файла конфигурации
+func communitiesFromEnv(fromFile []Community) []Community {
+	if os.Getenv("VK_COMMUNITY_GROUP_ID") != "" {
+		return []Community{{
+			Name:    os.Getenv("VK_COMMUNITY_NAME"),
+			GroupID: getEnvInt64("VK_COMMUNITY_GROUP_ID"),
+			ChatID:  getEnvInt64("VK_COMMUNITY_CHAT_ID"),
+			AdminID: getEnvInt64("VK_COMMUNITY_ADMIN_ID"),
+		}}
 	}
+	return fromFile
 }
 
+// Validate проверяет конфигурацию при старте и возвращает все найденные ошибки
+func (c *Config) Validate() error {
+	var errs []error
+	if c.PredictionCloseAt != "qualifying" && c.PredictionCloseAt != "race" {
+		errs = append(errs, fmt.Errorf("PREDICTION_CLOSE_AT: %q, expected qualifying or race", c.PredictionCloseAt))
+	}
+	for _, chat := range c.PredictionVkChats {
+		if chat <= 0 {
+			errs = append(errs, fmt.Errorf("PREDICTION_VK_CHATS: invalid peer_id %d", chat))
+		}
+	}
+	if c.ReminderBefore <= 0 {
+		errs = append(errs, fmt.Errorf("REMINDER_BEFORE: must be positive, got %s", c.ReminderBefore))
+	}
+	if c.ResultsPollInterval <= 0 {
+		errs = append(errs, fmt.Errorf("RESULTS_POLL_INTERVAL: must be positive, got %s", c.ResultsPollInterval))
+	}
+	switch {
+	case c.VkAdminID == 0:
+		errs = append(errs, errors.New("VK_ADMIN_ID: required (or vk_admin_id in CONFIG_FILE)"))
+	case c.VkAdminID < 0:
+		errs = append(errs, fmt.Errorf("VK_ADMIN_ID: invalid user id %d", c.VkAdminID))
+	}
+
+	if len(c.Communities) == 0 {
+		errs = append(errs, errors.New("communities: at least one community is required (VK_COMMUNITY_* or communities in CONFIG_FILE)"))
+	}
+	seenChats := make(map[int64]string)
+	for i, cm := range c.Communities {
+		prefix := fmt.Sprintf("communities[%d] %q", i, cm.Name)
+		if strings.TrimSpace(cm.Name) == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", prefix))
+		}
+		if cm.GroupID >= 0 {
+			errs = append(errs, fmt.Errorf("%s: group_id must be negative (VK group owner id), got %d", prefix, cm.GroupID))
+		}
+		if cm.ChatID < chatPeerIDOffset {
+			errs = append(errs, fmt.Errorf("%s: chat_id must be a chat peer_id (>= %d), got %d", prefix, chatPeerIDOffset, cm.ChatID))
+		}
+		if cm.AdminID < 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid admin_id %d", prefix, cm.AdminID))
+		}
+		if other, ok := seenChats[cm.ChatID]; ok {
+			errs = append(errs, fmt.Errorf("%s: chat_id %d is already used by %q", prefix, cm.ChatID, other))
+		}
+		seenChats[cm.ChatID] = cm.Name
+	}
+
+	switch {
+	case c.VkMedia.LiveriesPhoto == "":
+		errs = append(errs, errors.New("VK_LIVERIES_PHOTO: required (or vk_media.liveries_photo in CONFIG_FILE)"))
+	case !photoAttachmentRe.MatchString(c.VkMedia.LiveriesPhoto):
+		errs = append(errs, fmt.Errorf("VK_LIVERIES_PHOTO: %q, expected photo<owner_id>_<photo_id>", c.VkMedia.LiveriesPhoto))
+	}
+	switch {
+	case c.VkMedia.CarouselPhoto == "":
+		errs = append(errs, errors.New("VK_CAROUSEL_PHOTO: required (or vk_media.carousel_photo in CONFIG_FILE)"))
+	case !photoIDRe.MatchString(c.VkMedia.CarouselPhoto):
+		errs = append(errs, fmt.Errorf("VK_CAROUSEL_PHOTO: %q, expected <owner_id>_<photo_id>", c.VkMedia.CarouselPhoto))
+	}
+	return errors.Join(errs...)
+}
+
+// peer_id бесед VK начинается с 2000000000
+const chatPeerIDOffset = 2000000000
+
+var (
+	photoAttachmentRe = regexp.MustCompile(`\Aphoto-?\d+_\d+\z`)
+	photoIDRe         = regexp.MustCompile(`\A-?\d+_\d+\z`)
+)
+
 func getEnv(key string) string {
 	value, ok := os.LookupEnv(key)
 	if !ok {
@@ -34,3 +209,85 @@ func getEnv(key string) string {
 		return value
 	}
 }
+
+// getEnvDefault читает необязательную переменную окружения со значением по умолчанию
+func getEnvDefault(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// getEnvInt64 читает необязательную числовую переменную окружения (0, если не задана)
+func getEnvInt64(key string) int64 {
+	return getEnvInt64Default(key, 0)
+}
+
+// getEnvInt64Default читает необязательную числовую переменную окружения со значением по умолчанию
+func getEnvInt64Default(key string, def int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	num, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("error parsing environment %s: %s", key, err))
+	}
+	return num
+}
+
+// getEnvInt64List читает необязательный список чисел через запятую
+func getEnvInt64List(key string) []int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	var nums []int64
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		num, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			panic(fmt.Sprintf("error parsing environment %s: %s", key, err))
+		}
+		nums = append(nums, num)
+	}
+	return nums
+}
+
+// getEnvInt64ListDefault читает необязательный список чисел через запятую со значением по умолчанию
+func getEnvInt64ListDefault(key string, def []int64) []int64 {
+	if os.Getenv(key) == "" {
+		return def
+	}
+	return getEnvInt64List(key)
+}
+
+// getEnvUserList читает список пользователей платформ через запятую: "vk:1,tg:2"
+func getEnvUserList(key, def string) []PlatformUser {
+	value := getEnvDefault(key, def)
+	if value == "" {
+		return nil
+	}
+
+	var users []PlatformUser
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		platform, id, ok := strings.Cut(part, ":")
+		if !ok || (platform != "vk" && platform != "tg") {
+			panic(fmt.Sprintf("error parsing environment %s: invalid user %q (expected vk:ID or tg:ID)", key, part))
+		}
+		userID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			panic(fmt.Sprintf("error parsing environment %s: %s", key, err))
+		}
+		users = append(users, PlatformUser{Platform: platform, UserID: userID})
+	}
+	return users
+}
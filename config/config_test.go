@@ -0,0 +1,169 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setTestEnv задаёт обязательные токены и очищает переменные, которые переопределяют файл
+func setTestEnv(t *testing.T, configFile string) {
+	t.Helper()
+	for _, key := range []string{"RACEVK_BOT", "USERTOKEN_VK", "RACETG_BOT"} {
+		t.Setenv(key, "token")
+	}
+	for _, key := range []string{
+		"VK_ADMIN_ID", "ROLE_OWNERS", "VK_COMMUNITY_NAME", "VK_COMMUNITY_GROUP_ID", "VK_COMMUNITY_CHAT_ID",
+		"VK_COMMUNITY_ADMIN_ID", "VK_LIVERIES_PHOTO", "VK_LIVERIES_CAPTION", "VK_CAROUSEL_PHOTO",
+		"PREDICTION_VK_CHATS", "PREDICTION_TG_CHATS", "PREDICTION_CLOSE_AT", "REMINDER_BEFORE", "RESULTS_POLL_INTERVAL",
+	} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("CONFIG_FILE", configFile)
+}
+
+// writeConfigFile записывает файл конфигурации во временный каталог теста
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestNewFromExampleFile(t *testing.T) {
+	setTestEnv(t, filepath.Join("..", "config.example.yaml"))
+
+	conf := New()
+	if err := conf.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if conf.VkAdminID != 147506714 {
+		t.Errorf("VkAdminID = %d, want 147506714", conf.VkAdminID)
+	}
+	if len(conf.RoleOwners) != 1 || conf.RoleOwners[0] != (PlatformUser{Platform: "vk", UserID: 147506714}) {
+		t.Errorf("RoleOwners = %v, want [vk:147506714]", conf.RoleOwners)
+	}
+	if len(conf.Communities) != 1 || conf.Communities[0].GroupID != -211183989 || conf.Communities[0].ChatID != 2000000003 {
+		t.Errorf("Communities = %+v", conf.Communities)
+	}
+	if conf.VkMedia.LiveriesPhoto != "photo-219009582_457239026" || conf.VkMedia.CarouselPhoto != "-219009582_457239025" {
+		t.Errorf("VkMedia = %+v", conf.VkMedia)
+	}
+	if conf.ReminderBefore != time.Hour || conf.PredictionCloseAt != "qualifying" {
+		t.Errorf("defaults: ReminderBefore = %s, PredictionCloseAt = %q", conf.ReminderBefore, conf.PredictionCloseAt)
+	}
+}
+
+func TestNewEnvOverridesFile(t *testing.T) {
+	setTestEnv(t, writeConfigFile(t, "config.toml", `
+vk_admin_id = 1
+
+[[communities]]
+name = "Из файла"
+group_id = -10
+chat_id = 2000000010
+
+[vk_media]
+liveries_photo = "photo-1_1"
+carousel_photo = "-1_2"
+`))
+	t.Setenv("VK_ADMIN_ID", "2")
+	t.Setenv("VK_COMMUNITY_NAME", "Тестовое")
+	t.Setenv("VK_COMMUNITY_GROUP_ID", "-20")
+	t.Setenv("VK_COMMUNITY_CHAT_ID", "2000000020")
+	t.Setenv("VK_CAROUSEL_PHOTO", "-2_3")
+
+	conf := New()
+	if err := conf.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if conf.VkAdminID != 2 {
+		t.Errorf("VkAdminID = %d, want 2 from environment", conf.VkAdminID)
+	}
+	want := Community{Name: "Тестовое", GroupID: -20, ChatID: 2000000020}
+	if len(conf.Communities) != 1 || conf.Communities[0] != want {
+		t.Errorf("Communities = %+v, want [%+v]", conf.Communities, want)
+	}
+	if conf.VkMedia.LiveriesPhoto != "photo-1_1" || conf.VkMedia.CarouselPhoto != "-2_3" {
+		t.Errorf("VkMedia = %+v", conf.VkMedia)
+	}
+}
+
+// Без файла и переменных окружения бот не получает чужих идентификаторов по умолчанию
+func TestNewWithoutCommunityConfig(t *testing.T) {
+	setTestEnv(t, "")
+
+	conf := New()
+	if conf.VkAdminID != 0 || len(conf.Communities) != 0 || len(conf.RoleOwners) != 0 || conf.VkMedia != (VkMedia{}) {
+		t.Fatalf("unexpected defaults: admin %d, communities %v, owners %v, media %+v", conf.VkAdminID, conf.Communities, conf.RoleOwners, conf.VkMedia)
+	}
+	err := conf.Validate()
+	if err == nil {
+		t.Fatal("Validate: want error for missing VK settings")
+	}
+	for _, key := range []string{"VK_ADMIN_ID", "communities", "VK_LIVERIES_PHOTO", "VK_CAROUSEL_PHOTO"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Validate error does not mention %s: %v", key, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() Config {
+		return Config{
+			PredictionCloseAt:   "qualifying",
+			ReminderBefore:      time.Hour,
+			ResultsPollInterval: 2 * time.Minute,
+			VkAdminID:           1,
+			Communities:         []Community{{Name: "F1", GroupID: -1, ChatID: 2000000001}},
+			VkMedia:             VkMedia{LiveriesPhoto: "photo-1_2", CarouselPhoto: "-1_3"},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string // подстрока ошибки, "" — конфигурация верна
+	}{
+		{"верная", func(c *Config) {}, ""},
+		{"закрытие перед гонкой", func(c *Config) { c.PredictionCloseAt = "race" }, ""},
+		{"неизвестное закрытие", func(c *Config) { c.PredictionCloseAt = "sprint" }, "PREDICTION_CLOSE_AT"},
+		{"чат VK не peer_id", func(c *Config) { c.PredictionVkChats = []int64{-5} }, "PREDICTION_VK_CHATS"},
+		{"нулевое напоминание", func(c *Config) { c.ReminderBefore = 0 }, "REMINDER_BEFORE"},
+		{"нулевой опрос", func(c *Config) { c.ResultsPollInterval = 0 }, "RESULTS_POLL_INTERVAL"},
+		{"нет администратора", func(c *Config) { c.VkAdminID = 0 }, "VK_ADMIN_ID: required"},
+		{"отрицательный администратор", func(c *Config) { c.VkAdminID = -1 }, "VK_ADMIN_ID: invalid"},
+		{"нет сообществ", func(c *Config) { c.Communities = nil }, "at least one community"},
+		{"без названия", func(c *Config) { c.Communities[0].Name = " " }, "name is required"},
+		{"положительная группа", func(c *Config) { c.Communities[0].GroupID = 1 }, "group_id must be negative"},
+		{"беседа не чат", func(c *Config) { c.Communities[0].ChatID = 5 }, "chat_id must be a chat peer_id"},
+		{"одна беседа дважды", func(c *Config) {
+			c.Communities = append(c.Communities, Community{Name: "F2", GroupID: -2, ChatID: 2000000001})
+		}, "already used"},
+		{"нет фото ливрей", func(c *Config) { c.VkMedia.LiveriesPhoto = "" }, "VK_LIVERIES_PHOTO: required"},
+		{"фото ливрей без префикса", func(c *Config) { c.VkMedia.LiveriesPhoto = "-1_2" }, "expected photo<owner_id>"},
+		{"нет фото карусели", func(c *Config) { c.VkMedia.CarouselPhoto = "" }, "VK_CAROUSEL_PHOTO: required"},
+		{"фото карусели с префиксом", func(c *Config) { c.VkMedia.CarouselPhoto = "photo-1_3" }, "expected <owner_id>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := valid()
+			tt.modify(&conf)
+			err := conf.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
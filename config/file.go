@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig — настройки, которые можно задать в файле CONFIG_FILE.
+// Токены и остальные параметры задаются только переменными окружения.
+type fileConfig struct {
+	VkAdminID   int64       `yaml:"vk_admin_id" toml:"vk_admin_id"`
+	Communities []Community `yaml:"communities" toml:"communities"`
+	VkMedia     VkMedia     `yaml:"vk_media" toml:"vk_media"`
+}
+
+// loadFile читает файл конфигурации; формат определяется расширением (.yaml, .yml, .toml).
+// Пустой путь — файла нет.
+func loadFile(path string) fileConfig {
+	var file fileConfig
+	if path == "" {
+		return file
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		panic(fmt.Sprintf("error reading config file %s: %s", path, err))
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	case ".toml":
+		err = toml.Unmarshal(data, &file)
+	default:
+		err = fmt.Errorf("unknown format %q, expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		panic(fmt.Sprintf("error parsing config file %s: %s", path, err))
+	}
+	return file
+}
@@ -5,11 +5,15 @@ import (
 	"os"
 	"path/filepath"
 	"racebot-vk/config"
+	"racebot-vk/models"
 	"racebot-vk/service"
 	"racebot-vk/storage/ergast"
 	predStorage "racebot-vk/storage/prediction"
 	tg_api "racebot-vk/telegram"
 	vk_api "racebot-vk/vk"
+	"time"
+	// База часовых поясов внутри бинарника: в минимальном Docker-образе её может не быть
+	_ "time/tzdata"
 
 	"github.com/joho/godotenv"
 )
@@ -42,20 +46,32 @@ func main() {
 
 	conf := config.New()
 	log := setupLogger()
+	if err := conf.Validate(); err != nil {
+		log.Error("invalid configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
 
-	vkAPI, tgAPI := setupConnection(conf, log)
+	vkAPI, tgAPI, jobs := setupConnection(conf, log)
 
 	go vkAPI.Run(log)
+	for _, job := range jobs {
+		go job.Run(log)
+	}
 	tgAPI.Run(log)
 }
 
+// backgroundJob — фоновая периодическая задача (блокирующий Run)
+type backgroundJob interface {
+	Run(log *slog.Logger)
+}
+
 func setupLogger() *slog.Logger {
 	return slog.New(slog.NewJSONHandler(os.Stdout, nil))
 }
 
-func setupConnection(conf *config.Config, log *slog.Logger) (*vk_api.VkAPI, *tg_api.TgAPI) {
+func setupConnection(conf *config.Config, log *slog.Logger) (*vk_api.VkAPI, *tg_api.TgAPI, []backgroundJob) {
 	ergastAPI := ergast.NewErgastAPI()
-	f1Service := service.NewServiceF1(ergastAPI)
+	f1Service := service.NewServiceF1(ergastAPI, conf.VkMedia.CarouselPhoto)
 
 	// Инициализация хранилища прогнозов
 	predStore, err := predStorage.NewStorage(conf.PredictionDBPath)
@@ -63,19 +79,68 @@ func setupConnection(conf *config.Config, log *slog.Logger) (*vk_api.VkAPI, *tg_
 		log.Error("failed to init prediction storage", slog.Any("error", err))
 		os.Exit(1)
 	}
-	predService := service.NewPredictionService(predStore)
+	predService := service.NewPredictionService(predStore, service.NewDriverResolver(ergastAPI), conf.PredictionCloseAt)
 
-	vkAPI, err := vk_api.NewVKAPI(conf.VkGroupToken, conf.VkUserToken, f1Service, f1Service, predService)
+	// Роли бота: из конфигурации и выданные командами
+	roleService := service.NewRoleService(predStore, staticRoles(conf))
+
+	// Часовые пояса пользователей и чатов для расписания
+	timezones := service.NewTimezoneService(predStore, roleService)
+
+	// Подписки чатов на рассылки; чаты из конфигурации подписаны по умолчанию
+	subscriptions := service.NewSubscriptionService(predStore, roleService,
+		map[string][]int64{models.PlatformVK: conf.ReminderVkChats, models.PlatformTelegram: conf.ReminderTgChats},
+		map[string][]int64{models.PlatformVK: conf.PredictionVkChats, models.PlatformTelegram: conf.PredictionTgChats})
+
+	vkAPI, err := vk_api.NewVKAPI(conf.VkGroupToken, conf.VkUserToken, int(conf.VkAdminID), conf.Communities, conf.VkMedia, f1Service, f1Service, predService, roleService, subscriptions, timezones)
 	if err != nil {
 		log.Error("Error vkApi object")
 		os.Exit(1)
 	}
 
-	tgAPI, err := tg_api.NewTGAPI(conf.TgChatToken, f1Service)
+	tgAPI, err := tg_api.NewTGAPI(conf.TgChatToken, f1Service, predService, roleService, subscriptions, timezones)
 	if err != nil {
 		log.Error("Error tgApi object")
 		os.Exit(1)
 	}
 
-	return vkAPI, tgAPI
+	// Имена участников для рейтингов и итогов
+	predService.SetProfileSource(models.PlatformVK, vkAPI)
+	predService.SetProfileSource(models.PlatformTelegram, tgAPI)
+	profileRefresher := service.NewUserProfileRefresher(predService, 24*time.Hour)
+
+	// Администраторы бесед и групп — администраторы бота в своём чате
+	roleService.SetChatAdminSource(models.PlatformVK, vkAPI)
+	roleService.SetChatAdminSource(models.PlatformTelegram, tgAPI)
+
+	// Автоматический подсчёт очков после публикации результатов гонки
+	settler := service.NewPredictionSettler(predService, ergastAPI, subscriptions, conf.PredictionSettleInterval, vkAPI, tgAPI)
+
+	// Автоматическое открытие и закрытие раундов по календарю
+	scheduler := service.NewPredictionScheduler(predService, ergastAPI, f1Service, subscriptions, timezones, conf.PredictionOpenBefore, time.Minute, vkAPI, tgAPI)
+
+	// Напоминания о сессиях гоночного уикенда подписанным чатам
+	reminders := service.NewReminderScheduler(ergastAPI, predStore, subscriptions, timezones, conf.ReminderBefore, time.Minute, vkAPI, tgAPI)
+
+	// Результаты сессий подписанным чатам сразу после публикации в Ergast
+	resultsPoller := service.NewResultsPoller(ergastAPI, ergastAPI, predStore, subscriptions, conf.ResultsPollInterval, vkAPI, tgAPI)
+
+	return vkAPI, tgAPI, []backgroundJob{settler, scheduler, profileRefresher, reminders, resultsPoller}
+}
+
+// staticRoles собирает роли из конфигурации. TG_ADMIN_ID — владелец бота в Telegram.
+func staticRoles(conf *config.Config) []models.UserRole {
+	var roles []models.UserRole
+	add := func(users []config.PlatformUser, role models.Role) {
+		for _, u := range users {
+			roles = append(roles, models.UserRole{Platform: u.Platform, UserID: int(u.UserID), Role: role})
+		}
+	}
+	add(conf.RoleOwners, models.RoleOwner)
+	add(conf.RoleAdmins, models.RoleAdmin)
+	add(conf.RoleModerators, models.RoleModerator)
+	if conf.TgAdminID != 0 {
+		add([]config.PlatformUser{{Platform: models.PlatformTelegram, UserID: conf.TgAdminID}}, models.RoleOwner)
+	}
+	return roles
 }
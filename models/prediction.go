@@ -1,34 +1,149 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
+
+// Платформы, с которых пользователи отправляют прогнозы.
+// ID пользователей VK и Telegram могут совпадать, поэтому пользователь
+// однозначно определяется только парой (платформа, ID).
+const (
+	PlatformVK       = "vk"
+	PlatformTelegram = "tg"
+)
 
 // Прогноз пользователя на гонку
 type Prediction struct {
 	ID        int       `json:"id"`
+	Platform  string    `json:"platform"` // PlatformVK / PlatformTelegram
 	UserID    int       `json:"user_id"`
-	RaceID    string    `json:"race_id"` // "2025_1" (season_round)
+	RaceID    string    `json:"race_id"`  // "2025_1" (season_round)
+	RoundID   int       `json:"round_id"` // раунд прогнозов (PredictionRace.ID)
+	ChatID    int64     `json:"chat_id"`  // чат платформы, где отправлен первый прогноз (0 — неизвестен)
 	Driver1   uint8     `json:"driver_1"`
 	Driver2   uint8     `json:"driver_2"`
 	Driver3   uint8     `json:"driver_3"`
 	Points    int       `json:"points"`
 	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"` // время последнего изменения прогноза
+	// Ответы на дополнительные вопросы раунда
+	Extras PredictionExtras `json:"extras"`
+}
+
+// Дополнительные вопросы раунда прогнозов помимо подиума.
+// Нулевое значение поля означает отсутствие ответа: в прогнозе — вопрос пропущен,
+// в результатах раунда — события не было или данных ещё нет.
+type PredictionExtras struct {
+	Pole         uint8   `json:"pole,omitempty"`          // поул-позиция
+	FastestLap   uint8   `json:"fastest_lap,omitempty"`   // быстрый круг в гонке
+	FirstDNF     uint8   `json:"first_dnf,omitempty"`     // первый сошедший гонщик
+	Constructor  string  `json:"constructor,omitempty"`   // команда победителя (constructorId Ergast)
+	SprintPodium []uint8 `json:"sprint_podium,omitempty"` // подиум спринта (только для спринт-уикендов)
+}
+
+// IsEmpty возвращает true, если ни на один дополнительный вопрос нет ответа
+func (e PredictionExtras) IsEmpty() bool {
+	return e.Pole == 0 && e.FastestLap == 0 && e.FirstDNF == 0 && e.Constructor == "" && len(e.SprintPodium) == 0
+}
+
+// Merge возвращает ответы e, дополненные непустыми ответами other
+func (e PredictionExtras) Merge(other PredictionExtras) PredictionExtras {
+	if other.Pole != 0 {
+		e.Pole = other.Pole
+	}
+	if other.FastestLap != 0 {
+		e.FastestLap = other.FastestLap
+	}
+	if other.FirstDNF != 0 {
+		e.FirstDNF = other.FirstDNF
+	}
+	if other.Constructor != "" {
+		e.Constructor = other.Constructor
+	}
+	if len(other.SprintPodium) > 0 {
+		e.SprintPodium = other.SprintPodium
+	}
+	return e
+}
+
+// Действия в истории прогнозов
+const (
+	PredictionActionSubmit = "submit" // первый прогноз на гонку
+	PredictionActionEdit   = "edit"   // изменение ранее отправленного прогноза
+	PredictionActionExtras = "extras" // ответы на дополнительные вопросы
+)
+
+// Запись истории прогноза: каждая отправка или правка сохраняется отдельно
+type PredictionHistoryEntry struct {
+	ID        int              `json:"id"`
+	Platform  string           `json:"platform"`
+	UserID    int              `json:"user_id"`
+	RaceID    string           `json:"race_id"`
+	RoundID   int              `json:"round_id"`
+	Driver1   uint8            `json:"driver_1"`
+	Driver2   uint8            `json:"driver_2"`
+	Driver3   uint8            `json:"driver_3"`
+	Action    string           `json:"action"` // PredictionActionSubmit / PredictionActionEdit / PredictionActionExtras
+	CreatedAt time.Time        `json:"created_at"`
+	Extras    PredictionExtras `json:"extras"`
 }
 
-// Раунд прогнозов на конкретную гонку
+// Раунд прогнозов на конкретную гонку. На одну гонку может быть открыто несколько
+// раундов: общий для всех чатов и раунды отдельных чатов.
 type PredictionRace struct {
 	ID        int        `json:"id"`
 	RaceID    string     `json:"race_id"` // "2025_1" (season_round)
 	RaceName  string     `json:"race_name"`
+	Platform  string     `json:"platform,omitempty"` // платформа чата раунда
+	ChatID    int64      `json:"chat_id,omitempty"`  // чат раунда, 0 — общий раунд для всех чатов
 	IsActive  bool       `json:"is_active"`          // true - приём прогнозов открыт
 	Driver1   *uint8     `json:"driver_1,omitempty"` // реальный результат (номер гонщика)
 	Driver2   *uint8     `json:"driver_2,omitempty"`
 	Driver3   *uint8     `json:"driver_3,omitempty"`
 	CreatedAt time.Time  `json:"created_at"`
 	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+	RaceStart *time.Time `json:"race_start,omitempty"` // время старта гонки (UTC)
+	ClosesAt  *time.Time `json:"closes_at,omitempty"`  // дедлайн приёма прогнозов
+	Scoring   string     `json:"scoring"`              // имя правила подсчёта очков
+	// Старт квалификации и спринта (UTC) — дедлайны вопросов о поуле и подиуме спринта
+	QualifyingStart *time.Time `json:"qualifying_start,omitempty"`
+	SprintStart     *time.Time `json:"sprint_start,omitempty"`
+	// Полный финишный порядок (номера гонщиков), если результаты получены из Ergast
+	Classification []uint8 `json:"classification,omitempty"`
+	IsSprint       bool    `json:"is_sprint"` // спринт-уикенд: доступен прогноз на подиум спринта
+	// Реальные ответы на дополнительные вопросы
+	Extras PredictionExtras `json:"extras"`
+}
+
+// Deadline возвращает момент, после которого прогнозы не принимаются (nil — без ограничения)
+func (r *PredictionRace) Deadline() *time.Time {
+	if r.ClosesAt != nil && (r.RaceStart == nil || r.ClosesAt.Before(*r.RaceStart)) {
+		return r.ClosesAt
+	}
+	return r.RaceStart
+}
+
+// Round возвращает номер этапа сезона из race_id ("2025_6" → "6")
+func (r *PredictionRace) Round() string {
+	if i := strings.LastIndex(r.RaceID, "_"); i >= 0 {
+		return r.RaceID[i+1:]
+	}
+	return r.RaceID
+}
+
+// Область таблицы лидеров. Нулевое значение — рейтинг за всё время по всем чатам.
+type LeaderboardScope struct {
+	Season   int       `json:"season,omitempty"`   // сезон из race_id ("2025_1" → 2025), 0 — все сезоны
+	Platform string    `json:"platform,omitempty"` // платформа чата (учитывается вместе с ChatID)
+	ChatID   int64     `json:"chat_id,omitempty"`  // чат, где сделан прогноз, 0 — все чаты
+	From     time.Time `json:"from,omitempty"`     // начало периода по старту гонки (включительно)
+	To       time.Time `json:"to,omitempty"`       // конец периода по старту гонки (не включительно)
 }
 
 // Статичтика пользователя по прогнозам
 type UserStats struct {
+	Platform    string  `json:"platform"`
 	UserID      int     `json:"user_id"`
 	TotalPoints int     `json:"total_points"`
 	TotalRaces  int     `json:"total_races"`
@@ -39,6 +154,7 @@ type UserStats struct {
 
 // Результат одного прогноза после подсчёта
 type PredictionResult struct {
+	Platform   string     `json:"platform"`
 	UserID     int        `json:"user_id"`
 	Prediction Prediction `json:"prediction"`
 	Points     int        `json:"points"`
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Роль пользователя бота. Старшая роль включает права младших.
+type Role string
+
+const (
+	RoleOwner     Role = "owner"     // владелец: все команды, назначение администраторов
+	RoleAdmin     Role = "admin"     // администратор: управление конкурсами прогнозов
+	RoleModerator Role = "moderator" // модератор: закрытие приёма и просмотр прогнозов
+)
+
+// Rank возвращает старшинство роли (0 — нет роли)
+func (r Role) Rank() int {
+	switch r {
+	case RoleOwner:
+		return 3
+	case RoleAdmin:
+		return 2
+	case RoleModerator:
+		return 1
+	}
+	return 0
+}
+
+// Title возвращает название роли для сообщений
+func (r Role) Title() string {
+	switch r {
+	case RoleOwner:
+		return "владелец"
+	case RoleAdmin:
+		return "администратор"
+	case RoleModerator:
+		return "модератор"
+	}
+	return string(r)
+}
+
+// Роль, выданная пользователю платформы
+type UserRole struct {
+	Platform  string    `json:"platform"` // PlatformVK / PlatformTelegram
+	UserID    int       `json:"user_id"`
+	ChatID    int64     `json:"chat_id"` // чат, в котором действует роль, 0 — во всех чатах
+	Role      Role      `json:"role"`
+	GrantedBy int       `json:"granted_by,omitempty"` // кто выдал роль, 0 — из конфигурации
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -45,19 +45,20 @@ type Circuit struct {
 }
 
 type Result struct {
-	Number      string
-	Position    string
-	Points      string
-	Driver      Driver
-	Constructor Constructors
-	Grid        string
-	Laps        string
-	Status      string
-	Time        Time
-	FastestLap  FastestLap
-	Q1          string
-	Q2          string
-	Q3          string
+	Number       string
+	Position     string
+	PositionText string // "R" — сход, "D" — дисквалификация, иначе место
+	Points       string
+	Driver       Driver
+	Constructor  Constructors
+	Grid         string
+	Laps         string
+	Status       string
+	Time         Time
+	FastestLap   FastestLap
+	Q1           string
+	Q2           string
+	Q3           string
 }
 
 type Time struct {
@@ -104,11 +105,17 @@ type DriverTable struct {
 	Drivers []Driver
 }
 
+type ConstructorTable struct {
+	Constructors []Constructors
+}
+
 type MRData struct {
-	Series         string
-	RaceTable      RaceTable
-	StandingsTable StandingsTable
-	DriverTable    DriverTable
+	Series           string
+	Total            string // число записей ответа без учёта limit
+	RaceTable        RaceTable
+	StandingsTable   StandingsTable
+	DriverTable      DriverTable
+	ConstructorTable ConstructorTable
 }
 
 type Object struct {
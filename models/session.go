@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Тип сессии гоночного уикенда
+type SessionType string
+
+const (
+	SessionFP1              SessionType = "fp1"
+	SessionFP2              SessionType = "fp2"
+	SessionFP3              SessionType = "fp3"
+	SessionSprintQualifying SessionType = "sprint_qualifying"
+	SessionSprint           SessionType = "sprint"
+	SessionQualifying       SessionType = "qualifying"
+	SessionRace             SessionType = "race"
+)
+
+// Title возвращает название сессии для сообщений
+func (t SessionType) Title() string {
+	switch t {
+	case SessionFP1:
+		return "Первая практика"
+	case SessionFP2:
+		return "Вторая практика"
+	case SessionFP3:
+		return "Третья практика"
+	case SessionSprintQualifying:
+		return "Квалификация спринта"
+	case SessionSprint:
+		return "Спринт"
+	case SessionQualifying:
+		return "Квалификация"
+	case SessionRace:
+		return "Гонка"
+	}
+	return string(t)
+}
+
+// Сессия гоночного уикенда с временем старта (UTC)
+type Session struct {
+	Type  SessionType
+	Start time.Time
+}
+
+// Duration возвращает примерную длительность сессии: после неё сессия считается завершённой
+func (t SessionType) Duration() time.Duration {
+	switch t {
+	case SessionSprintQualifying, SessionSprint:
+		return 45 * time.Minute
+	case SessionRace:
+		return 2 * time.Hour
+	}
+	return time.Hour
+}
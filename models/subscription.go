@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Тема рассылки, на которую подписывается чат
+type Topic string
+
+const (
+	TopicRace        Topic = "race"        // напоминание о гонке
+	TopicQualifying  Topic = "qualifying"  // напоминание о квалификации
+	TopicSprint      Topic = "sprint"      // напоминания о квалификации спринта и спринте
+	TopicPractice    Topic = "practice"    // напоминания о практиках
+	TopicResults     Topic = "results"     // результаты сессий сразу после публикации
+	TopicPredictions Topic = "predictions" // объявления и итоги общих раундов прогнозов
+)
+
+// AllTopics — все темы в порядке вывода
+var AllTopics = []Topic{TopicRace, TopicQualifying, TopicSprint, TopicPractice, TopicResults, TopicPredictions}
+
+// Title возвращает название темы для сообщений
+func (t Topic) Title() string {
+	switch t {
+	case TopicRace:
+		return "гонка"
+	case TopicQualifying:
+		return "квалификация"
+	case TopicSprint:
+		return "спринт"
+	case TopicPractice:
+		return "практики"
+	case TopicResults:
+		return "результаты"
+	case TopicPredictions:
+		return "прогнозы"
+	}
+	return string(t)
+}
+
+// SessionTopic возвращает тему напоминаний о сессии
+func SessionTopic(session SessionType) Topic {
+	switch session {
+	case SessionRace:
+		return TopicRace
+	case SessionQualifying:
+		return TopicQualifying
+	case SessionSprint, SessionSprintQualifying:
+		return TopicSprint
+	}
+	return TopicPractice
+}
+
+// Подписка чата платформы (личный диалог — подписка пользователя)
+type Subscription struct {
+	Platform  string    `json:"platform"`
+	ChatID    int64     `json:"chat_id"`
+	Topics    []Topic   `json:"topics"`
+	UpdatedBy int       `json:"updated_by,omitempty"` // кто изменил подписку, 0 — подписка по умолчанию из конфигурации
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Has проверяет, подписан ли чат на тему
+func (s *Subscription) Has(topic Topic) bool {
+	for _, t := range s.Topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Профиль пользователя платформы для вывода имён в рейтингах и итогах прогнозов
+type UserProfile struct {
+	Platform  string    `json:"platform"` // PlatformVK / PlatformTelegram
+	UserID    int       `json:"user_id"`
+	FirstName string    `json:"first_name,omitempty"`
+	LastName  string    `json:"last_name,omitempty"`
+	Username  string    `json:"username,omitempty"` // screen_name VK / username Telegram
+	Nickname  string    `json:"nickname,omitempty"` // псевдоним вместо настоящего имени (отказ от показа имени)
+	UpdatedAt time.Time `json:"updated_at"`         // время последнего обновления имени с платформы
+}
+
+// DisplayName возвращает имя для вывода: никнейм, если пользователь его задал,
+// иначе имя и фамилию или username. Пустая строка — имя неизвестно.
+func (p UserProfile) DisplayName() string {
+	if p.Nickname != "" {
+		return p.Nickname
+	}
+	if name := strings.TrimSpace(p.FirstName + " " + p.LastName); name != "" {
+		return name
+	}
+	if p.Username != "" {
+		return "@" + p.Username
+	}
+	return ""
+}
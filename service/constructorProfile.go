@@ -0,0 +1,232 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Сколько последних гонок команды показывать в профиле
+const constructorRecentRaces = 5
+
+// constructorAliases — написания команд кириллицей, сокращения и названия с другим идентификатором в Ergast (после normalizeDriverName)
+var constructorAliases = map[string]string{
+	"феррари":       "ferrari",
+	"мерседес":      "mercedes",
+	"макларен":      "mclaren",
+	"ред булл":      "red_bull",
+	"редбулл":       "red_bull",
+	"рб":            "rb",
+	"рейсинг буллз": "rb",
+	"альфатаури":    "alphatauri",
+	"торо россо":    "toro_rosso",
+	"астон мартин":  "aston_martin",
+	"астон":         "aston_martin",
+	"альпин":        "alpine",
+	"альпайн":       "alpine",
+	"рено":          "renault",
+	"уильямс":       "williams",
+	"вильямс":       "williams",
+	"хаас":          "haas",
+	"заубер":        "sauber",
+	"ауди":          "audi",
+	"кадиллак":      "cadillac",
+	"лотус":         "team_lotus",
+	"lotus":         "team_lotus",
+	"брэбем":        "brabham",
+	"тиррелл":       "tyrrell",
+	"бенеттон":      "benetton",
+	"джордан":       "jordan",
+}
+
+// GetConstructorProfileMessage возвращает профиль команды: гонщиков, место, очки и победы
+// в текущем сезоне, результаты обеих машин в последних гонках и историю кубков конструкторов.
+// query — название латиницей или кириллицей либо идентификатор Ergast ("red_bull").
+func (s *ServiceF1) GetConstructorProfileMessage(query string, now time.Time) (string, error) {
+	season := now.Year()
+	constructor, err := s.findConstructor(query, season)
+	if err != nil {
+		if errors.Is(err, temperrors.ErrUnknownConstructor) {
+			return fmt.Sprintf("Команда «%s» не найдена. Укажите название, например: феррари, red bull, mclaren.", query), nil
+		}
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🏭 %s", constructor.Name))
+	if constructor.Nationality != "" {
+		sb.WriteString(fmt.Sprintf(" (%s)", constructor.Nationality))
+	}
+	sb.WriteString("\n")
+
+	if err := s.writeConstructorSeason(&sb, constructor.ConstructorId, season); err != nil {
+		return "", err
+	}
+
+	if err := s.writeConstructorHistory(&sb, constructor.ConstructorId, now); err != nil {
+		slog.Error("failed to get constructor history", slog.String("constructor", constructor.ConstructorId), slog.Any("error", err))
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// findConstructor ищет команду среди команд сезона (идентификатор, название или начало
+// названия), затем — по идентификатору Ergast, чтобы находить и команды прошлых лет
+func (s *ServiceF1) findConstructor(query string, season int) (models.Constructors, error) {
+	name := strings.Join(strings.Fields(normalizeDriverName(query)), " ")
+	if name == "" {
+		return models.Constructors{}, temperrors.ErrUnknownConstructor
+	}
+	id := strings.ReplaceAll(name, " ", "_")
+	if alias, ok := constructorAliases[name]; ok {
+		id = alias
+	}
+
+	constructors, err := s.storage.GetConstructorsList(season)
+	if err != nil && !errors.Is(err, temperrors.ErrEmptyList) {
+		slog.Warn("failed to get constructors list", slog.Any("error", err))
+	}
+	var prefixed []models.Constructors
+	for _, c := range constructors {
+		cName := normalizeDriverName(c.Name)
+		if c.ConstructorId == id || cName == name {
+			return c, nil
+		}
+		if strings.HasPrefix(cName, name) || strings.HasPrefix(c.ConstructorId, id) {
+			prefixed = append(prefixed, c)
+		}
+	}
+	if len(prefixed) == 1 {
+		return prefixed[0], nil
+	}
+
+	constructor, err := s.storage.GetConstructor(id)
+	if err != nil {
+		if !errors.Is(err, temperrors.ErrEmptyList) {
+			slog.Warn("failed to get constructor", slog.String("constructor", id), slog.Any("error", err))
+		}
+		return models.Constructors{}, fmt.Errorf("constructor %q: %w", query, temperrors.ErrUnknownConstructor)
+	}
+	return constructor, nil
+}
+
+// writeConstructorSeason дописывает гонщиков, положение в кубке конструкторов и последние
+// гонки команды в сезоне. До первой гонки сезона показывается прошлый сезон.
+func (s *ServiceF1) writeConstructorSeason(sb *strings.Builder, constructorID string, season int) error {
+	races, err := s.storage.GetConstructorResults(season, constructorID)
+	if errors.Is(err, temperrors.ErrEmptyList) {
+		season--
+		races, err = s.storage.GetConstructorResults(season, constructorID)
+	}
+	if err != nil {
+		if errors.Is(err, temperrors.ErrEmptyList) {
+			// Команда не выступает ни в этом, ни в прошлом сезоне
+			return nil
+		}
+		slog.Error("failed to get constructor results", slog.Any("error", err))
+		return err
+	}
+
+	drivers, err := s.storage.GetConstructorDrivers(season, constructorID)
+	if err != nil && !errors.Is(err, temperrors.ErrEmptyList) {
+		slog.Error("failed to get constructor drivers", slog.Any("error", err))
+		return err
+	}
+	if len(drivers) > 0 {
+		names := make([]string, 0, len(drivers))
+		for _, d := range drivers {
+			names = append(names, d.GivenName+" "+d.FamilyName)
+		}
+		sb.WriteString(fmt.Sprintf("Гонщики %d: %s\n", season, strings.Join(names, ", ")))
+	}
+
+	standings, err := s.storage.GetConstructorStandings(season, "last")
+	if err != nil && !errors.Is(err, temperrors.ErrEmptyList) {
+		slog.Error("failed to get constructor standings", slog.Any("error", err))
+		return err
+	}
+	for _, item := range standings {
+		if item.Constructor.ConstructorId == constructorID {
+			sb.WriteString(fmt.Sprintf("Сезон %d: %s место, очки — %s, победы — %s\n", season, item.Position, item.Points, item.Wins))
+			break
+		}
+	}
+
+	if len(races) > constructorRecentRaces {
+		races = races[len(races)-constructorRecentRaces:]
+	}
+	sb.WriteString("\nПоследние гонки:\n")
+	for _, race := range races {
+		sb.WriteString(fmt.Sprintf("%s. %s: %s\n", race.Round, race.RaceName, constructorRaceResults(race.Results)))
+	}
+	return nil
+}
+
+// constructorRaceResults — "VER 1, TSU сход"
+func constructorRaceResults(results []models.Result) string {
+	parts := make([]string, 0, len(results))
+	for _, r := range results {
+		position := r.PositionText
+		if _, err := strconv.Atoi(position); err != nil {
+			position = "сход"
+			if r.PositionText == "D" {
+				position = "дискв."
+			}
+		}
+		parts = append(parts, driverCode(r.Driver)+" "+position)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeConstructorHistory дописывает сезоны, победы и кубки конструкторов команды. Кубок
+// идущего сезона не засчитывается, пока в календаре остаются гонки.
+func (s *ServiceF1) writeConstructorHistory(sb *strings.Builder, constructorID string, now time.Time) error {
+	seasons, err := s.storage.CountConstructorSeasons(constructorID)
+	if err != nil {
+		return err
+	}
+	wins, err := s.storage.CountConstructorWins(constructorID)
+	if err != nil {
+		return err
+	}
+	titles, err := s.storage.GetConstructorTitles(constructorID)
+	if err != nil {
+		return err
+	}
+
+	seasonOver := s.seasonFinished(now)
+	years := make([]string, 0, len(titles))
+	for _, t := range titles {
+		if t.Season == strconv.Itoa(now.Year()) && !seasonOver {
+			continue
+		}
+		years = append(years, t.Season)
+	}
+
+	sb.WriteString(fmt.Sprintf("\nИстория:\nСезонов в F1: %d\nПобед: %d\n", seasons, wins))
+	if len(years) == 0 {
+		sb.WriteString("Кубков конструкторов: 0")
+		return nil
+	}
+	sb.WriteString(fmt.Sprintf("Кубков конструкторов: %d (%s)", len(years), strings.Join(years, ", ")))
+	return nil
+}
+
+// seasonFinished проверяет, что все гонки текущего сезона уже прошли
+func (s *ServiceF1) seasonFinished(now time.Time) bool {
+	calendar, err := s.storage.GetCalendar(now.Year())
+	if err != nil || len(calendar) == 0 {
+		return false
+	}
+	last := calendar[len(calendar)-1]
+	raceDate, err := time.Parse(time.DateOnly, last.Date)
+	if err != nil {
+		return false
+	}
+	return now.After(raceDate.Add(24 * time.Hour))
+}
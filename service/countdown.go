@@ -0,0 +1,130 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"strings"
+	"time"
+)
+
+// GetCountdownMessage возвращает обратный отсчёт до ближайшей сессии. Во время гоночного
+// уикенда (от старта первой сессии до конца гонки) показывает, какие сессии прошли, какая
+// идёт и сколько осталось до следующих. Время — в часовом поясе loc.
+func (s *ServiceF1) GetCountdownMessage(now time.Time, loc *time.Location) (string, error) {
+	race, err := s.findCountdownRace(now)
+	if err != nil {
+		if errors.Is(err, temperrors.ErrEmptyList) {
+			return "Сезон завершён, календарь следующего ещё не опубликован.", nil
+		}
+		return "", err
+	}
+
+	sessions := RaceSessions(race)
+	if len(sessions) == 0 {
+		return fmt.Sprintf("Время сессий %s ещё не объявлено.", race.RaceName), nil
+	}
+
+	if now.Before(sessions[0].Start) {
+		return nextWeekendCountdown(race, sessions, now, loc), nil
+	}
+	return weekendProgress(race, sessions, now, loc), nil
+}
+
+// findCountdownRace возвращает этап, который идёт сейчас или будет следующим;
+// после последнего этапа сезона ищет первый этап следующего
+func (s *ServiceF1) findCountdownRace(now time.Time) (models.Race, error) {
+	for _, year := range []int{now.Year(), now.Year() + 1} {
+		calendar, err := s.storage.GetCalendar(year)
+		if err != nil {
+			if errors.Is(err, temperrors.ErrEmptyList) {
+				continue
+			}
+			slog.Error("failed to get calendar", slog.Any("error", err))
+			return models.Race{}, err
+		}
+
+		for _, race := range calendar {
+			sessions := RaceSessions(race)
+			if len(sessions) == 0 {
+				continue
+			}
+			last := sessions[len(sessions)-1]
+			if now.Before(last.Start.Add(last.Type.Duration())) {
+				return race, nil
+			}
+		}
+	}
+	return models.Race{}, temperrors.ErrEmptyList
+}
+
+// nextWeekendCountdown — отсчёт до первой сессии и гонки следующего этапа
+func nextWeekendCountdown(race models.Race, sessions []models.Session, now time.Time, loc *time.Location) string {
+	first, last := sessions[0], sessions[len(sessions)-1]
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("⏳ Следующий этап: %s\n", race.RaceName))
+	sb.WriteString(fmt.Sprintf("%s — через %s (%s)\n", first.Type.Title(), formatCountdown(first.Start.Sub(now)), formatLocalTime(first.Start, loc)))
+	if last.Type != first.Type {
+		sb.WriteString(fmt.Sprintf("%s — через %s (%s)\n", last.Type.Title(), formatCountdown(last.Start.Sub(now)), formatLocalTime(last.Start, loc)))
+	}
+	sb.WriteString(fmt.Sprintf("\nВремя %s.", zoneLabel(first.Start, loc)))
+	return sb.String()
+}
+
+// weekendProgress — состояние идущего уикенда: завершённые, текущая и предстоящие сессии
+func weekendProgress(race models.Race, sessions []models.Session, now time.Time, loc *time.Location) string {
+	var done, current, upcoming []string
+	for _, session := range sessions {
+		switch end := session.Start.Add(session.Type.Duration()); {
+		case !now.Before(end):
+			done = append(done, "✅ "+session.Type.Title())
+		case !now.Before(session.Start):
+			current = append(current, "🔴 "+session.Type.Title())
+		default:
+			upcoming = append(upcoming, fmt.Sprintf("⏳ %s — через %s (%s)", session.Type.Title(), formatCountdown(session.Start.Sub(now)), formatLocalTime(session.Start, loc)))
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🏁 Идёт гоночный уикенд: %s\n", race.RaceName))
+	for _, group := range []struct {
+		title string
+		lines []string
+	}{
+		{"Завершены", done},
+		{"Идёт сейчас", current},
+		{"Впереди", upcoming},
+	} {
+		if len(group.lines) > 0 {
+			sb.WriteString(fmt.Sprintf("\n%s:\n%s\n", group.title, strings.Join(group.lines, "\n")))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("\nВремя %s.", zoneLabel(now, loc)))
+	return sb.String()
+}
+
+// nextSessionLine — строка отсчёта до ближайшей сессии этапа для сообщения о следующей гонке
+func nextSessionLine(race models.Race, now time.Time) string {
+	for _, session := range RaceSessions(race) {
+		if session.Start.After(now) {
+			return fmt.Sprintf("⏳ До ближайшей сессии (%s): %s", strings.ToLower(session.Type.Title()), formatCountdown(session.Start.Sub(now)))
+		}
+	}
+	return ""
+}
+
+// formatCountdown форматирует интервал для отсчёта: "3 д 4 ч 15 мин", "5 ч 20 мин"
+func formatCountdown(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	if days == 0 {
+		return formatRemaining(d)
+	}
+	rest := d - time.Duration(days)*24*time.Hour
+	if rest < time.Minute {
+		return fmt.Sprintf("%d д", days)
+	}
+	return fmt.Sprintf("%d д %s", days, formatRemaining(rest))
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// driverCareer — карьерная статистика гонщика в гонках чемпионата мира
+type driverCareer struct {
+	starts, wins, poles, podiums int
+}
+
+// GetDriverProfileMessage возвращает профиль гонщика: гражданство, возраст, команду и место
+// в текущем сезоне, карьерные старты, победы, поулы и подиумы. query — номер, код (VER),
+// фамилия латиницей или кириллицей либо идентификатор Ergast ("michael_schumacher").
+func (s *ServiceF1) GetDriverProfileMessage(query string, now time.Time) (string, error) {
+	driver, err := s.findDriver(query, now.Year())
+	if err != nil {
+		if errors.Is(err, temperrors.ErrUnknownDriver) {
+			return fmt.Sprintf("Гонщик «%s» не найден. Укажите номер, код (VER) или фамилию.", query), nil
+		}
+		return "", err
+	}
+
+	standing, err := s.driverStanding(driver.DriverId, now.Year())
+	if err != nil {
+		return "", err
+	}
+
+	career, err := s.driverCareer(driver.DriverId)
+	if err != nil {
+		slog.Error("failed to get driver career", slog.String("driver", driver.DriverId), slog.Any("error", err))
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("👤 %s %s", driver.GivenName, driver.FamilyName))
+	if driver.Code != "" {
+		sb.WriteString(" — " + driver.Code)
+	}
+	if driver.PermanentNumber != "" {
+		sb.WriteString(", №" + driver.PermanentNumber)
+	}
+	sb.WriteString("\n")
+	if driver.Nationality != "" {
+		sb.WriteString(fmt.Sprintf("Гражданство: %s\n", driver.Nationality))
+	}
+	if driver.DateOfBirth != "" {
+		sb.WriteString(fmt.Sprintf("Дата рождения: %s", ruMonth(driver.DateOfBirth)))
+		if age, ok := driverAge(driver.DateOfBirth, now); ok {
+			sb.WriteString(fmt.Sprintf(" (возраст %d)", age))
+		}
+		sb.WriteString("\n")
+	}
+	if standing != nil {
+		if n := len(standing.Constructors); n > 0 {
+			sb.WriteString(fmt.Sprintf("Команда: %s\n", standing.Constructors[n-1].Name))
+		}
+		sb.WriteString(fmt.Sprintf("Сезон %d: %s место, очки — %s, победы — %s\n", now.Year(), standing.PositionText, standing.Points, standing.Wins))
+	}
+
+	sb.WriteString(fmt.Sprintf("\nКарьера в F1:\nСтарты: %d\nПобеды: %d\nПоулы: %d\nПодиумы: %d",
+		career.starts, career.wins, career.poles, career.podiums))
+	return sb.String(), nil
+}
+
+// findDriver ищет гонщика сначала в заявке сезона (номер, код, фамилия), затем —
+// по идентификатору Ergast, чтобы находить и гонщиков прошлых лет
+func (s *ServiceF1) findDriver(query string, season int) (models.Driver, error) {
+	tokens := SplitDriverTokens(query)
+	if len(tokens) == 0 {
+		return models.Driver{}, temperrors.ErrUnknownDriver
+	}
+
+	grid, err := NewDriverResolver(s.storage).Grid(season)
+	if err != nil && !errors.Is(err, temperrors.ErrEmptyList) {
+		slog.Warn("failed to get drivers grid", slog.Any("error", err))
+	}
+	for _, token := range tokens {
+		if g, ok := findGridDriver(grid, token); ok {
+			return g.Driver, nil
+		}
+	}
+
+	names := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if _, err := strconv.Atoi(token); err == nil {
+			continue
+		}
+		names = append(names, latinDriverName(token))
+	}
+	if len(names) == 0 {
+		return models.Driver{}, temperrors.ErrUnknownDriver
+	}
+
+	// "michael schumacher" → michael_schumacher, затем каждое слово отдельно: "senna"
+	ids := []string{strings.Join(names, "_")}
+	if len(names) > 1 {
+		ids = append(ids, names...)
+	}
+	for _, id := range ids {
+		driver, err := s.storage.GetDriver(id)
+		if err == nil {
+			return driver, nil
+		}
+		if !errors.Is(err, temperrors.ErrEmptyList) {
+			slog.Warn("failed to get driver", slog.String("driver", id), slog.Any("error", err))
+		}
+	}
+	return models.Driver{}, fmt.Errorf("driver %q: %w", query, temperrors.ErrUnknownDriver)
+}
+
+// driverStanding возвращает строку гонщика в личном зачёте сезона или nil, если он не выступает
+func (s *ServiceF1) driverStanding(driverID string, season int) (*models.DriverStandingsItem, error) {
+	standings, err := s.storage.GetDriverStandings(season, "last")
+	if err != nil {
+		if errors.Is(err, temperrors.ErrEmptyList) {
+			return nil, nil
+		}
+		slog.Error("failed to get driver standings", slog.Any("error", err))
+		return nil, err
+	}
+	for i := range standings {
+		if standings[i].Driver.DriverId == driverID {
+			return &standings[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// driverCareer считает старты, победы, поулы и подиумы гонщика по запросам к Ergast
+func (s *ServiceF1) driverCareer(driverID string) (driverCareer, error) {
+	var career driverCareer
+	var err error
+
+	if career.starts, err = s.storage.CountDriverResults(driverID, 0); err != nil {
+		return career, err
+	}
+	if career.poles, err = s.storage.CountDriverPoles(driverID); err != nil {
+		return career, err
+	}
+	for position := 1; position <= 3; position++ {
+		count, err := s.storage.CountDriverResults(driverID, position)
+		if err != nil {
+			return career, err
+		}
+		if position == 1 {
+			career.wins = count
+		}
+		career.podiums += count
+	}
+	return career, nil
+}
+
+// driverAge возвращает полных лет на дату now по дате рождения "1997-09-30"
+func driverAge(dateOfBirth string, now time.Time) (int, bool) {
+	born, err := time.Parse(time.DateOnly, dateOfBirth)
+	if err != nil {
+		return 0, false
+	}
+	age := now.Year() - born.Year()
+	if now.Month() < born.Month() || (now.Month() == born.Month() && now.Day() < born.Day()) {
+		age--
+	}
+	return age, true
+}
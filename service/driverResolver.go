@@ -0,0 +1,292 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"strconv"
+	"strings"
+)
+
+type driverGridSource interface {
+	GetDriversList(season int) ([]models.Driver, error)
+	GetRaceResults(season int, raceId string) ([]models.Race, error)
+}
+
+// UnknownDriverError — в прогнозе указан гонщик, которого нет в заявке сезона
+type UnknownDriverError struct {
+	Token string // слово или номер из сообщения пользователя
+}
+
+func (e *UnknownDriverError) Error() string {
+	return fmt.Sprintf("unknown driver %q", e.Token)
+}
+
+func (e *UnknownDriverError) Unwrap() error {
+	return temperrors.ErrUnknownDriver
+}
+
+// DuplicateDriverError — один и тот же гонщик указан в прогнозе несколько раз
+type DuplicateDriverError struct {
+	Number uint8
+}
+
+func (e *DuplicateDriverError) Error() string {
+	return fmt.Sprintf("driver #%d is used more than once", e.Number)
+}
+
+func (e *DuplicateDriverError) Unwrap() error {
+	return temperrors.ErrDuplicateDriver
+}
+
+// DriverNotOnGridError — под указанным номером никто не выступает в сезоне раунда
+type DriverNotOnGridError struct {
+	Number uint8
+	Season int
+}
+
+func (e *DriverNotOnGridError) Error() string {
+	return fmt.Sprintf("driver #%d is not on the %d grid", e.Number, e.Season)
+}
+
+func (e *DriverNotOnGridError) Unwrap() error {
+	return temperrors.ErrDriverNotOnGrid
+}
+
+// GridDriver — гонщик заявки сезона и номер, под которым он выступает
+type GridDriver struct {
+	Number uint8
+	Driver models.Driver
+}
+
+// DriverResolver сопоставляет номер, код (VER) или фамилию гонщика (латиницей
+// или кириллицей) с номером машины в текущей заявке сезона
+type DriverResolver struct {
+	source driverGridSource
+}
+
+func NewDriverResolver(source driverGridSource) *DriverResolver {
+	return &DriverResolver{source: source}
+}
+
+// Grid возвращает заявку сезона. Номер машины берётся из результатов последней гонки
+// сезона (чемпион выступает под №1), до первой гонки — постоянный номер гонщика.
+func (r *DriverResolver) Grid(season int) ([]GridDriver, error) {
+	drivers, err := r.source.GetDriversList(season)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get drivers list: %w", err)
+	}
+
+	raceNumbers := make(map[string]string)
+	races, err := r.source.GetRaceResults(season, "last")
+	if err != nil && !errors.Is(err, temperrors.ErrEmptyList) {
+		return nil, fmt.Errorf("failed to get last race results: %w", err)
+	}
+	if len(races) > 0 {
+		for _, res := range races[0].Results {
+			raceNumbers[res.Driver.DriverId] = res.Number
+		}
+	}
+
+	grid := make([]GridDriver, 0, len(drivers))
+	for _, d := range drivers {
+		number, ok := raceNumbers[d.DriverId]
+		if !ok {
+			number = d.PermanentNumber
+		}
+		num, err := strconv.ParseUint(number, 10, 8)
+		if err != nil || num == 0 {
+			continue
+		}
+		grid = append(grid, GridDriver{Number: uint8(num), Driver: d})
+	}
+	return grid, nil
+}
+
+// Resolve возвращает номера машин для слов из сообщения пользователя.
+// Нераспознанное слово возвращается как *UnknownDriverError.
+// Если заявку получить не удалось или она пуста (сезон ещё не заполнен в Ergast),
+// принимаются только числовые номера без проверки — как и в Validate.
+func (r *DriverResolver) Resolve(season int, tokens []string) ([]uint8, error) {
+	grid, gridErr := r.Grid(season)
+	if gridErr == nil && len(grid) == 0 {
+		gridErr = fmt.Errorf("drivers grid for season %d: %w", season, temperrors.ErrEmptyList)
+	}
+
+	numbers := make([]uint8, 0, len(tokens))
+	for _, token := range tokens {
+		if gridErr != nil {
+			num, err := strconv.ParseUint(token, 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("driver %q can not be resolved: %w", token, gridErr)
+			}
+			numbers = append(numbers, uint8(num))
+			continue
+		}
+
+		num, ok := matchDriver(grid, token)
+		if !ok {
+			return nil, &UnknownDriverError{Token: token}
+		}
+		numbers = append(numbers, num)
+	}
+	return numbers, nil
+}
+
+// Label возвращает короткую подпись гонщика для кнопок: код (VER) или фамилию
+func (g GridDriver) Label() string {
+	if g.Driver.Code != "" {
+		return g.Driver.Code
+	}
+	return g.Driver.FamilyName
+}
+
+// GridDriverName возвращает "№1 Max Verstappen" или просто номер, если гонщика нет в заявке
+func GridDriverName(grid []GridDriver, number uint8) string {
+	for _, g := range grid {
+		if g.Number == number {
+			return fmt.Sprintf("№%d %s %s", number, g.Driver.GivenName, g.Driver.FamilyName)
+		}
+	}
+	return fmt.Sprintf("№%d", number)
+}
+
+// Validate проверяет, что гонщики не повторяются и выступают в заявке сезона.
+// Нулевые номера (вопрос пропущен) не проверяются. Если заявку получить не удалось,
+// проверяется только уникальность — как и в Resolve, прогноз не блокируется из-за недоступности API.
+func (r *DriverResolver) Validate(season int, numbers ...uint8) error {
+	seen := make(map[uint8]bool, len(numbers))
+	for _, num := range numbers {
+		if num == 0 {
+			continue
+		}
+		if seen[num] {
+			return &DuplicateDriverError{Number: num}
+		}
+		seen[num] = true
+	}
+
+	grid, err := r.Grid(season)
+	if err != nil || len(grid) == 0 {
+		return nil
+	}
+	onGrid := make(map[uint8]bool, len(grid))
+	for _, g := range grid {
+		onGrid[g.Number] = true
+	}
+	for _, num := range numbers {
+		if num != 0 && !onGrid[num] {
+			return &DriverNotOnGridError{Number: num, Season: season}
+		}
+	}
+	return nil
+}
+
+// matchDriver ищет гонщика по номеру машины, постоянному номеру, коду или фамилии
+func matchDriver(grid []GridDriver, token string) (uint8, bool) {
+	g, ok := findGridDriver(grid, token)
+	return g.Number, ok
+}
+
+// findGridDriver ищет гонщика заявки по номеру машины, постоянному номеру, коду или фамилии
+func findGridDriver(grid []GridDriver, token string) (GridDriver, bool) {
+	if num, err := strconv.ParseUint(token, 10, 8); err == nil {
+		for _, g := range grid {
+			if uint64(g.Number) == num {
+				return g, true
+			}
+		}
+		for _, g := range grid {
+			if g.Driver.PermanentNumber == strconv.FormatUint(num, 10) {
+				return g, true
+			}
+		}
+		return GridDriver{}, false
+	}
+
+	name := latinDriverName(token)
+	for _, g := range grid {
+		if strings.EqualFold(g.Driver.Code, name) || normalizeDriverName(g.Driver.FamilyName) == name {
+			return g, true
+		}
+	}
+	return GridDriver{}, false
+}
+
+// latinDriverName нормализует фамилию и переводит написание кириллицей в латиницу
+func latinDriverName(token string) string {
+	name := normalizeDriverName(token)
+	if latin, ok := russianFamilyNames[name]; ok {
+		return latin
+	}
+	return name
+}
+
+// SplitDriverTokens разбивает сообщение на номера/имена гонщиков (через пробел или запятую)
+func SplitDriverTokens(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return r == ' ' || r == ',' || r == ';' || r == '\n' || r == '\t'
+	})
+}
+
+// normalizeDriverName приводит фамилию к нижнему регистру без диакритики: "Hülkenberg" → "hulkenberg"
+func normalizeDriverName(name string) string {
+	return diacriticsReplacer.Replace(strings.ToLower(strings.TrimSpace(name)))
+}
+
+var diacriticsReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "ä", "a", "â", "a", "ã", "a",
+	"é", "e", "è", "e", "ë", "e", "ê", "e",
+	"í", "i", "ï", "i", "î", "i",
+	"ó", "o", "ö", "o", "ô", "o", "õ", "o",
+	"ú", "u", "ü", "u", "û", "u",
+	"ñ", "n", "ç", "c", "ё", "е",
+)
+
+// russianFamilyNames — написания фамилий гонщиков кириллицей (после normalizeDriverName)
+var russianFamilyNames = map[string]string{
+	"ферстаппен":  "verstappen",
+	"норрис":      "norris",
+	"леклер":      "leclerc",
+	"пиастри":     "piastri",
+	"сайнс":       "sainz",
+	"хэмилтон":    "hamilton",
+	"хамилтон":    "hamilton",
+	"гамильтон":   "hamilton",
+	"расселл":     "russell",
+	"рассел":      "russell",
+	"алонсо":      "alonso",
+	"стролл":      "stroll",
+	"гасли":       "gasly",
+	"окон":        "ocon",
+	"албон":       "albon",
+	"элбон":       "albon",
+	"хюлькенберг": "hulkenberg",
+	"хулькенберг": "hulkenberg",
+	"цунода":      "tsunoda",
+	"лоусон":      "lawson",
+	"антонелли":   "antonelli",
+	"бортолето":   "bortoleto",
+	"хаджар":      "hadjar",
+	"беарман":     "bearman",
+	"берман":      "bearman",
+	"колапинто":   "colapinto",
+	"дуэн":        "doohan",
+	"духан":       "doohan",
+	"перес":       "perez",
+	"боттас":      "bottas",
+	"чжоу":        "zhou",
+	"магнуссен":   "magnussen",
+	"сарджент":    "sargeant",
+	"риккардо":    "ricciardo",
+	"линдблад":    "lindblad",
+	"деврис":      "de vries",
+	"латифи":      "latifi",
+	"мазепин":     "mazepin",
+	"шумахер":     "schumacher",
+	"феттель":     "vettel",
+	"райкконен":   "raikkonen",
+	"квят":        "kvyat",
+	"джовинацци":  "giovinazzi",
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"reflect"
+	"testing"
+)
+
+// stubGrid — заявка сезона без обращений к Ergast
+type stubGrid struct {
+	drivers []models.Driver
+	results []models.Result // результаты последней гонки, nil — сезон ещё не начался
+	err     error
+}
+
+func (s stubGrid) GetDriversList(int) ([]models.Driver, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.drivers, nil
+}
+
+func (s stubGrid) GetRaceResults(int, string) ([]models.Race, error) {
+	if len(s.results) == 0 {
+		return nil, temperrors.ErrEmptyList
+	}
+	return []models.Race{{Results: s.results}}, nil
+}
+
+var (
+	testVerstappen = models.Driver{DriverId: "max_verstappen", PermanentNumber: "33", Code: "VER", GivenName: "Max", FamilyName: "Verstappen"}
+	testNorris     = models.Driver{DriverId: "norris", PermanentNumber: "4", Code: "NOR", GivenName: "Lando", FamilyName: "Norris"}
+	testHulkenberg = models.Driver{DriverId: "hulkenberg", PermanentNumber: "27", Code: "HUL", GivenName: "Nico", FamilyName: "Hülkenberg"}
+)
+
+// testGrid — чемпион выступает под №1, а не под постоянным №33
+func testGrid() stubGrid {
+	return stubGrid{
+		drivers: []models.Driver{testVerstappen, testNorris, testHulkenberg},
+		results: []models.Result{
+			{Number: "1", Driver: testVerstappen},
+			{Number: "4", Driver: testNorris},
+			{Number: "27", Driver: testHulkenberg},
+		},
+	}
+}
+
+func TestDriverResolverResolve(t *testing.T) {
+	unavailable := stubGrid{err: errors.New("ergast is down")}
+	emptySeason := stubGrid{}
+
+	tests := []struct {
+		name    string
+		source  stubGrid
+		tokens  []string
+		want    []uint8
+		wantErr error
+	}{
+		{"номер машины", testGrid(), []string{"1", "4", "27"}, []uint8{1, 4, 27}, nil},
+		{"постоянный номер чемпиона", testGrid(), []string{"33"}, []uint8{1}, nil},
+		{"коды", testGrid(), []string{"ver", "NOR", "Hul"}, []uint8{1, 4, 27}, nil},
+		{"фамилии латиницей", testGrid(), []string{"Verstappen", "hulkenberg"}, []uint8{1, 27}, nil},
+		{"фамилии кириллицей", testGrid(), []string{"ферстаппен", "норрис", "хюлькенберг"}, []uint8{1, 4, 27}, nil},
+		{"неизвестная фамилия", testGrid(), []string{"ver", "сенна"}, nil, temperrors.ErrUnknownDriver},
+		{"номера нет в заявке", testGrid(), []string{"99"}, nil, temperrors.ErrUnknownDriver},
+		{"заявка недоступна: номера", unavailable, []string{"1", "99"}, []uint8{1, 99}, nil},
+		{"заявка недоступна: фамилия", unavailable, []string{"norris"}, nil, unavailable.err},
+		{"пустая заявка: номера", emptySeason, []string{"1", "4", "16"}, []uint8{1, 4, 16}, nil},
+		{"пустая заявка: фамилия", emptySeason, []string{"norris"}, nil, temperrors.ErrEmptyList},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewDriverResolver(tt.source).Resolve(2025, tt.tokens)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Resolve(%q) error = %v, want %v", tt.tokens, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q) error = %v", tt.tokens, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve(%q) = %v, want %v", tt.tokens, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDriverResolverValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  stubGrid
+		numbers []uint8
+		wantErr error
+	}{
+		{"все в заявке", testGrid(), []uint8{1, 4, 27}, nil},
+		{"пропущенный вопрос", testGrid(), []uint8{1, 0, 0}, nil},
+		{"повтор", testGrid(), []uint8{1, 4, 1}, temperrors.ErrDuplicateDriver},
+		{"повтор нулей не считается", testGrid(), []uint8{0, 0, 4}, nil},
+		{"нет в заявке", testGrid(), []uint8{1, 4, 33}, temperrors.ErrDriverNotOnGrid},
+		{"заявка недоступна", stubGrid{err: errors.New("ergast is down")}, []uint8{1, 4, 99}, nil},
+		{"заявка недоступна: повтор", stubGrid{err: errors.New("ergast is down")}, []uint8{4, 4}, temperrors.ErrDuplicateDriver},
+		{"пустая заявка", stubGrid{}, []uint8{1, 4, 99}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewDriverResolver(tt.source).Validate(2025, tt.numbers...)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Validate(%v) error = %v", tt.numbers, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate(%v) error = %v, want %v", tt.numbers, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// h2hStats — показатели одного гонщика в сравнении
+type h2hStats struct {
+	quali, race     int // побед в личных встречах
+	points          float64
+	finishes, total int // классифицированные финиши и сумма мест в них
+	dnfs            int
+	best, bestCount int // лучшее место и сколько раз
+}
+
+// ParseHeadToHeadArgs разбирает аргументы сравнения "гонщик гонщик [сезон]": без сезона —
+// текущий. Сезон проверяется ValidateSeason.
+func ParseHeadToHeadArgs(args []string, now time.Time) (first, second string, season int, err error) {
+	season = now.Year()
+	if n := len(args); n == 3 {
+		season, err = strconv.Atoi(args[2])
+		if err != nil {
+			return "", "", 0, fmt.Errorf("invalid season %q: %w", args[2], temperrors.ErrParse)
+		}
+		if err := ValidateSeason(season, now); err != nil {
+			return "", "", 0, err
+		}
+		args = args[:2]
+	}
+	if len(args) != 2 {
+		return "", "", 0, fmt.Errorf("head-to-head needs two drivers, got %q: %w", args, temperrors.ErrParse)
+	}
+	return args[0], args[1], season, nil
+}
+
+// GetHeadToHeadMessage сравнивает двух гонщиков по всем прошедшим этапам сезона: личные
+// встречи в квалификациях и гонках, очки в гонках, средний финиш, сходы и лучший результат
+func (s *ServiceF1) GetHeadToHeadMessage(first, second string, season int) (string, error) {
+	a, err := s.findSeasonDriver(first, season)
+	if err != nil {
+		return h2hDriverErrorMessage(first, season, err)
+	}
+	b, err := s.findSeasonDriver(second, season)
+	if err != nil {
+		return h2hDriverErrorMessage(second, season, err)
+	}
+	if a.DriverId == b.DriverId {
+		return "Укажите двух разных гонщиков.", nil
+	}
+
+	race, err := s.storage.GetGPInfo(season, "last")
+	if err != nil {
+		if errors.Is(err, temperrors.ErrEmptyList) {
+			return fmt.Sprintf("В сезоне %d ещё не было гонок.", season), nil
+		}
+		slog.Error("failed to get GP info", slog.Any("error", err))
+		return "", err
+	}
+	rounds, err := strconv.Atoi(race[0].Round)
+	if err != nil {
+		return "", fmt.Errorf("invalid round %q: %w", race[0].Round, temperrors.ErrParse)
+	}
+
+	var sa, sb h2hStats
+	for round := 1; round <= rounds; round++ {
+		id := strconv.Itoa(round)
+
+		quali, err := s.storage.GetQualifyingResults(season, id)
+		if err != nil && !errors.Is(err, temperrors.ErrEmptyList) {
+			slog.Error("failed to get qualifying results", slog.Int("round", round), slog.Any("error", err))
+			return "", err
+		}
+		if len(quali) > 0 {
+			qa, okA := findResult(quali[0].QualifyingResults, a.DriverId)
+			qb, okB := findResult(quali[0].QualifyingResults, b.DriverId)
+			if okA && okB {
+				countDuel(qa.Position, qb.Position, &sa.quali, &sb.quali)
+			}
+		}
+
+		results, err := s.storage.GetRaceResults(season, id)
+		if err != nil && !errors.Is(err, temperrors.ErrEmptyList) {
+			slog.Error("failed to get race results", slog.Int("round", round), slog.Any("error", err))
+			return "", err
+		}
+		if len(results) == 0 {
+			continue
+		}
+		ra, okA := findResult(results[0].Results, a.DriverId)
+		rb, okB := findResult(results[0].Results, b.DriverId)
+		if okA {
+			sa.addRace(ra)
+		}
+		if okB {
+			sb.addRace(rb)
+		}
+		if okA && okB {
+			countDuel(ra.Position, rb.Position, &sa.race, &sb.race)
+		}
+	}
+
+	return formatHeadToHead(a, b, sa, sb, season), nil
+}
+
+// findSeasonDriver ищет гонщика в заявке сезона, а если его там нет (сезоны без номеров
+// машин в Ergast, гонщик сменил команду) — в списке гонщиков сезона по коду или фамилии
+func (s *ServiceF1) findSeasonDriver(token string, season int) (models.Driver, error) {
+	grid, err := NewDriverResolver(s.storage).Grid(season)
+	if err != nil && !errors.Is(err, temperrors.ErrEmptyList) {
+		return models.Driver{}, err
+	}
+	if g, ok := findGridDriver(grid, token); ok {
+		return g.Driver, nil
+	}
+
+	drivers, err := s.storage.GetDriversList(season)
+	if err != nil && !errors.Is(err, temperrors.ErrEmptyList) {
+		return models.Driver{}, err
+	}
+	name := latinDriverName(token)
+	for _, d := range drivers {
+		if strings.EqualFold(d.Code, name) || normalizeDriverName(d.FamilyName) == name {
+			return d, nil
+		}
+	}
+	return models.Driver{}, &UnknownDriverError{Token: token}
+}
+
+// h2hDriverErrorMessage переводит ошибку поиска гонщика в ответ пользователю
+func h2hDriverErrorMessage(token string, season int, err error) (string, error) {
+	if errors.Is(err, temperrors.ErrUnknownDriver) {
+		return fmt.Sprintf("Гонщик «%s» не выступал в сезоне %d.", token, season), nil
+	}
+	slog.Error("failed to find driver", slog.String("driver", token), slog.Any("error", err))
+	return "", err
+}
+
+// findResult возвращает результат гонщика в сессии
+func findResult(results []models.Result, driverID string) (models.Result, bool) {
+	for _, r := range results {
+		if r.Driver.DriverId == driverID {
+			return r, true
+		}
+	}
+	return models.Result{}, false
+}
+
+// countDuel засчитывает личную встречу гонщику с меньшим местом
+func countDuel(posA, posB string, winsA, winsB *int) {
+	a, errA := strconv.Atoi(posA)
+	b, errB := strconv.Atoi(posB)
+	if errA != nil || errB != nil || a == b {
+		return
+	}
+	if a < b {
+		*winsA++
+	} else {
+		*winsB++
+	}
+}
+
+// addRace учитывает очки, финиш или сход гонщика в гонке
+func (st *h2hStats) addRace(r models.Result) {
+	if points, err := strconv.ParseFloat(r.Points, 64); err == nil {
+		st.points += points
+	}
+
+	position, err := strconv.Atoi(r.PositionText)
+	if err != nil {
+		// "R" — сход, "N" — не классифицирован; дисквалификации и неучастие сходом не считаются
+		if r.PositionText == "R" || r.PositionText == "N" {
+			st.dnfs++
+		}
+		return
+	}
+	st.finishes++
+	st.total += position
+	switch {
+	case st.bestCount == 0 || position < st.best:
+		st.best, st.bestCount = position, 1
+	case position == st.best:
+		st.bestCount++
+	}
+}
+
+// formatHeadToHead — таблица сравнения: показатель, первый и второй гонщик
+func formatHeadToHead(a, b models.Driver, sa, sb h2hStats, season int) string {
+	message := new(strings.Builder)
+	fmt.Fprintf(message, "⚔️ %s %s — %s %s, сезон %d\n", a.GivenName, a.FamilyName, b.GivenName, b.FamilyName, season)
+
+	w := tabwriter.NewWriter(message, 2, 5, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "\t%s\t%s\t\n", driverCode(a), driverCode(b))
+	fmt.Fprintf(w, "Квалификации\t%d\t%d\t\n", sa.quali, sb.quali)
+	fmt.Fprintf(w, "Гонки\t%d\t%d\t\n", sa.race, sb.race)
+	fmt.Fprintf(w, "Очки в гонках\t%s\t%s\t\n", formatPoints(sa.points), formatPoints(sb.points))
+	fmt.Fprintf(w, "Средний финиш\t%s\t%s\t\n", sa.averageFinish(), sb.averageFinish())
+	fmt.Fprintf(w, "Сходы\t%d\t%d\t\n", sa.dnfs, sb.dnfs)
+	fmt.Fprintf(w, "Лучший результат\t%s\t%s\t\n", sa.bestResult(), sb.bestResult())
+	w.Flush()
+
+	message.WriteString("\nКвалификации и гонки — в скольких этапах гонщик был выше соперника.")
+	return message.String()
+}
+
+// averageFinish — среднее место в классифицированных финишах: "4.3"
+func (st h2hStats) averageFinish() string {
+	if st.finishes == 0 {
+		return "—"
+	}
+	return strconv.FormatFloat(float64(st.total)/float64(st.finishes), 'f', 1, 64)
+}
+
+// bestResult — лучшее место и сколько раз оно занято: "1 ×9"
+func (st h2hStats) bestResult() string {
+	if st.bestCount == 0 {
+		return "—"
+	}
+	if st.bestCount == 1 {
+		return strconv.Itoa(st.best)
+	}
+	return fmt.Sprintf("%d ×%d", st.best, st.bestCount)
+}
+
+// formatPoints — очки без лишних нулей: "399", "12.5"
+func formatPoints(points float64) string {
+	return strconv.FormatFloat(points, 'f', -1, 64)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"errors"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"testing"
+	"time"
+)
+
+func TestParseLeaderboardScope(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	yekaterinburg, err := time.LoadLocation("Asia/Yekaterinburg")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	// Границы периода — полночь в часовом поясе пользователя
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, yekaterinburg)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    models.LeaderboardScope
+		wantErr error
+	}{
+		{"текущий сезон", nil, models.LeaderboardScope{Season: 2025}, nil},
+		{"сезон", []string{"2024"}, models.LeaderboardScope{Season: 2024}, nil},
+		{"за всё время", []string{"всё"}, models.LeaderboardScope{}, nil},
+		{"all", []string{"ALL"}, models.LeaderboardScope{}, nil},
+		{"чат", []string{"чат"}, models.LeaderboardScope{Season: 2025, Platform: models.PlatformVK, ChatID: 2000000001}, nil},
+		{"чат за всё время", []string{"чат", "все"}, models.LeaderboardScope{Platform: models.PlatformVK, ChatID: 2000000001}, nil},
+		{"период", []string{"2025-03-01", "30.06.2025"}, models.LeaderboardScope{From: day(2025, 3, 1), To: day(2025, 7, 1)}, nil},
+		{"с даты", []string{"01.05.2025"}, models.LeaderboardScope{From: day(2025, 5, 1)}, nil},
+		{"период в сезоне", []string{"2024", "2024-03-01", "2024-06-30"}, models.LeaderboardScope{Season: 2024, From: day(2024, 3, 1), To: day(2024, 7, 1)}, nil},
+		{"первый сезон", []string{"1950"}, models.LeaderboardScope{Season: 1950}, nil},
+		{"сезон до 1950", []string{"1949"}, models.LeaderboardScope{}, temperrors.ErrSeasonOutOfRange},
+		{"будущий сезон", []string{"2026"}, models.LeaderboardScope{}, temperrors.ErrSeasonOutOfRange},
+		{"конец раньше начала", []string{"2025-06-30", "2025-03-01"}, models.LeaderboardScope{}, temperrors.ErrParse},
+		{"три даты", []string{"2025-03-01", "2025-04-01", "2025-05-01"}, models.LeaderboardScope{}, temperrors.ErrParse},
+		{"неизвестное слово", []string{"топ"}, models.LeaderboardScope{}, temperrors.ErrParse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLeaderboardScope(tt.args, models.PlatformVK, 2000000001, now, yekaterinburg)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseLeaderboardScope(%q) error = %v, want %v", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLeaderboardScope(%q) error = %v", tt.args, err)
+			}
+			if got.Season != tt.want.Season || got.Platform != tt.want.Platform || got.ChatID != tt.want.ChatID ||
+				!got.From.Equal(tt.want.From) || !got.To.Equal(tt.want.To) {
+				t.Errorf("ParseLeaderboardScope(%q) = %+v, want %+v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
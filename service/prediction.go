@@ -1,96 +1,383 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"racebot-vk/models"
 	predStorage "racebot-vk/storage/prediction"
+	"racebot-vk/temperrors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Момент автоматического закрытия приёма прогнозов
+const (
+	CloseAtQualifying = "qualifying"
+	CloseAtRace       = "race"
 )
 
 type PredictionService struct {
-	storage *predStorage.Storage
+	storage        *predStorage.Storage
+	drivers        *DriverResolver
+	closeAt        string
+	profileSources map[string]UserProfileSource
 }
 
-func NewPredictionService(storage *predStorage.Storage) *PredictionService {
-	return &PredictionService{storage: storage}
+func NewPredictionService(storage *predStorage.Storage, drivers *DriverResolver, closeAt string) *PredictionService {
+	if closeAt != CloseAtRace {
+		closeAt = CloseAtQualifying
+	}
+	return &PredictionService{storage: storage, drivers: drivers, closeAt: closeAt}
 }
 
-// StartPrediction открывает конкурс прогнозов на указанную гонку
-func (s *PredictionService) StartPrediction(raceID, raceName string) error {
-	// Проверяем, нет ли уже активного раунда
-	activeRace, err := s.storage.GetActiveRace()
+// StartPrediction открывает конкурс прогнозов на указанную гонку календаря.
+// Дедлайн приёма прогнозов — старт квалификации или гонки (в зависимости от настройки).
+// scoring — имя правила подсчёта очков (пустая строка — правило по умолчанию).
+// chatID = 0 открывает общий раунд для всех чатов, иначе — раунд только для чата platform/chatID.
+// Раунд на гонку не открывается повторно (temperrors.ErrRoundAlreadyOpen): общий раунд
+// пересекается с любым раундом гонки, раунд чата — с общим и с раундом того же чата.
+func (s *PredictionService) StartPrediction(race models.Race, scoring, platform string, chatID int64) (*models.PredictionRace, error) {
+	if race.Season == "" || race.Round == "" {
+		return nil, errors.New("нет предстоящих гонок")
+	}
+
+	rule, err := GetScoringRule(scoring)
+	if err != nil {
+		return nil, err
+	}
+
+	raceID := race.Season + "_" + race.Round
+	if chatID == 0 {
+		platform = ""
+	}
+
+	// Проверяем, нет ли уже пересекающегося раунда на эту гонку
+	rounds, err := s.storage.GetRaceRounds(raceID)
 	if err != nil {
-		return fmt.Errorf("failed to check active race: %w", err)
+		return nil, fmt.Errorf("failed to check race rounds: %w", err)
 	}
-	if activeRace != nil {
-		return fmt.Errorf("прогноз уже активен для гонки: %s", activeRace.RaceName)
+	for _, r := range rounds {
+		sameKey := r.Platform == platform && r.ChatID == chatID
+		overlaps := chatID == 0 || r.ChatID == 0 || sameKey
+		if sameKey || (r.IsActive && overlaps) {
+			return nil, fmt.Errorf("round #%d for %s: %w", r.ID, raceID, temperrors.ErrRoundAlreadyOpen)
+		}
 	}
 
-	race := &models.PredictionRace{
+	predRace := &models.PredictionRace{
 		RaceID:   raceID,
-		RaceName: raceName,
+		RaceName: race.RaceName,
+		Platform: platform,
+		ChatID:   chatID,
 		IsActive: true,
+		Scoring:  rule.Name(),
+		IsSprint: race.Sprint.Date != "",
+	}
+
+	predRace.RaceStart, predRace.ClosesAt = s.roundTimes(race)
+	if qualStart, err := parseStringToTime(race.Qualifying.Date, race.Qualifying.Time); err == nil {
+		predRace.QualifyingStart = &qualStart
+	}
+	if sprintStart, err := parseStringToTime(race.Sprint.Date, race.Sprint.Time); err == nil {
+		predRace.SprintStart = &sprintStart
+	}
+
+	if err := s.storage.CreateRace(predRace); err != nil {
+		return nil, err
 	}
+	return predRace, nil
+}
+
+// RaceDeadline возвращает дедлайн приёма прогнозов, который получит раунд на гонку календаря
+// (nil, если время гонки неизвестно)
+func (s *PredictionService) RaceDeadline(race models.Race) *time.Time {
+	raceStart, closesAt := s.roundTimes(race)
+	round := models.PredictionRace{RaceStart: raceStart, ClosesAt: closesAt}
+	return round.Deadline()
+}
+
+// roundTimes возвращает старт гонки и время закрытия приёма прогнозов по настройке closeAt
+func (s *PredictionService) roundTimes(race models.Race) (raceStart, closesAt *time.Time) {
+	if start, err := parseStringToTime(race.Date, race.Time); err == nil {
+		raceStart = &start
+	}
+	closesAt = raceStart
+	if s.closeAt == CloseAtQualifying {
+		if qualStart, err := parseStringToTime(race.Qualifying.Date, race.Qualifying.Time); err == nil {
+			closesAt = &qualStart
+		}
+	}
+	return raceStart, closesAt
+}
 
-	return s.storage.CreateRace(race)
+// ClosePrediction закрывает приём прогнозов в раунде
+func (s *PredictionService) ClosePrediction(roundID int) error {
+	return s.storage.CloseRace(roundID)
 }
 
-// ClosePrediction закрывает приём прогнозов
-func (s *PredictionService) ClosePrediction(raceID string) error {
-	return s.storage.CloseRace(raceID)
+// SetRaceResult сохраняет реальные результаты гонки во все её закрытые раунды.
+// classification — полный финишный порядок (nil при ручном вводе подиума).
+func (s *PredictionService) SetRaceResult(raceID string, d1, d2, d3 uint8, classification []uint8) error {
+	return s.storage.SetRaceResults(raceID, d1, d2, d3, classification)
 }
 
-// SetRaceResult сохраняет реальные результаты гонки
-func (s *PredictionService) SetRaceResult(raceID string, d1, d2, d3 uint8) error {
-	return s.storage.SetRaceResults(raceID, d1, d2, d3)
+// SetRaceScoring меняет правило подсчёта очков для раунда.
+// Очки пересчитываются при следующем подведении итогов.
+func (s *PredictionService) SetRaceScoring(roundID int, scoring string) (ScoringRule, error) {
+	rule, err := GetScoringRule(scoring)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storage.SetRaceScoring(roundID, rule.Name()); err != nil {
+		return nil, err
+	}
+	return rule, nil
 }
 
-// SubmitPrediction сохраняет прогноз пользователя
-func (s *PredictionService) SubmitPrediction(userID int, raceID string, d1, d2, d3 uint8) error {
+// SubmitPrediction сохраняет прогноз пользователя указанной платформы.
+// После дедлайна раунда прогноз отклоняется с temperrors.ErrPredictionClosed,
+// даже если раунд ещё не закрыт вручную или планировщиком.
+// chatID — чат, из которого отправлен прогноз (для рейтинга чата); раунд другого чата
+// в нём недоступен (temperrors.ErrRoundNotFound).
+// Гонщики должны быть разными и выступать в сезоне раунда (см. validateDrivers).
+func (s *PredictionService) SubmitPrediction(platform string, userID int, chatID int64, roundID int, d1, d2, d3 uint8) error {
+	race, err := s.openRound(roundID)
+	if err != nil {
+		return err
+	}
+	if !isRoundVisible(race, platform, chatID) {
+		return temperrors.ErrRoundNotFound
+	}
+	if err := s.validateDrivers(race.RaceID, d1, d2, d3); err != nil {
+		return err
+	}
+
 	pred := &models.Prediction{
-		UserID:  userID,
-		RaceID:  raceID,
-		Driver1: d1,
-		Driver2: d2,
-		Driver3: d3,
+		Platform: platform,
+		UserID:   userID,
+		RaceID:   race.RaceID,
+		RoundID:  race.ID,
+		ChatID:   chatID,
+		Driver1:  d1,
+		Driver2:  d2,
+		Driver3:  d3,
 	}
+
+	// Ответы на дополнительные вопросы при правке подиума сохраняются
+	existing, err := s.storage.GetUserPrediction(platform, userID, race.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get existing prediction: %w", err)
+	}
+	if existing != nil {
+		pred.Extras = existing.Extras
+	}
+
 	return s.storage.SavePrediction(pred)
 }
 
-// CalculateResults подсчитывает очки для всех прогнозов на указанную гонку
-func (s *PredictionService) CalculateResults(raceID string) ([]models.PredictionResult, error) {
+// openRound возвращает раунд, открытый для прогнозов, или temperrors.ErrPredictionClosed
+func (s *PredictionService) openRound(roundID int) (*models.PredictionRace, error) {
+	race, err := s.storage.GetRound(roundID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get round: %w", err)
+	}
+	if race == nil || !race.IsActive {
+		return nil, temperrors.ErrPredictionClosed
+	}
+	if deadline := race.Deadline(); deadline != nil && !time.Now().Before(*deadline) {
+		return nil, temperrors.ErrPredictionClosed
+	}
+	return race, nil
+}
+
+// validateDrivers проверяет гонщиков прогноза по заявке сезона раунда raceID.
+// Возвращает *DuplicateDriverError или *DriverNotOnGridError.
+func (s *PredictionService) validateDrivers(raceID string, numbers ...uint8) error {
+	season, _, err := parseRaceID(raceID)
+	if err != nil {
+		return err
+	}
+	return s.drivers.Validate(season, numbers...)
+}
+
+// QuestionClosedError — приём ответов на дополнительный вопрос закрыт раньше дедлайна раунда:
+// вопрос о поуле — со стартом квалификации, подиум спринта — со стартом спринта
+type QuestionClosedError struct {
+	Question string    // "поул", "подиум спринта"
+	Session  string    // сессия, со стартом которой закрылся вопрос
+	Deadline time.Time // старт сессии
+}
+
+func (e *QuestionClosedError) Error() string {
+	return fmt.Sprintf("question %q closed at %s", e.Question, e.Deadline.Format(time.RFC3339))
+}
+
+func (e *QuestionClosedError) Unwrap() error {
+	return temperrors.ErrQuestionClosed
+}
+
+// checkQuestionDeadlines проверяет, что на заданные вопросы ещё принимаются ответы.
+// Быстрый круг, первый сход и команда победителя ограничены дедлайном раунда (проверяется
+// в openRound), поул — стартом квалификации, подиум спринта — стартом спринта.
+// В раундах, открытых до появления времени сессий, действует только дедлайн раунда.
+func checkQuestionDeadlines(race *models.PredictionRace, extras models.PredictionExtras, now time.Time) error {
+	if extras.Pole != 0 && race.QualifyingStart != nil && !now.Before(*race.QualifyingStart) {
+		return &QuestionClosedError{Question: "поул", Session: "квалификации", Deadline: *race.QualifyingStart}
+	}
+	if len(extras.SprintPodium) > 0 && race.SprintStart != nil && !now.Before(*race.SprintStart) {
+		return &QuestionClosedError{Question: "подиум спринта", Session: "спринта", Deadline: *race.SprintStart}
+	}
+	return nil
+}
+
+// SubmitExtras добавляет ответы на дополнительные вопросы к прогнозу пользователя.
+// Пропущенные вопросы сохраняют прежние ответы. Прогноз на подиум должен быть отправлен
+// заранее (иначе temperrors.ErrPredictionNotFound), подиум спринта принимается только
+// на спринт-уикенде (иначе temperrors.ErrNotSprintWeekend). Ответ на вопрос, сессия
+// которого уже началась, не принимается (*QuestionClosedError).
+func (s *PredictionService) SubmitExtras(platform string, userID int, roundID int, extras models.PredictionExtras) (*models.Prediction, error) {
+	race, err := s.openRound(roundID)
+	if err != nil {
+		return nil, err
+	}
+	if len(extras.SprintPodium) > 0 && !race.IsSprint {
+		return nil, temperrors.ErrNotSprintWeekend
+	}
+	if err := checkQuestionDeadlines(race, extras, time.Now()); err != nil {
+		return nil, err
+	}
+	// Поул, быстрый круг и сход — разные вопросы, один гонщик может быть ответом на все
+	for _, num := range []uint8{extras.Pole, extras.FastestLap, extras.FirstDNF} {
+		if err := s.validateDrivers(race.RaceID, num); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.validateDrivers(race.RaceID, extras.SprintPodium...); err != nil {
+		return nil, err
+	}
+
+	pred, err := s.storage.GetUserPrediction(platform, userID, race.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prediction: %w", err)
+	}
+	if pred == nil {
+		return nil, temperrors.ErrPredictionNotFound
+	}
+
+	pred.Extras = pred.Extras.Merge(extras)
+	if err := s.storage.SavePredictionExtras(pred); err != nil {
+		return nil, err
+	}
+	return pred, nil
+}
+
+// GetRaceGrid возвращает гонщиков сезона раунда raceID по возрастанию номера —
+// для выбора подиума кнопками
+func (s *PredictionService) GetRaceGrid(raceID string) ([]GridDriver, error) {
+	season, _, err := parseRaceID(raceID)
+	if err != nil {
+		return nil, err
+	}
+	grid, err := s.drivers.Grid(season)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(grid, func(i, j int) bool { return grid[i].Number < grid[j].Number })
+	return grid, nil
+}
+
+// GetUserPrediction возвращает прогноз пользователя в раунде (nil, если прогноза нет)
+func (s *PredictionService) GetUserPrediction(platform string, userID int, roundID int) (*models.Prediction, error) {
+	return s.storage.GetUserPrediction(platform, userID, roundID)
+}
+
+// CalculateResults подсчитывает очки для всех прогнозов раунда
+// по правилу, сохранённому в раунде (повторный вызов пересчитывает очки)
+func (s *PredictionService) CalculateResults(roundID int) ([]models.PredictionResult, error) {
 	// Получаем результаты гонки
-	race, err := s.storage.GetRaceByID(raceID)
+	race, err := s.storage.GetRound(roundID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get race: %w", err)
+		return nil, fmt.Errorf("failed to get round: %w", err)
 	}
 	if race == nil {
-		return nil, fmt.Errorf("гонка %s не найдена", raceID)
+		return nil, fmt.Errorf("раунд #%d не найден", roundID)
 	}
+
+	results, err := s.GetRoundResults(race)
+	if err != nil {
+		return nil, err
+	}
+
+	// Обновляем очки в БД
+	for _, r := range results {
+		if err := s.storage.UpdatePredictionPoints(r.Prediction.ID, r.Points); err != nil {
+			return nil, fmt.Errorf("failed to update points: %w", err)
+		}
+	}
+	return results, nil
+}
+
+// GetRoundResults подсчитывает очки прогнозов раунда по сохранённым в нём результатам гонки,
+// не обновляя очки в БД
+func (s *PredictionService) GetRoundResults(race *models.PredictionRace) ([]models.PredictionResult, error) {
 	if race.Driver1 == nil || race.Driver2 == nil || race.Driver3 == nil {
-		return nil, fmt.Errorf("результаты гонки %s ещё не установлены", raceID)
+		return nil, fmt.Errorf("результаты гонки %s ещё не установлены", race.RaceID)
 	}
+	outcome := RaceOutcome{Podium: []uint8{*race.Driver1, *race.Driver2, *race.Driver3}, Classification: race.Classification, Extras: race.Extras}
+	return s.scoreRound(race, outcome)
+}
 
-	// Получаем все прогнозы на эту гонку
-	predictions, err := s.storage.GetRacePredictions(raceID)
+// SettleRound подводит итоги закрытого раунда по результатам гонки outcome: результаты,
+// ответы на дополнительные вопросы и очки прогнозов сохраняются в одной транзакции.
+// При ошибке раунд остаётся без результатов, и его можно подвести повторно; при успехе
+// результаты записываются и в race — для сообщения с итогами.
+func (s *PredictionService) SettleRound(race *models.PredictionRace, outcome RaceOutcome) ([]models.PredictionResult, error) {
+	results, err := s.scoreRound(race, outcome)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get predictions: %w", err)
+		return nil, err
+	}
+
+	points := make(map[int]int, len(results))
+	for _, r := range results {
+		points[r.Prediction.ID] = r.Points
+	}
+	if err := s.storage.SettleRound(race.ID, outcome.Podium, outcome.Classification, outcome.Extras, points); err != nil {
+		return nil, err
+	}
+
+	d1, d2, d3 := outcome.Podium[0], outcome.Podium[1], outcome.Podium[2]
+	race.Driver1, race.Driver2, race.Driver3 = &d1, &d2, &d3
+	race.Classification, race.Extras = outcome.Classification, outcome.Extras
+	return results, nil
+}
+
+// scoreRound подсчитывает очки прогнозов раунда по правилу раунда, не сохраняя их
+func (s *PredictionService) scoreRound(race *models.PredictionRace, outcome RaceOutcome) ([]models.PredictionResult, error) {
+	rule, err := GetScoringRule(race.Scoring)
+	if err != nil {
+		return nil, err
 	}
 
-	realResults := []uint8{*race.Driver1, *race.Driver2, *race.Driver3}
+	// Получаем все прогнозы раунда
+	predictions, err := s.storage.GetRoundPredictions(race.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get predictions: %w", err)
+	}
 
 	var results []models.PredictionResult
 	for _, pred := range predictions {
-		predPoints := calculatePoints(pred, realResults)
+		predPoints := rule.Score(pred, outcome)
 		pred.Points = predPoints
 
-		// Обновляем очки в БД
-		if err := s.storage.UpdatePredictionPoints(pred.ID, predPoints); err != nil {
-			return nil, fmt.Errorf("failed to update points: %w", err)
+		details := formatPredictionDetails(pred, outcome.Podium)
+		if !pred.Extras.IsEmpty() {
+			details += "; " + formatExtrasDetails(pred.Extras, outcome.Extras)
 		}
 
-		details := formatPredictionDetails(pred, realResults)
-
 		results = append(results, models.PredictionResult{
+			Platform:   pred.Platform,
 			UserID:     pred.UserID,
 			Prediction: pred,
 			Points:     predPoints,
@@ -98,32 +385,40 @@ func (s *PredictionService) CalculateResults(raceID string) ([]models.Prediction
 		})
 	}
 
+	sortPredictionResults(results)
 	return results, nil
 }
 
-// GetActiveRace возвращает активный раунд прогнозов
-func (s *PredictionService) GetActiveRace() (*models.PredictionRace, error) {
-	return s.storage.GetActiveRace()
+// sortPredictionResults упорядочивает итоги раунда по только что подсчитанным очкам,
+// при равенстве — по времени прогноза: кто раньше прогнозировал, тот выше.
+// Порядок из БД не подходит — там очки ещё до пересчёта.
+func sortPredictionResults(results []models.PredictionResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Points != results[j].Points {
+			return results[i].Points > results[j].Points
+		}
+		return results[i].Prediction.CreatedAt.Before(results[j].Prediction.CreatedAt)
+	})
 }
 
-// GetRaceByID возвращает раунд прогнозов по race_id
-func (s *PredictionService) GetRaceByID(raceID string) (*models.PredictionRace, error) {
-	return s.storage.GetRaceByID(raceID)
+// GetLeaderboard возвращает таблицу лидеров в указанной области
+func (s *PredictionService) GetLeaderboard(scope models.LeaderboardScope) ([]models.UserStats, error) {
+	return s.storage.GetLeaderboard(scope)
 }
 
-// GetLeaderboard возвращает таблицу лидеров
-func (s *PredictionService) GetLeaderboard() ([]models.UserStats, error) {
-	return s.storage.GetLeaderboard()
+// GetUserStats возвращает статистику пользователя
+func (s *PredictionService) GetUserStats(platform string, userID int) (*models.UserStats, error) {
+	return s.storage.GetUserStats(platform, userID)
 }
 
-// GetUserStats возвращает статистику пользователя
-func (s *PredictionService) GetUserStats(userID int) (*models.UserStats, error) {
-	return s.storage.GetUserStats(userID)
+// GetRaceRounds возвращает все раунды на гонку race_id
+func (s *PredictionService) GetRaceRounds(raceID string) ([]models.PredictionRace, error) {
+	return s.storage.GetRaceRounds(raceID)
 }
 
-// GetRacePredictions возвращает все прогнозы на гонку
-func (s *PredictionService) GetRacePredictions(raceID string) ([]models.Prediction, error) {
-	return s.storage.GetRacePredictions(raceID)
+// GetRoundPredictions возвращает все прогнозы раунда
+func (s *PredictionService) GetRoundPredictions(roundID int) ([]models.Prediction, error) {
+	return s.storage.GetRoundPredictions(roundID)
 }
 
 // GetAllRaces возвращает все раунды прогнозов
@@ -131,28 +426,448 @@ func (s *PredictionService) GetAllRaces() ([]models.PredictionRace, error) {
 	return s.storage.GetAllRaces()
 }
 
+// GetRaceAwaitingResults возвращает последний закрытый раунд чата без результатов
+func (s *PredictionService) GetRaceAwaitingResults(platform string, chatID int64) (*models.PredictionRace, error) {
+	allRaces, err := s.storage.GetChatRaces(platform, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat races: %w", err)
+	}
+
+	for i := range allRaces {
+		r := &allRaces[i]
+		if !r.IsActive && r.Driver1 == nil {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetRaceAwaitingSummary возвращает последний закрытый раунд чата с результатами
+func (s *PredictionService) GetRaceAwaitingSummary(platform string, chatID int64) (*models.PredictionRace, error) {
+	allRaces, err := s.storage.GetChatRaces(platform, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat races: %w", err)
+	}
+
+	for i := range allRaces {
+		r := &allRaces[i]
+		if !r.IsActive && r.Driver1 != nil {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetUserHistoryMessage формирует историю отправок и правок прогноза пользователя на гонку,
+// время указывается в часовом поясе loc
+func (s *PredictionService) GetUserHistoryMessage(platform string, userID int, race *models.PredictionRace, loc *time.Location) (string, error) {
+	history, err := s.storage.GetPredictionHistory(platform, userID, race.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(history) == 0 {
+		return fmt.Sprintf("Вы не отправляли прогноз на гонку '%s'.", race.RaceName), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🕓 История вашего прогноза на гонку '%s':\n\n", race.RaceName))
+	for _, h := range history {
+		action := "отправлен"
+		if h.Action == models.PredictionActionEdit {
+			action = "изменён"
+		}
+		if h.Action == models.PredictionActionExtras {
+			sb.WriteString(fmt.Sprintf("%s — доп. вопросы: %s\n", FormatZonedTime(h.CreatedAt, loc), FormatExtras(h.Extras)))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s — %s: 1. №%d, 2. №%d, 3. №%d\n",
+			FormatZonedTime(h.CreatedAt, loc), action, h.Driver1, h.Driver2, h.Driver3))
+	}
+
+	return sb.String(), nil
+}
+
+// GetRaceAuditMessage формирует для администратора список прогнозов на гонку
+// с временем отправки, последнего изменения и количеством правок в часовом поясе loc
+func (s *PredictionService) GetRaceAuditMessage(race *models.PredictionRace, loc *time.Location) (string, error) {
+	predictions, err := s.storage.GetRoundPredictions(race.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(predictions) == 0 {
+		return fmt.Sprintf("На гонку '%s' пока нет прогнозов.", race.RaceName), nil
+	}
+
+	edits, err := s.storage.CountPredictionEdits(race.ID)
+	if err != nil {
+		return "", err
+	}
+
+	profiles, err := s.userNames()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📋 Прогнозы на гонку '%s':\n", race.RaceName))
+	if deadline := race.Deadline(); deadline != nil {
+		sb.WriteString(fmt.Sprintf("Дедлайн: %s\n", FormatZonedTime(*deadline, loc)))
+	}
+	sb.WriteString("\n")
+
+	for _, p := range predictions {
+		sb.WriteString(fmt.Sprintf("%s: 1. №%d, 2. №%d, 3. №%d\n", displayName(profiles, p.Platform, p.UserID), p.Driver1, p.Driver2, p.Driver3))
+		if !p.Extras.IsEmpty() {
+			sb.WriteString(fmt.Sprintf("   Доп. вопросы: %s\n", FormatExtras(p.Extras)))
+		}
+		sb.WriteString(fmt.Sprintf("   Отправлен: %s, изменён: %s (%s), правок: %d\n",
+			formatLocalTime(p.CreatedAt, loc), formatLocalTime(p.UpdatedAt, loc), zoneLabel(p.UpdatedAt, loc), edits[fmt.Sprintf("%s:%d", p.Platform, p.UserID)]))
+	}
+
+	return sb.String(), nil
+}
+
+// GetAnnouncementMessage формирует объявление об открытии конкурса прогнозов,
+// дедлайн указывается в часовом поясе чата loc
+func (s *PredictionService) GetAnnouncementMessage(race *models.PredictionRace, loc *time.Location) string {
+	msg := fmt.Sprintf("Открываем конкурс прогнозов на гонку '%s'! Укажите номера гонщиков, которые по вашему мнению займут первые 3 места по итогам гонки.\n\nVK: мойпрогноз №_топ1 №_топ2 №_топ3 (или просто «мойпрогноз» — выбор кнопками), либо ответьте на это сообщение номерами\nTelegram: /predict №_топ1 №_топ2 №_топ3 (или просто /predict — выбор кнопками)\n\nПример: 23 17 29", race.RaceName)
+	msg += "\n\nДополнительные вопросы (необязательно, после прогноза на подиум):\nVK: допрогноз поул=№ круг=№ сход=№ команда=название"
+	msg += "\nTelegram: /predictextra pole=№ fastest=№ dnf=№ team=название"
+	if race.IsSprint {
+		msg += "\nЭто спринт-уикенд — можно угадать и подиум спринта: спринт=№,№,№ (sprint=№,№,№)"
+	}
+	if rule, err := GetScoringRule(race.Scoring); err == nil {
+		msg += fmt.Sprintf("\n\nПравило подсчёта очков: %s — %s.", rule.Name(), rule.Description())
+	}
+	if deadline := race.Deadline(); deadline != nil {
+		msg += fmt.Sprintf("\n\nПриём прогнозов до %s.", FormatZonedTime(*deadline, loc))
+	}
+	msg += fmt.Sprintf("\n\nНомер этапа: #%s. Если открыто несколько конкурсов, укажите его перед гонщиками: мойпрогноз #%s ... (/predict #%s ...)", race.Round(), race.Round(), race.Round())
+	return msg
+}
+
+// GetSummaryMessage формирует сообщение с итогами конкурса прогнозов на гонку
+func (s *PredictionService) GetSummaryMessage(race *models.PredictionRace, results []models.PredictionResult) string {
+	if len(results) == 0 {
+		return fmt.Sprintf("На гонку '%s' не было прогнозов.", race.RaceName)
+	}
+
+	// Без кэша имён итоги всё равно публикуются — с ID пользователей
+	profiles, _ := s.userNames()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🏁 Итоги конкурса прогнозов на гонку '%s'\n", race.RaceName))
+	sb.WriteString(fmt.Sprintf("Правило подсчёта очков: %s\n\n", race.Scoring))
+	sb.WriteString(fmt.Sprintf("Результаты гонки: 1. №%d, 2. №%d, 3. №%d\n", *race.Driver1, *race.Driver2, *race.Driver3))
+	if !race.Extras.IsEmpty() {
+		sb.WriteString(fmt.Sprintf("Дополнительные вопросы: %s\n", FormatExtras(race.Extras)))
+	}
+	sb.WriteString("\n")
+
+	for i, r := range results {
+		place := i + 1
+		sb.WriteString(fmt.Sprintf("%d. %s\n", place, displayName(profiles, r.Platform, r.UserID)))
+		sb.WriteString(fmt.Sprintf("   Прогноз: %s\n", r.Details))
+		sb.WriteString(fmt.Sprintf("   Очки: %d\n\n", r.Points))
+	}
+
+	return sb.String()
+}
+
+// GetLeaderboardMessage формирует таблицу лидеров прогнозов в указанной области
+func (s *PredictionService) GetLeaderboardMessage(scope models.LeaderboardScope) (string, error) {
+	leaderboard, err := s.storage.GetLeaderboard(scope)
+	if err != nil {
+		return "", err
+	}
+
+	if len(leaderboard) == 0 {
+		return fmt.Sprintf("Таблица лидеров (%s) пока пуста.", describeScope(scope)), nil
+	}
+
+	profiles, err := s.userNames()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🏆 Таблица лидеров прогнозов (%s):\n\n", describeScope(scope)))
+	for i, st := range leaderboard {
+		place := i + 1
+		sb.WriteString(fmt.Sprintf("%d. %s — %d очков (%d гонок, среднее: %.1f)\n",
+			place, displayName(profiles, st.Platform, st.UserID), st.TotalPoints, st.TotalRaces, st.AvgPoints))
+	}
+
+	return sb.String(), nil
+}
+
+// GetUserStatsMessage формирует персональную статистику пользователя
+func (s *PredictionService) GetUserStatsMessage(platform string, userID int) (string, error) {
+	stats, err := s.storage.GetUserStats(platform, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if stats.TotalRaces == 0 {
+		return "У вас пока нет прогнозов.", nil
+	}
+
+	profiles, err := s.userNames()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("📊 Статистика прогнозов — %s:\n\nВсего очков: %d\nУчастий в гонках: %d\nСреднее очков: %.1f\nЛучший результат: %d (гонка: %s)",
+		displayName(profiles, platform, userID), stats.TotalPoints, stats.TotalRaces, stats.AvgPoints, stats.BestPoints, stats.BestRaceID), nil
+}
+
 // --- Вспомогательные функции ---
 
-// calculatePoints рассчитывает очки за прогноз
-// Правила:
-// - Точное попадание на позицию: 5 очков
-// - Гонщик из прогноза попал в топ-3, но не на своей позиции: 3 очка
-// - Гонщик из прогноза не попал в топ-3: 0 очков
-func calculatePoints(pred models.Prediction, realResults []uint8) int {
-	points := 0
-	predDrivers := []uint8{pred.Driver1, pred.Driver2, pred.Driver3}
+// ParseLeaderboardScope разбирает аргументы команды рейтинга:
+//
+//	(без аргументов) — текущий сезон
+//	2024             — сезон 2024
+//	чат / chat       — только прогнозы из текущего чата (platform, chatID)
+//	всё / все / all  — за всё время
+//	2025-03-01 2025-06-30 (или 01.03.2025 30.06.2025) — гонки в периоде, конец включительно
+//
+// Аргументы можно комбинировать: "чат 2024", "чат всё". Даты периода отсчитываются в часовом
+// поясе loc, сезон проверяется ValidateSeason (temperrors.ErrSeasonOutOfRange).
+func ParseLeaderboardScope(args []string, platform string, chatID int64, now time.Time, loc *time.Location) (models.LeaderboardScope, error) {
+	scope := models.LeaderboardScope{Season: now.Year()}
+	seasonSet := false
+	var dates []time.Time
 
-	for i, predicted := range predDrivers {
-		if predicted == realResults[i] {
-			// Точное попадание
-			points += 5
-		} else if contains(realResults, predicted) {
-			// Гонщик в топ-3, но не на своей позиции
-			points += 3
+	for _, arg := range args {
+		arg = strings.ToLower(arg)
+		switch arg {
+		case "чат", "chat":
+			scope.Platform = platform
+			scope.ChatID = chatID
+			continue
+		case "всё", "все", "all":
+			scope.Season = 0
+			seasonSet = true
+			continue
 		}
+
+		if year, err := strconv.Atoi(arg); err == nil {
+			if err := ValidateSeason(year, now); err != nil {
+				return scope, err
+			}
+			scope.Season = year
+			seasonSet = true
+			continue
+		}
+
+		date, err := parseScopeDate(arg, loc)
+		if err != nil {
+			return scope, err
+		}
+		dates = append(dates, date)
+	}
+
+	switch len(dates) {
+	case 0:
+	case 1, 2:
+		scope.From = dates[0]
+		if len(dates) == 2 {
+			if dates[1].Before(dates[0]) {
+				return scope, fmt.Errorf("period end before start: %w", temperrors.ErrParse)
+			}
+			scope.To = dates[1].AddDate(0, 0, 1)
+		}
+		if !seasonSet {
+			scope.Season = 0
+		}
+	default:
+		return scope, fmt.Errorf("too many dates: %w", temperrors.ErrParse)
+	}
+
+	return scope, nil
+}
+
+// parseScopeDate разбирает дату в формате 2025-03-01 или 01.03.2025 — начало дня в поясе loc
+func parseScopeDate(str string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "02.01.2006"} {
+		if date, err := time.ParseInLocation(layout, str, loc); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid leaderboard argument %q: %w", str, temperrors.ErrParse)
+}
+
+// describeScope описывает область таблицы лидеров для заголовка
+func describeScope(scope models.LeaderboardScope) string {
+	var parts []string
+	if scope.Season != 0 {
+		parts = append(parts, fmt.Sprintf("сезон %d", scope.Season))
+	}
+	if !scope.From.IsZero() && !scope.To.IsZero() {
+		parts = append(parts, fmt.Sprintf("гонки с %s по %s", scope.From.Format("02.01.2006"), scope.To.AddDate(0, 0, -1).Format("02.01.2006")))
+	} else if !scope.From.IsZero() {
+		parts = append(parts, fmt.Sprintf("гонки с %s", scope.From.Format("02.01.2006")))
+	}
+	if scope.Season == 0 && scope.From.IsZero() {
+		parts = append(parts, "за всё время")
+	}
+	if scope.ChatID != 0 {
+		parts = append(parts, "этот чат")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// LeaderboardUsage — подсказка по аргументам команды рейтинга
+const LeaderboardUsage = "Аргументы рейтинга: без аргументов — текущий сезон; год (2024) — сезон; «чат» — только прогнозы из этого чата; «всё» — за всё время; две даты (2025-03-01 2025-06-30) — гонки в периоде. Аргументы можно комбинировать: «чат всё»."
+
+// ParsePrediction разбирает подиум из трёх гонщиков для раунда raceID. Гонщика можно
+// указать номером, кодом (VER) или фамилией латиницей или кириллицей (Verstappen, Ферстаппен).
+// Нераспознанный гонщик возвращается как *UnknownDriverError.
+func (s *PredictionService) ParsePrediction(raceID, text string) (uint8, uint8, uint8, error) {
+	tokens := SplitDriverTokens(text)
+	if len(tokens) != 3 {
+		return 0, 0, 0, fmt.Errorf("prediction must contain 3 drivers, got %d: %w", len(tokens), temperrors.ErrParse)
+	}
+
+	numbers, err := s.resolveDrivers(raceID, tokens)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return numbers[0], numbers[1], numbers[2], nil
+}
+
+// resolveDrivers сопоставляет гонщиков с заявкой сезона раунда
+func (s *PredictionService) resolveDrivers(raceID string, tokens []string) ([]uint8, error) {
+	season, _, err := parseRaceID(raceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.drivers.Resolve(season, tokens)
+}
+
+// extrasKeys — названия дополнительных вопросов в командах (VK и Telegram)
+var extrasKeys = map[string]string{
+	"поул":    "pole",
+	"pole":    "pole",
+	"круг":    "fastest",
+	"fastest": "fastest",
+	"сход":    "dnf",
+	"dnf":     "dnf",
+	"команда": "team",
+	"team":    "team",
+	"спринт":  "sprint",
+	"sprint":  "sprint",
+}
+
+// ParseExtras парсит ответы на дополнительные вопросы раунда raceID в формате "ключ=значение":
+// "поул=1 круг=NOR сход=Леклер команда=ferrari спринт=1,4,81". Гонщики указываются так же, как в ParsePrediction.
+func (s *PredictionService) ParseExtras(raceID, text string) (models.PredictionExtras, error) {
+	var extras models.PredictionExtras
+	parts := strings.Fields(text)
+	if len(parts) == 0 {
+		return extras, fmt.Errorf("no extra answers: %w", temperrors.ErrParse)
+	}
+
+	for _, part := range parts {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return extras, fmt.Errorf("invalid extra answer %q: %w", part, temperrors.ErrParse)
+		}
+
+		question := extrasKeys[strings.ToLower(key)]
+		switch question {
+		case "pole", "fastest", "dnf":
+			numbers, err := s.resolveDrivers(raceID, []string{value})
+			if err != nil {
+				return extras, err
+			}
+			switch question {
+			case "pole":
+				extras.Pole = numbers[0]
+			case "fastest":
+				extras.FastestLap = numbers[0]
+			default:
+				extras.FirstDNF = numbers[0]
+			}
+		case "team":
+			extras.Constructor = strings.ToLower(value)
+		case "sprint":
+			d1, d2, d3, err := s.ParsePrediction(raceID, value)
+			if err != nil {
+				return extras, err
+			}
+			extras.SprintPodium = []uint8{d1, d2, d3}
+		default:
+			return extras, fmt.Errorf("unknown extra question %q: %w", key, temperrors.ErrParse)
+		}
+	}
+
+	return extras, nil
+}
+
+// FormatExtras формирует текстовое описание ответов на дополнительные вопросы
+func FormatExtras(extras models.PredictionExtras) string {
+	var parts []string
+	if extras.Pole != 0 {
+		parts = append(parts, fmt.Sprintf("поул №%d", extras.Pole))
+	}
+	if extras.FastestLap != 0 {
+		parts = append(parts, fmt.Sprintf("быстрый круг №%d", extras.FastestLap))
+	}
+	if extras.FirstDNF != 0 {
+		parts = append(parts, fmt.Sprintf("первый сход №%d", extras.FirstDNF))
+	}
+	if extras.Constructor != "" {
+		parts = append(parts, fmt.Sprintf("команда победителя %s", extras.Constructor))
+	}
+	if len(extras.SprintPodium) == 3 {
+		parts = append(parts, fmt.Sprintf("подиум спринта №%d, №%d, №%d", extras.SprintPodium[0], extras.SprintPodium[1], extras.SprintPodium[2]))
+	}
+	if len(parts) == 0 {
+		return "нет ответов"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatExtrasDetails отмечает угаданные (✓) и неугаданные (✗) дополнительные вопросы
+func formatExtrasDetails(pred, real models.PredictionExtras) string {
+	mark := func(ok bool) string {
+		if ok {
+			return "✓"
+		}
+		return "✗"
 	}
 
-	return points
+	var parts []string
+	if pred.Pole != 0 {
+		parts = append(parts, fmt.Sprintf("поул №%d %s", pred.Pole, mark(pred.Pole == real.Pole)))
+	}
+	if pred.FastestLap != 0 {
+		parts = append(parts, fmt.Sprintf("быстрый круг №%d %s", pred.FastestLap, mark(pred.FastestLap == real.FastestLap)))
+	}
+	if pred.FirstDNF != 0 {
+		parts = append(parts, fmt.Sprintf("первый сход №%d %s", pred.FirstDNF, mark(pred.FirstDNF == real.FirstDNF)))
+	}
+	if pred.Constructor != "" {
+		parts = append(parts, fmt.Sprintf("команда %s %s", pred.Constructor, mark(NormalizeConstructor(pred.Constructor) == NormalizeConstructor(real.Constructor))))
+	}
+	if len(pred.SprintPodium) == 3 {
+		sprint := make([]string, 0, 3)
+		for i, predicted := range pred.SprintPodium {
+			switch {
+			case i < len(real.SprintPodium) && predicted == real.SprintPodium[i]:
+				sprint = append(sprint, fmt.Sprintf("№%d ✓", predicted))
+			case contains(real.SprintPodium, predicted):
+				sprint = append(sprint, fmt.Sprintf("№%d △", predicted))
+			default:
+				sprint = append(sprint, fmt.Sprintf("№%d ✗", predicted))
+			}
+		}
+		parts = append(parts, "спринт "+strings.Join(sprint, ", "))
+	}
+	return strings.Join(parts, ", ")
 }
 
 // formatPredictionDetails формирует текстовое описание результатов прогноза
@@ -177,6 +892,19 @@ func formatPredictionDetails(pred models.Prediction, realResults []uint8) string
 	return result
 }
 
+// FormatZonedTime форматирует время в часовом поясе loc с подписью пояса: "03 мая 2025 23:00 (МСК)"
+func FormatZonedTime(t time.Time, loc *time.Location) string {
+	return fmt.Sprintf("%s (%s)", formatLocalTime(t, loc), zoneLabel(t, loc))
+}
+
+// userLabel формирует подпись пользователя в зависимости от платформы
+func userLabel(platform string, userID int) string {
+	if platform == models.PlatformTelegram {
+		return fmt.Sprintf("Пользователь Telegram %d", userID)
+	}
+	return fmt.Sprintf("Пользователь [id%d|]", userID)
+}
+
 func contains(slice []uint8, val uint8) bool {
 	for _, item := range slice {
 		if item == val {
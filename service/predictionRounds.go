@@ -0,0 +1,143 @@
+package service
+
+import (
+	"fmt"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"strings"
+	"time"
+)
+
+// Одновременно может быть открыто несколько раундов прогнозов: на соседние гонки
+// (двойные этапы) и отдельные раунды чатов. Чат видит общие раунды и свои.
+// Если открытых раундов несколько, пользователь выбирает раунд номером этапа: "#6".
+
+// AmbiguousRoundError — в чате открыто несколько раундов, а номер этапа не указан
+type AmbiguousRoundError struct {
+	Rounds []models.PredictionRace
+}
+
+func (e *AmbiguousRoundError) Error() string {
+	return fmt.Sprintf("%d prediction rounds are open", len(e.Rounds))
+}
+
+func (e *AmbiguousRoundError) Unwrap() error {
+	return temperrors.ErrAmbiguousRound
+}
+
+// SplitRoundSelector отделяет номер этапа ("#6") в начале аргументов команды
+func SplitRoundSelector(text string) (selector, rest string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "#") {
+		return "", text
+	}
+	fields := strings.SplitN(text, " ", 2)
+	selector = strings.TrimPrefix(fields[0], "#")
+	if len(fields) > 1 {
+		rest = strings.TrimSpace(fields[1])
+	}
+	return selector, rest
+}
+
+// GetActiveRounds возвращает открытые раунды, доступные в чате платформы (ближайшая гонка — первой)
+func (s *PredictionService) GetActiveRounds(platform string, chatID int64) ([]models.PredictionRace, error) {
+	rounds, err := s.storage.GetActiveRaces()
+	if err != nil {
+		return nil, err
+	}
+	active := make([]models.PredictionRace, 0, len(rounds))
+	for i := range rounds {
+		if isRoundVisible(&rounds[i], platform, chatID) {
+			active = append(active, rounds[i])
+		}
+	}
+	return active, nil
+}
+
+// GetActiveRaces возвращает все открытые раунды во всех чатах
+func (s *PredictionService) GetActiveRaces() ([]models.PredictionRace, error) {
+	return s.storage.GetActiveRaces()
+}
+
+// GetRound возвращает раунд прогнозов по id (nil, если раунда нет)
+func (s *PredictionService) GetRound(roundID int) (*models.PredictionRace, error) {
+	return s.storage.GetRound(roundID)
+}
+
+// ResolveActiveRound выбирает открытый раунд чата для прогноза.
+// selector — номер этапа ("6") или race_id ("2025_6"), пустая строка — единственный открытый раунд.
+// Возвращает nil, если открытых раундов нет, *AmbiguousRoundError — если раундов несколько
+// и этап не указан, temperrors.ErrRoundNotFound — если указанного этапа среди открытых нет.
+func (s *PredictionService) ResolveActiveRound(platform string, chatID int64, selector string) (*models.PredictionRace, error) {
+	rounds, err := s.GetActiveRounds(platform, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active rounds: %w", err)
+	}
+	return pickRound(rounds, selector)
+}
+
+// ResolveLatestRound выбирает раунд чата для просмотра (история, список прогнозов, правила):
+// открытый раунд по правилам ResolveActiveRound, а если открытых нет — последний проведённый.
+func (s *PredictionService) ResolveLatestRound(platform string, chatID int64, selector string) (*models.PredictionRace, error) {
+	rounds, err := s.storage.GetChatRaces(platform, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat rounds: %w", err)
+	}
+	if selector != "" {
+		return pickRound(rounds, selector)
+	}
+
+	active, err := s.GetActiveRounds(platform, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active rounds: %w", err)
+	}
+	if len(active) > 0 {
+		return pickRound(active, "")
+	}
+	if len(rounds) == 0 {
+		return nil, nil
+	}
+	return &rounds[0], nil
+}
+
+// pickRound выбирает раунд из списка по номеру этапа
+func pickRound(rounds []models.PredictionRace, selector string) (*models.PredictionRace, error) {
+	if selector == "" {
+		switch len(rounds) {
+		case 0:
+			return nil, nil
+		case 1:
+			return &rounds[0], nil
+		}
+		return nil, &AmbiguousRoundError{Rounds: rounds}
+	}
+
+	for i := range rounds {
+		if rounds[i].Round() == selector || rounds[i].RaceID == selector {
+			return &rounds[i], nil
+		}
+	}
+	return nil, fmt.Errorf("round %q: %w", selector, temperrors.ErrRoundNotFound)
+}
+
+// isRoundVisible проверяет, что раунд доступен в чате платформы
+func isRoundVisible(race *models.PredictionRace, platform string, chatID int64) bool {
+	return race.ChatID == 0 || (race.Platform == platform && race.ChatID == chatID)
+}
+
+// FormatRoundChoice перечисляет раунды для выбора: "#6 Miami Grand Prix — до 03 мая 2025 20:00 (МСК)",
+// дедлайны указываются в часовом поясе loc
+func FormatRoundChoice(rounds []models.PredictionRace, loc *time.Location) string {
+	var sb strings.Builder
+	for _, r := range rounds {
+		sb.WriteString(fmt.Sprintf("#%s %s", r.Round(), r.RaceName))
+		if r.ChatID != 0 {
+			sb.WriteString(" (раунд чата)")
+		}
+		if deadline := r.Deadline(); deadline != nil && r.IsActive {
+			sb.WriteString(" — до " + FormatZonedTime(*deadline, loc))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
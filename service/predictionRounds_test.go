@@ -0,0 +1,113 @@
+package service
+
+import (
+	"errors"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"testing"
+	"time"
+)
+
+func TestSplitRoundSelector(t *testing.T) {
+	tests := []struct {
+		text     string
+		selector string
+		rest     string
+	}{
+		{"", "", ""},
+		{"ver nor lec", "", "ver nor lec"},
+		{"  1 4 16 ", "", "1 4 16"},
+		{"#6 ver nor lec", "6", "ver nor lec"},
+		{"#6", "6", ""},
+		{" #6   поул=1 ", "6", "поул=1"},
+		{"#2025_6 1 4 16", "2025_6", "1 4 16"},
+		{"1 #6 4", "", "1 #6 4"},
+	}
+
+	for _, tt := range tests {
+		selector, rest := SplitRoundSelector(tt.text)
+		if selector != tt.selector || rest != tt.rest {
+			t.Errorf("SplitRoundSelector(%q) = %q, %q; want %q, %q", tt.text, selector, rest, tt.selector, tt.rest)
+		}
+	}
+}
+
+func TestPickRound(t *testing.T) {
+	miami := models.PredictionRace{ID: 1, RaceID: "2025_6", RaceName: "Miami Grand Prix"}
+	imola := models.PredictionRace{ID: 2, RaceID: "2025_7", RaceName: "Emilia Romagna Grand Prix"}
+	both := []models.PredictionRace{miami, imola}
+
+	tests := []struct {
+		name      string
+		rounds    []models.PredictionRace
+		selector  string
+		wantID    int // 0 — раунд не выбран
+		wantErr   error
+		ambiguous int // число раундов в *AmbiguousRoundError
+	}{
+		{"нет раундов", nil, "", 0, nil, 0},
+		{"нет раундов с номером", nil, "6", 0, temperrors.ErrRoundNotFound, 0},
+		{"единственный раунд", []models.PredictionRace{miami}, "", 1, nil, 0},
+		{"несколько раундов без номера", both, "", 0, temperrors.ErrAmbiguousRound, 2},
+		{"по номеру этапа", both, "7", 2, nil, 0},
+		{"по race_id", both, "2025_6", 1, nil, 0},
+		{"номер другого этапа", both, "8", 0, temperrors.ErrRoundNotFound, 0},
+		{"номер не совпадает с единственным", []models.PredictionRace{miami}, "7", 0, temperrors.ErrRoundNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			round, err := pickRound(tt.rounds, tt.selector)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("pickRound(%q) error = %v, want %v", tt.selector, err, tt.wantErr)
+				}
+				var ambiguous *AmbiguousRoundError
+				if tt.ambiguous > 0 && (!errors.As(err, &ambiguous) || len(ambiguous.Rounds) != tt.ambiguous) {
+					t.Fatalf("pickRound(%q) error = %v, want %d rounds to choose from", tt.selector, err, tt.ambiguous)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("pickRound(%q) error = %v", tt.selector, err)
+			}
+			gotID := 0
+			if round != nil {
+				gotID = round.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("pickRound(%q) = round #%d, want #%d", tt.selector, gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+// Дедлайн открытого раунда указывается в поясе пользователя, у закрытого не указывается
+func TestFormatRoundChoice(t *testing.T) {
+	closes := time.Date(2025, 5, 3, 20, 0, 0, 0, time.UTC)
+	rounds := []models.PredictionRace{
+		{RaceID: "2025_6", RaceName: "Miami Grand Prix", IsActive: true, ClosesAt: &closes},
+		{RaceID: "2025_5", RaceName: "Saudi Arabian Grand Prix", Platform: models.PlatformVK, ChatID: 2000000001},
+	}
+	vladivostok, err := time.LoadLocation("Asia/Vladivostok")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		want string
+	}{
+		{"Москва", DefaultLocation(), "#6 Miami Grand Prix — до 03 мая 2025 23:00 (МСК)\n#5 Saudi Arabian Grand Prix (раунд чата)\n"},
+		{"Владивосток", vladivostok, "#6 Miami Grand Prix — до 04 мая 2025 06:00 (UTC+10:00)\n#5 Saudi Arabian Grand Prix (раунд чата)\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatRoundChoice(rounds, tt.loc); got != tt.want {
+				t.Errorf("FormatRoundChoice() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"log/slog"
+	"racebot-vk/models"
+	"time"
+)
+
+type calendarSource interface {
+	GetCalendar(year int) ([]models.Race, error)
+}
+
+type driversListSource interface {
+	GetDriversListMessage(season int) (string, error)
+}
+
+// PredictionScheduler автоматически открывает раунды прогнозов за openBefore до гонки
+// и закрывает их по наступлении дедлайна (старт квалификации или гонки)
+type PredictionScheduler struct {
+	predictions   *PredictionService
+	calendar      calendarSource
+	drivers       driversListSource
+	locations     chatLocations
+	notifications *roundNotifier
+	openBefore    time.Duration
+	interval      time.Duration
+}
+
+func NewPredictionScheduler(predictions *PredictionService, calendar calendarSource, drivers driversListSource, audience ChatAudience, locations chatLocations, openBefore, interval time.Duration, notifiers ...Notifier) *PredictionScheduler {
+	return &PredictionScheduler{
+		predictions:   predictions,
+		calendar:      calendar,
+		drivers:       drivers,
+		locations:     locations,
+		notifications: &roundNotifier{storage: predictions.storage, audience: audience, notifiers: notifiers},
+		openBefore:    openBefore,
+		interval:      interval,
+	}
+}
+
+// Run запускает планировщик (блокирующий вызов)
+func (s *PredictionScheduler) Run(log *slog.Logger) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	log.Info("Start prediction scheduler", slog.Duration("open_before", s.openBefore), slog.String("close_at", s.predictions.closeAt))
+	s.tick(log, time.Now())
+
+	for t := range ticker.C {
+		s.tick(log, t)
+	}
+}
+
+func (s *PredictionScheduler) tick(log *slog.Logger, now time.Time) {
+	s.retryNotifications(log, now)
+	s.closeExpired(log, now)
+	s.openUpcoming(log, now)
+}
+
+// closeExpired закрывает открытые раунды, дедлайн которых уже наступил
+func (s *PredictionScheduler) closeExpired(log *slog.Logger, now time.Time) {
+	active, err := s.predictions.GetActiveRaces()
+	if err != nil {
+		log.Error("scheduler: failed to get active races", slog.Any("error", err))
+		return
+	}
+
+	for i := range active {
+		round := &active[i]
+		deadline := round.Deadline()
+		if deadline == nil || now.Before(*deadline) {
+			continue
+		}
+
+		if err := s.predictions.ClosePrediction(round.ID); err != nil {
+			log.Error("scheduler: failed to close prediction", slog.String("race_id", round.RaceID), slog.Int("round_id", round.ID), slog.Any("error", err))
+			continue
+		}
+
+		log.Info("Prediction closed automatically", slog.String("race_id", round.RaceID), slog.Int("round_id", round.ID))
+		s.notifications.notify(log, roundNotificationKey("close", round.ID), round, sameMessage(closedMessage(round)))
+	}
+}
+
+// openUpcoming открывает общий раунд на каждую гонку календаря, до старта которой осталось
+// не больше openBefore (на двойных этапах — сразу на обе гонки)
+func (s *PredictionScheduler) openUpcoming(log *slog.Logger, now time.Time) {
+	calendar, err := s.calendar.GetCalendar(now.Year())
+	if err != nil {
+		log.Error("scheduler: failed to get calendar", slog.Any("error", err))
+		return
+	}
+
+	var opened *models.PredictionRace
+	for _, race := range calendar {
+		raceStart, err := parseStringToTime(race.Date, race.Time)
+		if err != nil || !now.Before(raceStart) || now.Before(raceStart.Add(-s.openBefore)) {
+			continue
+		}
+		if round := s.openRace(log, race, now); round != nil && opened == nil {
+			opened = round
+		}
+	}
+	if opened == nil {
+		return
+	}
+
+	// Список гонщиков один на все открытые раунды, его ключ — по первому из них
+	driversMessage, err := s.driversMessage(opened)
+	if err != nil {
+		log.Error("scheduler: failed to get drivers list", slog.Any("error", err))
+		return
+	}
+	s.notifications.notify(log, roundNotificationKey("drivers", opened.ID), nil, sameMessage(driversMessage))
+}
+
+// openRace открывает и объявляет общий раунд на гонку, если на неё ещё не открывалось
+// ни одного раунда; возвращает открытый раунд или nil
+func (s *PredictionScheduler) openRace(log *slog.Logger, race models.Race, now time.Time) *models.PredictionRace {
+	raceID := race.Season + "_" + race.Round
+	existing, err := s.predictions.GetRaceRounds(raceID)
+	if err != nil {
+		log.Error("scheduler: failed to get prediction rounds", slog.String("race_id", raceID), slog.Any("error", err))
+		return nil
+	}
+	if len(existing) > 0 {
+		// Раунд уже открывался (вручную или планировщиком)
+		return nil
+	}
+	if deadline := s.predictions.RaceDeadline(race); deadline != nil && !now.Before(*deadline) {
+		// Окно приёма прогнозов уже прошло — раунд не открывается и не объявляется
+		return nil
+	}
+
+	predRace, err := s.predictions.StartPrediction(race, DefaultScoringRule, "", 0)
+	if err != nil {
+		log.Warn("scheduler: failed to start prediction", slog.String("race_id", raceID), slog.Any("error", err))
+		return nil
+	}
+
+	log.Info("Prediction opened automatically", slog.String("race_id", raceID), slog.Int("round_id", predRace.ID))
+	s.notifications.notify(log, roundNotificationKey("announce", predRace.ID), predRace, s.announcement(predRace))
+	return predRace
+}
+
+// announcement — объявление о раунде с дедлайном в часовом поясе чата
+func (s *PredictionScheduler) announcement(round *models.PredictionRace) chatMessage {
+	return func(platform string, chatID int64) string {
+		return s.predictions.GetAnnouncementMessage(round, s.locations.ChatLocation(platform, chatID))
+	}
+}
+
+// closedMessage — уведомление о закрытии приёма прогнозов
+func closedMessage(round *models.PredictionRace) string {
+	return "Приём прогнозов на гонку '" + round.RaceName + "' закрыт."
+}
+
+// driversMessage — список гонщиков сезона раунда
+func (s *PredictionScheduler) driversMessage(round *models.PredictionRace) (string, error) {
+	season, _, err := parseRaceID(round.RaceID)
+	if err != nil {
+		return "", err
+	}
+	return s.drivers.GetDriversListMessage(season)
+}
+
+// retryNotifications досылает объявления, списки гонщиков и уведомления о закрытии раундов
+// в чаты, в которые они не дошли. Объявление закрытого раунда уже не досылается.
+func (s *PredictionScheduler) retryNotifications(log *slog.Logger, now time.Time) {
+	for _, kind := range []string{"announce", "drivers", "close"} {
+		for _, p := range s.notifications.pending(log, kind, now) {
+			round, err := s.predictions.GetRound(p.roundID)
+			if err != nil {
+				log.Error("scheduler: failed to get prediction round", slog.Int("round_id", p.roundID), slog.Any("error", err))
+				continue
+			}
+			if round == nil || (kind != "close" && !round.IsActive) {
+				s.notifications.done(log, p.key)
+				continue
+			}
+
+			switch kind {
+			case "announce":
+				s.notifications.deliver(log, p.key, round, s.announcement(round))
+			case "drivers":
+				message, err := s.driversMessage(round)
+				if err != nil {
+					log.Error("scheduler: failed to get drivers list", slog.Any("error", err))
+					continue
+				}
+				s.notifications.deliver(log, p.key, nil, sameMessage(message))
+			case "close":
+				s.notifications.deliver(log, p.key, round, sameMessage(closedMessage(round)))
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"io"
+	"log/slog"
+	"racebot-vk/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fixedDriversList — список гонщиков сезона без обращений к Ergast
+type fixedDriversList string
+
+func (d fixedDriversList) GetDriversListMessage(int) (string, error) {
+	return string(d), nil
+}
+
+// Раунд открывается и объявляется, только пока дедлайн из календаря не наступил
+func TestSchedulerOpenUpcoming(t *testing.T) {
+	race := models.Race{
+		Season: "2025", Round: "6", RaceName: "Miami Grand Prix", Date: "2025-05-04", Time: "20:00:00Z",
+		Qualifying: models.Qualifying{Date: "2025-05-03", Time: "20:00:00Z"},
+	}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		wantOpen bool
+	}{
+		{"до квалификации", time.Date(2025, 5, 2, 12, 0, 0, 0, time.UTC), true},
+		{"после квалификации", time.Date(2025, 5, 4, 12, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newTestStorage(t)
+			predictions := NewPredictionService(storage, nil, CloseAtQualifying)
+			chats := fixedChats{2000000001}
+			notifier := &flakyNotifier{}
+			scheduler := NewPredictionScheduler(predictions, fixedCalendar{race}, fixedDriversList("Гонщики"), chats, chats, 7*24*time.Hour, time.Minute, notifier)
+
+			scheduler.openUpcoming(slog.New(slog.NewTextHandler(io.Discard, nil)), tt.now)
+
+			rounds, err := storage.GetRaceRounds("2025_6")
+			if err != nil {
+				t.Fatalf("GetRaceRounds: %v", err)
+			}
+			if opened := len(rounds) > 0; opened != tt.wantOpen {
+				t.Fatalf("round opened = %v, want %v", opened, tt.wantOpen)
+			}
+			if !tt.wantOpen && len(notifier.sent) > 0 {
+				t.Fatalf("got messages in chats %v for a round that was not opened", notifier.sent)
+			}
+			if tt.wantOpen && len(notifier.sent) != 2 {
+				t.Fatalf("got messages in chats %v, want announcement and drivers list", notifier.sent)
+			}
+		})
+	}
+}
+
+// Объявление, не дошедшее до чата, досылается на следующей проверке только в этот чат
+func TestSchedulerRetriesFailedAnnouncement(t *testing.T) {
+	race := models.Race{
+		Season: "2025", Round: "6", RaceName: "Miami Grand Prix", Date: "2025-05-04", Time: "20:00:00Z",
+		Qualifying: models.Qualifying{Date: "2025-05-03", Time: "20:00:00Z"},
+	}
+	storage := newTestStorage(t)
+	predictions := NewPredictionService(storage, nil, CloseAtQualifying)
+	chats := fixedChats{2000000001, 2000000002}
+	notifier := &flakyNotifier{failOnce: map[int64]bool{2000000002: true}}
+	scheduler := NewPredictionScheduler(predictions, fixedCalendar{race}, fixedDriversList("Гонщики"), chats, chats, 7*24*time.Hour, time.Minute, notifier)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	now := time.Date(2025, 5, 2, 12, 0, 0, 0, time.UTC)
+
+	// Объявление не дошло до второго чата, список гонщиков дошёл до обоих
+	scheduler.tick(log, now)
+	if want := []int64{2000000001, 2000000001, 2000000002}; !slices.Equal(notifier.sent, want) {
+		t.Fatalf("first tick: sent to %v, want %v", notifier.sent, want)
+	}
+
+	notifier.sent = nil
+	scheduler.tick(log, now.Add(time.Minute))
+	if want := []int64{2000000002}; !slices.Equal(notifier.sent, want) {
+		t.Fatalf("second tick: sent to %v, want %v", notifier.sent, want)
+	}
+
+	notifier.sent = nil
+	scheduler.tick(log, now.Add(2*time.Minute))
+	if len(notifier.sent) != 0 {
+		t.Fatalf("third tick: sent to %v, want nothing", notifier.sent)
+	}
+}
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type raceResultsSource interface {
+	GetRaceResults(season int, raceId string) ([]models.Race, error)
+	GetQualifyingResults(season int, raceID string) ([]models.Race, error)
+	GetSprintResults(season int, raceId string) []models.Race
+}
+
+// Notifier рассылает сообщение в настроенные чаты платформы
+type Notifier interface {
+	// SendToChat отправляет сообщение в один чат платформы; ошибка отправки уже записана в лог
+	SendToChat(log *slog.Logger, chatID int64, message string) error
+	// Platform возвращает платформу рассылки (models.PlatformVK, models.PlatformTelegram)
+	Platform() string
+}
+
+// PredictionSettler периодически проверяет закрытые раунды прогнозов без результатов,
+// подтягивает реальный подиум из Ergast, подсчитывает очки и публикует итоги
+type PredictionSettler struct {
+	predictions   *PredictionService
+	results       raceResultsSource
+	notifications *roundNotifier
+	interval      time.Duration
+}
+
+func NewPredictionSettler(predictions *PredictionService, results raceResultsSource, audience ChatAudience, interval time.Duration, notifiers ...Notifier) *PredictionSettler {
+	return &PredictionSettler{
+		predictions:   predictions,
+		results:       results,
+		notifications: &roundNotifier{storage: predictions.storage, audience: audience, notifiers: notifiers},
+		interval:      interval,
+	}
+}
+
+// Run запускает периодическую проверку (блокирующий вызов)
+func (s *PredictionSettler) Run(log *slog.Logger) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	log.Info("Start prediction settler", slog.Duration("interval", s.interval))
+	s.settleOnce(log)
+
+	for range ticker.C {
+		s.settleOnce(log)
+	}
+}
+
+// settleOnce повторяет рассылку недоставленных итогов и подводит итоги по всем закрытым
+// раундам, для которых в Ergast появились результаты
+func (s *PredictionSettler) settleOnce(log *slog.Logger) {
+	s.retrySummaries(log, time.Now())
+
+	races, err := s.predictions.GetAllRaces()
+	if err != nil {
+		log.Error("settler: failed to get prediction races", slog.Any("error", err))
+		return
+	}
+
+	settled := make(map[string]bool)
+	for i := range races {
+		race := &races[i]
+		if race.IsActive || race.Driver1 != nil || settled[race.RaceID] {
+			continue
+		}
+		settled[race.RaceID] = true
+
+		if err := s.settleRace(log, race); err != nil {
+			log.Error("settler: failed to settle race", slog.String("race_id", race.RaceID), slog.Any("error", err))
+		}
+	}
+}
+
+func (s *PredictionSettler) settleRace(log *slog.Logger, race *models.PredictionRace) error {
+	season, round, err := parseRaceID(race.RaceID)
+	if err != nil {
+		return err
+	}
+
+	raceResults, err := s.results.GetRaceResults(season, round)
+	if err != nil {
+		if errors.Is(err, temperrors.ErrEmptyList) {
+			// Результаты ещё не опубликованы
+			return nil
+		}
+		return fmt.Errorf("failed to get race results: %w", err)
+	}
+
+	d1, d2, d3, err := podiumFromResults(raceResults[0].Results)
+	if err != nil {
+		return err
+	}
+
+	// Результаты гонки общие для всех её раундов, но итоги подводятся только по закрытым
+	// раундам, которые их ещё не получили: подведённые раньше раунды не объявляются повторно,
+	// а открытые получат результаты после закрытия
+	rounds, err := s.predictions.GetRaceRounds(race.RaceID)
+	if err != nil {
+		return fmt.Errorf("failed to get race rounds: %w", err)
+	}
+
+	outcome := RaceOutcome{
+		Podium:         []uint8{d1, d2, d3},
+		Classification: classificationFromResults(raceResults[0].Results),
+		Extras:         s.extrasFromErgast(log, race, season, round, raceResults[0].Results),
+	}
+	for i := range rounds {
+		round := &rounds[i]
+		if round.IsActive || round.Driver1 != nil {
+			continue
+		}
+
+		// Раунд получает результаты вместе с очками, поэтому раунд с ошибкой подсчёта
+		// остаётся без результатов и подводится на следующей проверке
+		results, err := s.predictions.SettleRound(round, outcome)
+		if err != nil {
+			log.Error("settler: failed to settle round", slog.String("race_id", round.RaceID), slog.Int("round_id", round.ID), slog.Any("error", err))
+			continue
+		}
+
+		log.Info("Prediction race settled automatically", slog.String("race_id", round.RaceID), slog.Int("round_id", round.ID), slog.Int("predictions", len(results)))
+		s.notifications.notify(log, roundNotificationKey("summary", round.ID), round, sameMessage(s.predictions.GetSummaryMessage(round, results)))
+	}
+	return nil
+}
+
+// retrySummaries досылает итоги раундов в чаты, в которые они не дошли
+func (s *PredictionSettler) retrySummaries(log *slog.Logger, now time.Time) {
+	for _, p := range s.notifications.pending(log, "summary", now) {
+		round, err := s.predictions.GetRound(p.roundID)
+		if err != nil {
+			log.Error("settler: failed to get prediction round", slog.Int("round_id", p.roundID), slog.Any("error", err))
+			continue
+		}
+		if round == nil {
+			s.notifications.done(log, p.key)
+			continue
+		}
+
+		results, err := s.predictions.GetRoundResults(round)
+		if err != nil {
+			log.Error("settler: failed to get round results", slog.Int("round_id", round.ID), slog.Any("error", err))
+			continue
+		}
+		s.notifications.deliver(log, p.key, round, sameMessage(s.predictions.GetSummaryMessage(round, results)))
+	}
+}
+
+// extrasFromErgast собирает ответы на дополнительные вопросы: быстрый круг, первый сход
+// и команду победителя — из результатов гонки, поул — из квалификации, подиум спринта —
+// из результатов спринта. Недоступные данные оставляют вопрос без ответа.
+func (s *PredictionSettler) extrasFromErgast(log *slog.Logger, race *models.PredictionRace, season int, round string, results []models.Result) models.PredictionExtras {
+	extras := models.PredictionExtras{
+		FastestLap:  fastestLapFromResults(results),
+		FirstDNF:    firstDNFFromResults(results),
+		Constructor: winnerConstructorFromResults(results),
+	}
+
+	qualResults, err := s.results.GetQualifyingResults(season, round)
+	if err != nil {
+		log.Warn("settler: failed to get qualifying results", slog.String("race_id", race.RaceID), slog.Any("error", err))
+	} else if pole := classificationFromResults(qualResults[0].QualifyingResults); len(pole) > 0 {
+		extras.Pole = pole[0]
+	}
+
+	if race.IsSprint {
+		sprintResults := s.results.GetSprintResults(season, round)
+		if len(sprintResults) > 0 {
+			if d1, d2, d3, err := podiumFromResults(sprintResults[0].SprintResults); err == nil {
+				extras.SprintPodium = []uint8{d1, d2, d3}
+			}
+		}
+	}
+
+	return extras
+}
+
+// fastestLapFromResults возвращает номер гонщика с быстрейшим кругом гонки
+func fastestLapFromResults(results []models.Result) uint8 {
+	for _, r := range results {
+		if r.FastestLap.Rank != "1" {
+			continue
+		}
+		if num, err := strconv.ParseUint(r.Number, 10, 8); err == nil {
+			return uint8(num)
+		}
+	}
+	return 0
+}
+
+// firstDNFFromResults возвращает номер гонщика, сошедшего раньше всех: меньше всего пройденных
+// кругов, при равном числе — ниже в протоколе (Ergast ставит выше сошедшего позже).
+// Финишировавшие, не стартовавшие, снявшиеся, дисквалифицированные и не классифицированные не учитываются.
+func firstDNFFromResults(results []models.Result) uint8 {
+	type retirement struct {
+		number uint8
+		laps   int
+		order  int
+	}
+	var retirements []retirement
+	for i, r := range results {
+		if !isRetirement(r) {
+			continue
+		}
+		laps, err := strconv.Atoi(r.Laps)
+		if err != nil {
+			continue
+		}
+		num, err := strconv.ParseUint(r.Number, 10, 8)
+		if err != nil {
+			continue
+		}
+		retirements = append(retirements, retirement{number: uint8(num), laps: laps, order: i})
+	}
+	if len(retirements) == 0 {
+		return 0
+	}
+
+	sort.Slice(retirements, func(i, j int) bool {
+		if retirements[i].laps != retirements[j].laps {
+			return retirements[i].laps < retirements[j].laps
+		}
+		return retirements[i].order > retirements[j].order
+	})
+	return retirements[0].number
+}
+
+// isRetirement возвращает true, если гонщик стартовал и сошёл по ходу гонки: positionText "R",
+// а если его нет в ответе — статус схода ("Engine", "Collision", "Retired")
+func isRetirement(r models.Result) bool {
+	switch r.Status {
+	case "Did not start", "Withdrew", "Disqualified", "Excluded", "Not classified",
+		"Did not qualify", "Did not prequalify", "107% Rule":
+		return false
+	}
+	if r.PositionText != "" {
+		return r.PositionText == "R"
+	}
+	return !isClassifiedStatus(r.Status)
+}
+
+// isClassifiedStatus возвращает true для статусов финишировавших гонщиков
+func isClassifiedStatus(status string) bool {
+	return status == "Finished" || status == "Lapped" || strings.HasPrefix(status, "+")
+}
+
+// winnerConstructorFromResults возвращает constructorId команды победителя
+func winnerConstructorFromResults(results []models.Result) string {
+	for _, r := range results {
+		if r.Position == "1" {
+			return r.Constructor.ConstructorId
+		}
+	}
+	return ""
+}
+
+// podiumFromResults возвращает номера гонщиков, занявших первые три места
+func podiumFromResults(results []models.Result) (uint8, uint8, uint8, error) {
+	podium := make([]uint8, 3)
+	found := 0
+
+	for _, r := range results {
+		pos, err := strconv.Atoi(r.Position)
+		if err != nil || pos < 1 || pos > 3 {
+			continue
+		}
+		num, err := strconv.ParseUint(r.Number, 10, 8)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid car number %q: %w", r.Number, temperrors.ErrParse)
+		}
+		podium[pos-1] = uint8(num)
+		found++
+	}
+
+	if found != 3 {
+		return 0, 0, 0, fmt.Errorf("incomplete podium in race results: %w", temperrors.ErrEmptyList)
+	}
+	return podium[0], podium[1], podium[2], nil
+}
+
+// classificationFromResults возвращает номера гонщиков в порядке финиша
+func classificationFromResults(results []models.Result) []uint8 {
+	byPosition := make(map[int]uint8, len(results))
+	for _, r := range results {
+		pos, err := strconv.Atoi(r.Position)
+		if err != nil {
+			continue
+		}
+		num, err := strconv.ParseUint(r.Number, 10, 8)
+		if err != nil {
+			continue
+		}
+		byPosition[pos] = uint8(num)
+	}
+
+	classification := make([]uint8, 0, len(byPosition))
+	for pos := 1; pos <= len(results); pos++ {
+		num, ok := byPosition[pos]
+		if !ok {
+			break
+		}
+		classification = append(classification, num)
+	}
+	return classification
+}
+
+// parseRaceID разбирает race_id вида "2025_1" на сезон и номер этапа
+func parseRaceID(raceID string) (int, string, error) {
+	parts := strings.SplitN(raceID, "_", 2)
+	if len(parts) != 2 {
+		return 0, "", fmt.Errorf("invalid race id %q: %w", raceID, temperrors.ErrParse)
+	}
+	season, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid season in race id %q: %w", raceID, temperrors.ErrParse)
+	}
+	return season, parts[1], nil
+}
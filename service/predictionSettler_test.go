@@ -0,0 +1,243 @@
+package service
+
+import (
+	"io"
+	"log/slog"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"testing"
+)
+
+// fakeResults отдаёт результаты гонки; квалификации и спринта нет
+type fakeResults struct {
+	results []models.Result
+}
+
+func (f fakeResults) GetRaceResults(int, string) ([]models.Race, error) {
+	if len(f.results) == 0 {
+		return nil, temperrors.ErrEmptyList
+	}
+	return []models.Race{{Results: f.results}}, nil
+}
+
+func (f fakeResults) GetQualifyingResults(int, string) ([]models.Race, error) {
+	return nil, temperrors.ErrEmptyList
+}
+
+func (f fakeResults) GetSprintResults(int, string) []models.Race {
+	return nil
+}
+
+// recordingNotifier запоминает, в какие чаты ушли сообщения
+type recordingNotifier struct {
+	sent []int64
+}
+
+func (n *recordingNotifier) SendToChat(_ *slog.Logger, chatID int64, _ string) error {
+	n.sent = append(n.sent, chatID)
+	return nil
+}
+
+func (n *recordingNotifier) Platform() string {
+	return models.PlatformVK
+}
+
+// Раунд, закрытый после публикации результатов, подводится отдельно, а подведённый
+// раньше раунд той же гонки не объявляется повторно
+func TestSettleRoundClosedAfterResults(t *testing.T) {
+	storage := newTestStorage(t)
+	predictions := NewPredictionService(storage, nil, CloseAtQualifying)
+	notifier := &recordingNotifier{}
+	settler := NewPredictionSettler(predictions, fakeResults{results: []models.Result{
+		{Number: "1", Position: "1", Status: "Finished"},
+		{Number: "4", Position: "2", Status: "Finished"},
+		{Number: "16", Position: "3", Status: "Finished"},
+	}}, nil, 0, notifier)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	closed := &models.PredictionRace{RaceID: "2025_1", RaceName: "Australian Grand Prix", Platform: models.PlatformVK, ChatID: 100, Scoring: DefaultScoringRule}
+	open := &models.PredictionRace{RaceID: "2025_1", RaceName: "Australian Grand Prix", Platform: models.PlatformVK, ChatID: 200, IsActive: true, Scoring: DefaultScoringRule}
+	for _, round := range []*models.PredictionRace{closed, open} {
+		if err := storage.CreateRace(round); err != nil {
+			t.Fatalf("CreateRace: %v", err)
+		}
+	}
+
+	settler.settleOnce(log)
+	if len(notifier.sent) != 1 || notifier.sent[0] != 100 {
+		t.Fatalf("first settle: got summaries for chats %v, want [100]", notifier.sent)
+	}
+	rounds, err := storage.GetRaceRounds("2025_1")
+	if err != nil {
+		t.Fatalf("GetRaceRounds: %v", err)
+	}
+	for _, r := range rounds {
+		if r.ID == open.ID && r.Driver1 != nil {
+			t.Fatalf("open round #%d got race results before closing", r.ID)
+		}
+	}
+
+	if err := storage.CloseRace(open.ID); err != nil {
+		t.Fatalf("CloseRace: %v", err)
+	}
+	notifier.sent = nil
+	settler.settleOnce(log)
+	if len(notifier.sent) != 1 || notifier.sent[0] != 200 {
+		t.Fatalf("second settle: got summaries for chats %v, want [200]", notifier.sent)
+	}
+
+	notifier.sent = nil
+	settler.settleOnce(log)
+	if len(notifier.sent) != 0 {
+		t.Fatalf("third settle: got summaries for chats %v, want none", notifier.sent)
+	}
+}
+
+// Ошибка подсчёта одного раунда не мешает подвести остальные раунды гонки, а сам раунд
+// остаётся без результатов и подводится на следующей проверке
+func TestSettleContinuesAfterRoundFailure(t *testing.T) {
+	storage := newTestStorage(t)
+	predictions := NewPredictionService(storage, nil, CloseAtQualifying)
+	notifier := &recordingNotifier{}
+	settler := NewPredictionSettler(predictions, fakeResults{results: []models.Result{
+		{Number: "1", Position: "1", Status: "Finished"},
+		{Number: "4", Position: "2", Status: "Finished"},
+		{Number: "16", Position: "3", Status: "Finished"},
+	}}, nil, 0, notifier)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Правило первого раунда неизвестно — его подсчёт завершается ошибкой
+	broken := &models.PredictionRace{RaceID: "2025_1", RaceName: "Australian Grand Prix", Platform: models.PlatformVK, ChatID: 100, Scoring: "fantasy"}
+	healthy := &models.PredictionRace{RaceID: "2025_1", RaceName: "Australian Grand Prix", Platform: models.PlatformVK, ChatID: 200, Scoring: DefaultScoringRule}
+	for _, round := range []*models.PredictionRace{broken, healthy} {
+		if err := storage.CreateRace(round); err != nil {
+			t.Fatalf("CreateRace: %v", err)
+		}
+		pred := &models.Prediction{Platform: models.PlatformVK, UserID: 1, RaceID: round.RaceID, RoundID: round.ID, ChatID: round.ChatID, Driver1: 1, Driver2: 4, Driver3: 16}
+		if err := storage.SavePrediction(pred); err != nil {
+			t.Fatalf("SavePrediction: %v", err)
+		}
+	}
+
+	settler.settleOnce(log)
+	if len(notifier.sent) != 1 || notifier.sent[0] != 200 {
+		t.Fatalf("first settle: got summaries for chats %v, want [200]", notifier.sent)
+	}
+	round, err := storage.GetRound(broken.ID)
+	if err != nil {
+		t.Fatalf("GetRound: %v", err)
+	}
+	if round.Driver1 != nil {
+		t.Fatalf("round #%d got race results although scoring failed", broken.ID)
+	}
+
+	if err := storage.SetRaceScoring(broken.ID, DefaultScoringRule); err != nil {
+		t.Fatalf("SetRaceScoring: %v", err)
+	}
+	notifier.sent = nil
+	settler.settleOnce(log)
+	if len(notifier.sent) != 1 || notifier.sent[0] != 100 {
+		t.Fatalf("second settle: got summaries for chats %v, want [100]", notifier.sent)
+	}
+	preds, err := storage.GetRoundPredictions(broken.ID)
+	if err != nil {
+		t.Fatalf("GetRoundPredictions: %v", err)
+	}
+	if len(preds) != 1 || preds[0].Points != 15 {
+		t.Fatalf("round #%d predictions = %+v, want one with 15 points", broken.ID, preds)
+	}
+}
+
+// Итоги, не дошедшие до чата раунда, досылаются на следующей проверке один раз
+func TestSettleRetriesFailedSummary(t *testing.T) {
+	storage := newTestStorage(t)
+	predictions := NewPredictionService(storage, nil, CloseAtQualifying)
+	notifier := &flakyNotifier{failOnce: map[int64]bool{100: true}}
+	settler := NewPredictionSettler(predictions, fakeResults{results: []models.Result{
+		{Number: "1", Position: "1", Status: "Finished"},
+		{Number: "4", Position: "2", Status: "Finished"},
+		{Number: "16", Position: "3", Status: "Finished"},
+	}}, nil, 0, notifier)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	round := &models.PredictionRace{RaceID: "2025_1", RaceName: "Australian Grand Prix", Platform: models.PlatformVK, ChatID: 100, Scoring: DefaultScoringRule}
+	if err := storage.CreateRace(round); err != nil {
+		t.Fatalf("CreateRace: %v", err)
+	}
+
+	settler.settleOnce(log)
+	if len(notifier.sent) != 0 {
+		t.Fatalf("first settle: summary delivered to %v despite send failure", notifier.sent)
+	}
+
+	settler.settleOnce(log)
+	if len(notifier.sent) != 1 || notifier.sent[0] != 100 {
+		t.Fatalf("second settle: got summaries for chats %v, want [100]", notifier.sent)
+	}
+
+	notifier.sent = nil
+	settler.settleOnce(log)
+	if len(notifier.sent) != 0 {
+		t.Fatalf("third settle: got summaries for chats %v, want none", notifier.sent)
+	}
+}
+
+func TestFirstDNFFromResults(t *testing.T) {
+	result := func(number, positionText, status, laps string) models.Result {
+		return models.Result{Number: number, PositionText: positionText, Status: status, Laps: laps}
+	}
+
+	tests := []struct {
+		name    string
+		results []models.Result
+		want    uint8
+	}{
+		{"все финишировали", []models.Result{
+			result("1", "1", "Finished", "57"),
+			result("4", "2", "+1 Lap", "56"),
+			result("16", "3", "Lapped", "56"),
+		}, 0},
+		{"меньше всего кругов", []models.Result{
+			result("1", "1", "Finished", "57"),
+			result("44", "R", "Engine", "40"),
+			result("63", "R", "Collision", "12"),
+		}, 63},
+		{"равные круги: ниже в протоколе", []models.Result{
+			result("1", "1", "Finished", "57"),
+			result("44", "R", "Collision", "0"),
+			result("63", "R", "Collision", "0"),
+		}, 63},
+		{"порядок протокола не важен", []models.Result{
+			result("1", "1", "Finished", "57"),
+			result("63", "R", "Accident", "3"),
+			result("44", "R", "Engine", "30"),
+		}, 63},
+		{"дисквалификация, неучастие и снятие — не сход", []models.Result{
+			result("1", "1", "Finished", "57"),
+			result("44", "R", "Gearbox", "20"),
+			result("16", "D", "Disqualified", "57"),
+			result("10", "W", "Did not start", "0"),
+			result("31", "W", "Withdrew", "0"),
+			result("18", "N", "Not classified", "45"),
+			result("22", "E", "Excluded", "0"),
+		}, 44},
+		{"без positionText — по статусу", []models.Result{
+			result("1", "", "Finished", "57"),
+			result("44", "", "Engine", "20"),
+			result("10", "", "Did not start", "0"),
+			result("16", "", "Disqualified", "5"),
+		}, 44},
+		{"только не стартовавшие", []models.Result{
+			result("1", "1", "Finished", "57"),
+			result("10", "W", "Did not start", "0"),
+		}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := firstDNFFromResults(tt.results); got != tt.want {
+				t.Errorf("firstDNFFromResults() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"racebot-vk/models"
+	predStorage "racebot-vk/storage/prediction"
+	"racebot-vk/temperrors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestStorage создаёт пустую БД прогнозов во временном каталоге теста
+func newTestStorage(t *testing.T) *predStorage.Storage {
+	t.Helper()
+	storage, err := predStorage.NewStorage(filepath.Join(t.TempDir(), "predictions.db"))
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
+
+func TestSortPredictionResults(t *testing.T) {
+	base := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	result := func(userID, points int, submitted time.Duration) models.PredictionResult {
+		return models.PredictionResult{
+			UserID:     userID,
+			Points:     points,
+			Prediction: models.Prediction{UserID: userID, CreatedAt: base.Add(submitted)},
+		}
+	}
+
+	results := []models.PredictionResult{
+		result(1, 0, 0),
+		result(2, 5, 3*time.Minute),
+		result(3, 10, 2*time.Minute),
+		result(4, 5, time.Minute),
+	}
+	sortPredictionResults(results)
+
+	want := []int{3, 4, 2, 1}
+	for i, r := range results {
+		if r.UserID != want[i] {
+			t.Fatalf("place %d: got user %d, want %d (order %v)", i+1, r.UserID, want[i], userIDs(results))
+		}
+	}
+}
+
+// Первый подсчёт раунда: в БД у всех прогнозов ещё 0 очков, поэтому порядок из БД —
+// порядок отправки. Итоги должны идти по новым очкам.
+func TestCalculateResultsOrdersByNewPoints(t *testing.T) {
+	storage := newTestStorage(t)
+	service := NewPredictionService(storage, nil, CloseAtQualifying)
+
+	round := &models.PredictionRace{RaceID: "2025_1", RaceName: "Australian Grand Prix", Scoring: DefaultScoringRule}
+	if err := storage.CreateRace(round); err != nil {
+		t.Fatalf("CreateRace: %v", err)
+	}
+	predictions := []models.Prediction{
+		{Platform: models.PlatformVK, UserID: 1, Driver1: 81, Driver2: 44, Driver3: 63}, // мимо
+		{Platform: models.PlatformVK, UserID: 2, Driver1: 4, Driver2: 1, Driver3: 16},   // подиум не по порядку
+		{Platform: models.PlatformVK, UserID: 3, Driver1: 1, Driver2: 4, Driver3: 16},   // точный подиум
+	}
+	for i := range predictions {
+		predictions[i].RaceID, predictions[i].RoundID = round.RaceID, round.ID
+		if err := storage.SavePrediction(&predictions[i]); err != nil {
+			t.Fatalf("SavePrediction: %v", err)
+		}
+	}
+	if err := storage.SetRaceResults(round.RaceID, 1, 4, 16, nil); err != nil {
+		t.Fatalf("SetRaceResults: %v", err)
+	}
+
+	results, err := service.CalculateResults(round.ID)
+	if err != nil {
+		t.Fatalf("CalculateResults: %v", err)
+	}
+
+	want := []int{3, 2, 1}
+	for i, r := range results {
+		if r.UserID != want[i] {
+			t.Fatalf("place %d: got user %d, want %d (order %v)", i+1, r.UserID, want[i], userIDs(results))
+		}
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Points > results[i-1].Points {
+			t.Fatalf("results not sorted by points: %d after %d", results[i].Points, results[i-1].Points)
+		}
+	}
+}
+
+func userIDs(results []models.PredictionResult) []int {
+	ids := make([]int, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.UserID)
+	}
+	return ids
+}
+
+// Объявление общее для VK и Telegram: в нём дедлайн в поясе чата, правило подсчёта и номер
+// этапа, по которому VK принимает прогноз ответом на объявление
+func TestGetAnnouncementMessage(t *testing.T) {
+	closes := time.Date(2025, 4, 5, 6, 0, 0, 0, time.UTC)
+	race := &models.PredictionRace{RaceID: "2025_3", RaceName: "Japanese Grand Prix", Scoring: DefaultScoringRule, ClosesAt: &closes}
+	yekaterinburg, err := time.LoadLocation("Asia/Yekaterinburg")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		loc      *time.Location
+		deadline string
+	}{
+		{"Москва", DefaultLocation(), "Приём прогнозов до 05 апреля 2025 09:00 (МСК)."},
+		{"пояс чата", yekaterinburg, "Приём прогнозов до 05 апреля 2025 11:00 (UTC+05:00)."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := NewPredictionService(nil, nil, CloseAtQualifying).GetAnnouncementMessage(race, tt.loc)
+			for _, want := range []string{"Japanese Grand Prix", tt.deadline, "Правило подсчёта очков: " + DefaultScoringRule, "Номер этапа: #3", "допрогноз"} {
+				if !strings.Contains(msg, want) {
+					t.Errorf("announcement has no %q:\n%s", want, msg)
+				}
+			}
+		})
+	}
+}
+
+// Каждый дополнительный вопрос закрывается со стартом своей сессии: поул — с квалификацией,
+// подиум спринта — со спринтом, остальные вопросы — с дедлайном раунда (здесь старт гонки)
+func TestSubmitExtrasQuestionDeadlines(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name       string
+		qualifying *time.Time
+		sprint     *time.Time
+		extras     models.PredictionExtras
+		wantErr    error
+	}{
+		{"поул до квалификации", at(time.Hour), at(-time.Hour), models.PredictionExtras{Pole: 1}, nil},
+		{"поул после квалификации", at(-time.Hour), at(-2 * time.Hour), models.PredictionExtras{Pole: 1}, temperrors.ErrQuestionClosed},
+		{"быстрый круг после квалификации", at(-time.Hour), at(-2 * time.Hour), models.PredictionExtras{FastestLap: 4, Constructor: "mclaren"}, nil},
+		{"подиум спринта до спринта", at(2 * time.Hour), at(time.Hour), models.PredictionExtras{SprintPodium: []uint8{1, 4, 27}}, nil},
+		{"подиум спринта после спринта", at(time.Hour), at(-time.Hour), models.PredictionExtras{SprintPodium: []uint8{1, 4, 27}}, temperrors.ErrQuestionClosed},
+		{"поул вместе с другими вопросами", at(-time.Hour), nil, models.PredictionExtras{Pole: 1, FirstDNF: 27}, temperrors.ErrQuestionClosed},
+		{"раунд без времени сессий", nil, nil, models.PredictionExtras{Pole: 1, SprintPodium: []uint8{1, 4, 27}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newTestStorage(t)
+			predictions := NewPredictionService(storage, NewDriverResolver(testGrid()), CloseAtRace)
+
+			round := &models.PredictionRace{
+				RaceID: "2025_6", RaceName: "Miami Grand Prix", IsActive: true, Scoring: DefaultScoringRule, IsSprint: true,
+				RaceStart: at(24 * time.Hour), ClosesAt: at(24 * time.Hour), QualifyingStart: tt.qualifying, SprintStart: tt.sprint,
+			}
+			if err := storage.CreateRace(round); err != nil {
+				t.Fatalf("CreateRace: %v", err)
+			}
+			if err := predictions.SubmitPrediction(models.PlatformVK, 1, 0, round.ID, 1, 4, 27); err != nil {
+				t.Fatalf("SubmitPrediction: %v", err)
+			}
+
+			_, err := predictions.SubmitExtras(models.PlatformVK, 1, round.ID, tt.extras)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SubmitExtras error = %v, want %v", err, tt.wantErr)
+			}
+
+			saved, err := storage.GetUserPrediction(models.PlatformVK, 1, round.ID)
+			if err != nil {
+				t.Fatalf("GetUserPrediction: %v", err)
+			}
+			if tt.wantErr != nil && !saved.Extras.IsEmpty() {
+				t.Fatalf("rejected answers were saved: %+v", saved.Extras)
+			}
+		})
+	}
+}
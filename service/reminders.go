@@ -0,0 +1,159 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	predStorage "racebot-vk/storage/prediction"
+	"sort"
+	"time"
+)
+
+// ReminderAudience возвращает чаты платформы, в которые отправляется напоминание о сессии
+type ReminderAudience interface {
+	ReminderChats(platform string, session models.SessionType) ([]int64, error)
+}
+
+// chatLocations возвращает часовой пояс чата для времени в рассылке
+type chatLocations interface {
+	ChatLocation(platform string, chatID int64) *time.Location
+}
+
+// ReminderScheduler отправляет напоминания о сессиях гоночного уикенда за before до старта.
+// Отправленные напоминания сохраняются в БД, поэтому после перезапуска не повторяются.
+type ReminderScheduler struct {
+	calendar  calendarSource
+	storage   *predStorage.Storage
+	audience  ReminderAudience
+	locations chatLocations
+	notifiers []Notifier
+	before    time.Duration
+	interval  time.Duration
+}
+
+func NewReminderScheduler(calendar calendarSource, storage *predStorage.Storage, audience ReminderAudience, locations chatLocations, before, interval time.Duration, notifiers ...Notifier) *ReminderScheduler {
+	return &ReminderScheduler{
+		calendar:  calendar,
+		storage:   storage,
+		audience:  audience,
+		locations: locations,
+		notifiers: notifiers,
+		before:    before,
+		interval:  interval,
+	}
+}
+
+// Run запускает планировщик напоминаний (блокирующий вызов)
+func (s *ReminderScheduler) Run(log *slog.Logger) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	log.Info("Start reminder scheduler", slog.Duration("before", s.before))
+	s.tick(log, time.Now())
+
+	for t := range ticker.C {
+		s.tick(log, t)
+	}
+}
+
+// tick отправляет напоминания о сессиях, до старта которых осталось не больше before.
+// Напоминание о начавшейся сессии не отправляется, даже если бот был выключен.
+func (s *ReminderScheduler) tick(log *slog.Logger, now time.Time) {
+	calendar, err := s.calendar.GetCalendar(now.Year())
+	if err != nil {
+		log.Error("reminders: failed to get calendar", slog.Any("error", err))
+		return
+	}
+
+	for _, race := range calendar {
+		for _, session := range RaceSessions(race) {
+			if !now.Before(session.Start) || now.Before(session.Start.Add(-s.before)) {
+				continue
+			}
+			s.remind(log, race, session, now)
+		}
+	}
+}
+
+// remind отправляет напоминание о сессии в чаты, куда оно ещё не отправлялось
+func (s *ReminderScheduler) remind(log *slog.Logger, race models.Race, session models.Session, now time.Time) {
+	key := fmt.Sprintf("reminder:%s_%s:%s", race.Season, race.Round, session.Type)
+
+	for _, n := range s.notifiers {
+		chats, err := s.audience.ReminderChats(n.Platform(), session.Type)
+		if err != nil {
+			log.Error("reminders: failed to get chats", slog.String("platform", n.Platform()), slog.Any("error", err))
+			continue
+		}
+
+		for _, chatID := range chats {
+			sent, err := s.storage.IsNotificationSent(key, n.Platform(), chatID)
+			if err != nil {
+				log.Error("reminders: failed to check sent reminder", slog.String("key", key), slog.Any("error", err))
+				continue
+			}
+			if sent {
+				continue
+			}
+
+			// Неотправленное напоминание не отмечается и повторяется на следующей проверке
+			if err := n.SendToChat(log, chatID, reminderMessage(race, session, now, s.locations.ChatLocation(n.Platform(), chatID))); err != nil {
+				continue
+			}
+			if err := s.storage.MarkNotificationSent(key, n.Platform(), chatID); err != nil {
+				log.Error("reminders: failed to mark reminder sent", slog.String("key", key), slog.Any("error", err))
+			}
+			log.Info("Reminder sent", slog.String("key", key), slog.String("platform", n.Platform()), slog.Int64("chat_id", chatID))
+		}
+	}
+}
+
+// reminderMessage — "⏰ Miami Grand Prix: Квалификация начнётся через 1 ч — 03 мая 23:00 (МСК)",
+// время старта — в часовом поясе чата
+func reminderMessage(race models.Race, session models.Session, now time.Time, loc *time.Location) string {
+	return fmt.Sprintf("⏰ %s: %s начнётся через %s — %s (%s)",
+		race.RaceName, session.Type.Title(), formatRemaining(session.Start.Sub(now)), formatLocalTime(session.Start, loc), zoneLabel(session.Start, loc))
+}
+
+// formatRemaining форматирует оставшееся время с точностью до минуты: "1 ч", "1 ч 30 мин", "25 мин"
+func formatRemaining(d time.Duration) string {
+	minutes := int((d + time.Minute - 1) / time.Minute)
+	hours, minutes := minutes/60, minutes%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%d мин", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%d ч", hours)
+	}
+	return fmt.Sprintf("%d ч %d мин", hours, minutes)
+}
+
+// RaceSessions возвращает сессии этапа с известным временем старта в порядке начала
+func RaceSessions(race models.Race) []models.Session {
+	candidates := []struct {
+		sessionType models.SessionType
+		date, time  string
+	}{
+		{models.SessionFP1, race.FirstPractice.Date, race.FirstPractice.Time},
+		{models.SessionFP2, race.SecondPractice.Date, race.SecondPractice.Time},
+		{models.SessionFP3, race.ThirdPractice.Date, race.ThirdPractice.Time},
+		{models.SessionSprintQualifying, race.SprintQualifying.Date, race.SprintQualifying.Time},
+		{models.SessionSprint, race.Sprint.Date, race.Sprint.Time},
+		{models.SessionQualifying, race.Qualifying.Date, race.Qualifying.Time},
+		{models.SessionRace, race.Date, race.Time},
+	}
+
+	sessions := make([]models.Session, 0, len(candidates))
+	for _, c := range candidates {
+		if c.date == "" || c.time == "" {
+			continue
+		}
+		start, err := parseStringToTime(c.date, c.time)
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, models.Session{Type: c.sessionType, Start: start})
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Start.Before(sessions[j].Start) })
+	return sessions
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"racebot-vk/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFormatRemaining(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{25 * time.Minute, "25 мин"},
+		{24*time.Minute + 10*time.Second, "25 мин"},
+		{30 * time.Second, "1 мин"},
+		{time.Hour, "1 ч"},
+		{59*time.Minute + 30*time.Second, "1 ч"},
+		{90 * time.Minute, "1 ч 30 мин"},
+		{26*time.Hour + 5*time.Minute, "26 ч 5 мин"},
+	}
+
+	for _, tt := range tests {
+		if got := formatRemaining(tt.d); got != tt.want {
+			t.Errorf("formatRemaining(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestRaceSessions(t *testing.T) {
+	at := func(date, clock string) time.Time {
+		start, err := time.Parse("2006-01-02 15:04:05Z", date+" "+clock)
+		if err != nil {
+			t.Fatalf("parse %s %s: %v", date, clock, err)
+		}
+		return start
+	}
+
+	tests := []struct {
+		name string
+		race models.Race
+		want []models.Session
+	}{
+		{
+			name: "обычный уикенд",
+			race: models.Race{
+				Date: "2025-04-06", Time: "05:00:00Z",
+				FirstPractice:  models.FirstPractice{Date: "2025-04-04", Time: "02:30:00Z"},
+				SecondPractice: models.SecondPractice{Date: "2025-04-04", Time: "06:00:00Z"},
+				ThirdPractice:  models.ThirdPractice{Date: "2025-04-05", Time: "02:30:00Z"},
+				Qualifying:     models.Qualifying{Date: "2025-04-05", Time: "06:00:00Z"},
+			},
+			want: []models.Session{
+				{Type: models.SessionFP1, Start: at("2025-04-04", "02:30:00Z")},
+				{Type: models.SessionFP2, Start: at("2025-04-04", "06:00:00Z")},
+				{Type: models.SessionFP3, Start: at("2025-04-05", "02:30:00Z")},
+				{Type: models.SessionQualifying, Start: at("2025-04-05", "06:00:00Z")},
+				{Type: models.SessionRace, Start: at("2025-04-06", "05:00:00Z")},
+			},
+		},
+		{
+			name: "спринт-уикенд по времени старта",
+			race: models.Race{
+				Date: "2025-05-04", Time: "20:00:00Z",
+				FirstPractice:    models.FirstPractice{Date: "2025-05-02", Time: "16:30:00Z"},
+				SprintQualifying: models.SprintQualifying{Date: "2025-05-02", Time: "20:30:00Z"},
+				Sprint:           models.Sprint{Date: "2025-05-03", Time: "16:00:00Z"},
+				Qualifying:       models.Qualifying{Date: "2025-05-03", Time: "20:00:00Z"},
+			},
+			want: []models.Session{
+				{Type: models.SessionFP1, Start: at("2025-05-02", "16:30:00Z")},
+				{Type: models.SessionSprintQualifying, Start: at("2025-05-02", "20:30:00Z")},
+				{Type: models.SessionSprint, Start: at("2025-05-03", "16:00:00Z")},
+				{Type: models.SessionQualifying, Start: at("2025-05-03", "20:00:00Z")},
+				{Type: models.SessionRace, Start: at("2025-05-04", "20:00:00Z")},
+			},
+		},
+		{
+			name: "без времени и с неверным временем",
+			race: models.Race{
+				Date:          "1988-04-03",
+				FirstPractice: models.FirstPractice{Date: "1988-04-01", Time: "полдень"},
+				Qualifying:    models.Qualifying{Date: "1988-04-02", Time: "12:00:00Z"},
+			},
+			want: []models.Session{
+				{Type: models.SessionQualifying, Start: at("1988-04-02", "12:00:00Z")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RaceSessions(tt.race)
+			if len(got) != len(tt.want) {
+				t.Fatalf("RaceSessions() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Type != tt.want[i].Type || !got[i].Start.Equal(tt.want[i].Start) {
+					t.Errorf("session %d = %s %s, want %s %s", i, got[i].Type, got[i].Start, tt.want[i].Type, tt.want[i].Start)
+				}
+			}
+		})
+	}
+}
+
+// flakyNotifier не доставляет первое сообщение в чаты из failOnce
+type flakyNotifier struct {
+	failOnce map[int64]bool
+	sent     []int64
+}
+
+func (n *flakyNotifier) SendToChat(_ *slog.Logger, chatID int64, _ string) error {
+	if n.failOnce[chatID] {
+		delete(n.failOnce, chatID)
+		return errors.New("vk: too many requests")
+	}
+	n.sent = append(n.sent, chatID)
+	return nil
+}
+
+func (n *flakyNotifier) Platform() string {
+	return models.PlatformVK
+}
+
+// fixedChats — одни и те же чаты для любой рассылки
+type fixedChats []int64
+
+func (c fixedChats) ReminderChats(string, models.SessionType) ([]int64, error) {
+	return c, nil
+}
+
+func (c fixedChats) Chats(string, models.Topic) ([]int64, error) {
+	return c, nil
+}
+
+func (c fixedChats) ChatLocation(string, int64) *time.Location {
+	return time.UTC
+}
+
+// fixedCalendar — календарь из одного этапа
+type fixedCalendar []models.Race
+
+func (c fixedCalendar) GetCalendar(int) ([]models.Race, error) {
+	return c, nil
+}
+
+// Недоставленное напоминание не отмечается отправленным и уходит на следующей проверке,
+// доставленные не повторяются
+func TestReminderRetriesFailedChats(t *testing.T) {
+	storage := newTestStorage(t)
+	race := models.Race{Season: "2025", Round: "6", RaceName: "Miami Grand Prix", Date: "2025-05-04", Time: "20:00:00Z"}
+	chats := fixedChats{2000000001, 2000000002}
+	notifier := &flakyNotifier{failOnce: map[int64]bool{2000000002: true}}
+	scheduler := NewReminderScheduler(fixedCalendar{race}, storage, chats, chats, time.Hour, time.Minute, notifier)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	now := time.Date(2025, 5, 4, 19, 30, 0, 0, time.UTC)
+
+	scheduler.tick(log, now)
+	if !slices.Equal(notifier.sent, []int64{2000000001}) {
+		t.Fatalf("first tick: sent to %v, want [2000000001]", notifier.sent)
+	}
+
+	notifier.sent = nil
+	scheduler.tick(log, now.Add(time.Minute))
+	if !slices.Equal(notifier.sent, []int64{2000000002}) {
+		t.Fatalf("second tick: sent to %v, want [2000000002]", notifier.sent)
+	}
+
+	notifier.sent = nil
+	scheduler.tick(log, now.Add(2*time.Minute))
+	if len(notifier.sent) != 0 {
+		t.Fatalf("third tick: sent to %v, want none", notifier.sent)
+	}
+}
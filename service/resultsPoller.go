@@ -0,0 +1,173 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	predStorage "racebot-vk/storage/prediction"
+	"racebot-vk/temperrors"
+	"strconv"
+	"time"
+)
+
+// Сколько после старта сессии ждать появления результатов в Ergast
+const resultsPollWindow = 48 * time.Hour
+
+// Сессии с результатами в Ergast; до окончания сессии результаты не ищутся
+var resultSessions = map[models.SessionType]bool{
+	models.SessionQualifying: true,
+	models.SessionSprint:     true,
+	models.SessionRace:       true,
+}
+
+// freshResultsSource запрашивает результаты сессий в обход кэша
+type freshResultsSource interface {
+	FetchRaceResults(season int, round string) ([]models.Race, error)
+	FetchQualifyingResults(season int, round string) ([]models.Race, error)
+	FetchSprintResults(season int, round string) ([]models.Race, error)
+}
+
+// ResultsPoller следит за завершившимися сессиями текущего уикенда и, как только Ergast
+// публикует результаты, отправляет их в чаты, подписанные на результаты. Результаты каждой
+// сессии рассылаются один раз: отметки хранятся в БД и переживают перезапуск.
+type ResultsPoller struct {
+	calendar  calendarSource
+	results   freshResultsSource
+	storage   *predStorage.Storage
+	audience  ChatAudience
+	notifiers []Notifier
+	interval  time.Duration
+}
+
+func NewResultsPoller(calendar calendarSource, results freshResultsSource, storage *predStorage.Storage, audience ChatAudience, interval time.Duration, notifiers ...Notifier) *ResultsPoller {
+	return &ResultsPoller{
+		calendar:  calendar,
+		results:   results,
+		storage:   storage,
+		audience:  audience,
+		notifiers: notifiers,
+		interval:  interval,
+	}
+}
+
+// Run запускает опрос результатов (блокирующий вызов)
+func (p *ResultsPoller) Run(log *slog.Logger) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	log.Info("Start results poller", slog.Duration("interval", p.interval))
+	p.tick(log, time.Now())
+
+	for t := range ticker.C {
+		p.tick(log, t)
+	}
+}
+
+// tick проверяет сессии, которые уже закончились, но не раньше resultsPollWindow назад
+func (p *ResultsPoller) tick(log *slog.Logger, now time.Time) {
+	calendar, err := p.calendar.GetCalendar(now.Year())
+	if err != nil {
+		log.Error("results: failed to get calendar", slog.Any("error", err))
+		return
+	}
+
+	for _, race := range calendar {
+		for _, session := range RaceSessions(race) {
+			if !resultSessions[session.Type] || now.Before(session.Start.Add(session.Type.Duration())) || now.After(session.Start.Add(resultsPollWindow)) {
+				continue
+			}
+			if err := p.poll(log, race, session); err != nil {
+				log.Error("results: failed to poll session", slog.String("race", race.RaceName), slog.String("session", string(session.Type)), slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// poll запрашивает результаты сессии и при первом появлении рассылает их подписчикам
+func (p *ResultsPoller) poll(log *slog.Logger, race models.Race, session models.Session) error {
+	key := fmt.Sprintf("results:%s_%s:%s", race.Season, race.Round, session.Type)
+
+	// Отметка без чата — результаты сессии уже разосланы всем подписчикам
+	published, err := p.storage.IsNotificationSent(key, "", 0)
+	if err != nil || published {
+		return err
+	}
+
+	message, err := p.resultsMessage(race, session.Type)
+	if err != nil {
+		if errors.Is(err, temperrors.ErrEmptyList) {
+			// Результаты ещё не опубликованы
+			return nil
+		}
+		return err
+	}
+
+	failed := 0
+	for _, n := range p.notifiers {
+		chats, err := p.audience.Chats(n.Platform(), models.TopicResults)
+		if err != nil {
+			log.Error("results: failed to get chats", slog.String("platform", n.Platform()), slog.Any("error", err))
+			failed++
+			continue
+		}
+
+		for _, chatID := range chats {
+			sent, err := p.storage.IsNotificationSent(key, n.Platform(), chatID)
+			if err != nil {
+				log.Error("results: failed to check sent results", slog.String("key", key), slog.Any("error", err))
+				continue
+			}
+			if sent {
+				continue
+			}
+
+			if err := n.SendToChat(log, chatID, message); err != nil {
+				failed++
+				continue
+			}
+			if err := p.storage.MarkNotificationSent(key, n.Platform(), chatID); err != nil {
+				log.Error("results: failed to mark results sent", slog.String("key", key), slog.Any("error", err))
+			}
+		}
+	}
+
+	// Чаты, куда результаты не ушли, получат их на следующей проверке: отметки отдельных
+	// чатов не дают отправить результаты повторно
+	if failed > 0 {
+		return fmt.Errorf("results %s: %d deliveries failed", key, failed)
+	}
+	log.Info("Session results published", slog.String("key", key))
+	return p.storage.MarkNotificationSent(key, "", 0)
+}
+
+// resultsMessage запрашивает результаты сессии в обход кэша и форматирует их;
+// temperrors.ErrEmptyList — результатов ещё нет
+func (p *ResultsPoller) resultsMessage(race models.Race, session models.SessionType) (string, error) {
+	season, err := strconv.Atoi(race.Season)
+	if err != nil {
+		return "", fmt.Errorf("invalid season %q: %w", race.Season, temperrors.ErrParse)
+	}
+
+	switch session {
+	case models.SessionQualifying:
+		results, err := p.results.FetchQualifyingResults(season, race.Round)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("🏁 Результаты квалификации %s %s:\n%s", results[0].RaceName, results[0].Season, qualifyingResultsToString(results[0])), nil
+	case models.SessionSprint:
+		results, err := p.results.FetchSprintResults(season, race.Round)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("🏁 Результаты спринт-гонки %s %s:\n%s", results[0].RaceName, results[0].Season, sprintResultsToString(results[0])), nil
+	case models.SessionRace:
+		results, err := p.results.FetchRaceResults(season, race.Round)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("🏁 Результаты гонки F1 %s %s:\n%s", results[0].RaceName, results[0].Season, raceResultsToString(results[0])), nil
+	}
+	return "", fmt.Errorf("session %q has no results: %w", session, temperrors.ErrEmptyList)
+}
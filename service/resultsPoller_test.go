@@ -0,0 +1,65 @@
+package service
+
+import (
+	"io"
+	"log/slog"
+	"racebot-vk/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeFreshResults отдаёт результаты гонки; квалификации и спринта нет
+type fakeFreshResults struct {
+	race models.Race
+}
+
+func (f fakeFreshResults) FetchRaceResults(int, string) ([]models.Race, error) {
+	return []models.Race{f.race}, nil
+}
+
+func (f fakeFreshResults) FetchQualifyingResults(int, string) ([]models.Race, error) {
+	return nil, nil
+}
+
+func (f fakeFreshResults) FetchSprintResults(int, string) ([]models.Race, error) {
+	return nil, nil
+}
+
+// Результаты сессии не считаются разосланными, пока не доставлены во все чаты
+func TestResultsPollRetriesFailedChats(t *testing.T) {
+	storage := newTestStorage(t)
+	race := models.Race{Season: "2025", Round: "6", RaceName: "Miami Grand Prix", Date: "2025-05-04", Time: "20:00:00Z"}
+	results := race
+	results.Results = []models.Result{
+		{Number: "81", Position: "1", Points: "25", Status: "Finished", Driver: models.Driver{GivenName: "Oscar", FamilyName: "Piastri"}},
+	}
+	chats := fixedChats{2000000001, 2000000002}
+	notifier := &flakyNotifier{failOnce: map[int64]bool{2000000001: true}}
+	poller := NewResultsPoller(fixedCalendar{race}, fakeFreshResults{race: results}, storage, chats, time.Minute, notifier)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	session := models.Session{Type: models.SessionRace, Start: time.Date(2025, 5, 4, 20, 0, 0, 0, time.UTC)}
+
+	if err := poller.poll(log, race, session); err == nil {
+		t.Fatal("first poll: want delivery error")
+	}
+	if !slices.Equal(notifier.sent, []int64{2000000002}) {
+		t.Fatalf("first poll: sent to %v, want [2000000002]", notifier.sent)
+	}
+
+	notifier.sent = nil
+	if err := poller.poll(log, race, session); err != nil {
+		t.Fatalf("second poll: %v", err)
+	}
+	if !slices.Equal(notifier.sent, []int64{2000000001}) {
+		t.Fatalf("second poll: sent to %v, want [2000000001]", notifier.sent)
+	}
+
+	notifier.sent = nil
+	if err := poller.poll(log, race, session); err != nil {
+		t.Fatalf("third poll: %v", err)
+	}
+	if len(notifier.sent) != 0 {
+		t.Fatalf("third poll: sent to %v, want none", notifier.sent)
+	}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"fmt"
+	"racebot-vk/models"
+	predStorage "racebot-vk/storage/prediction"
+	"racebot-vk/temperrors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Сколько кэшируется список администраторов чата, полученный с платформы
+const chatAdminsTTL = 10 * time.Minute
+
+// Названия ролей в командах (VK и Telegram)
+var roleNames = map[string]models.Role{
+	"владелец":      models.RoleOwner,
+	"owner":         models.RoleOwner,
+	"админ":         models.RoleAdmin,
+	"администратор": models.RoleAdmin,
+	"admin":         models.RoleAdmin,
+	"модератор":     models.RoleModerator,
+	"модер":         models.RoleModerator,
+	"moderator":     models.RoleModerator,
+}
+
+// ChatAdminSource получает администраторов чата с платформы (VK messages.getConversationMembers,
+// Telegram getChatAdministrators). Для личных диалогов возвращает пустой список.
+type ChatAdminSource interface {
+	GetChatAdmins(chatID int64) ([]int, error)
+}
+
+type chatAdminsEntry struct {
+	admins    []int
+	fetchedAt time.Time
+}
+
+// RoleService проверяет права пользователей. Роль складывается из трёх источников:
+// роли из конфигурации (глобальные, не снимаются командами), роли, выданные командами
+// (глобально или в одном чате), и статус администратора чата на платформе —
+// он даёт роль администратора в этом чате.
+type RoleService struct {
+	storage    *predStorage.Storage
+	static     []models.UserRole
+	chatAdmins map[string]ChatAdminSource
+
+	mu    sync.Mutex
+	cache map[string]chatAdminsEntry
+}
+
+func NewRoleService(storage *predStorage.Storage, static []models.UserRole) *RoleService {
+	return &RoleService{
+		storage:    storage,
+		static:     static,
+		chatAdmins: make(map[string]ChatAdminSource),
+		cache:      make(map[string]chatAdminsEntry),
+	}
+}
+
+// SetChatAdminSource регистрирует источник администраторов чатов платформы.
+// Вызывается при старте до начала обработки сообщений.
+func (s *RoleService) SetChatAdminSource(platform string, source ChatAdminSource) {
+	s.chatAdmins[platform] = source
+}
+
+// UserRole возвращает старшую роль пользователя в чате (пустая роль — прав нет).
+// chatID = 0 — учитываются только глобальные роли.
+func (s *RoleService) UserRole(platform string, userID int, chatID int64) (models.Role, error) {
+	var best models.Role
+	promote := func(role models.Role) {
+		if role.Rank() > best.Rank() {
+			best = role
+		}
+	}
+
+	for _, r := range s.static {
+		if r.Platform == platform && r.UserID == userID {
+			promote(r.Role)
+		}
+	}
+
+	roles, err := s.storage.GetUserRoles(platform, userID, chatID)
+	if err != nil {
+		return best, err
+	}
+	for _, r := range roles {
+		promote(r.Role)
+	}
+
+	if chatID != 0 && best.Rank() < models.RoleAdmin.Rank() {
+		for _, id := range s.getChatAdmins(platform, chatID) {
+			if id == userID {
+				promote(models.RoleAdmin)
+			}
+		}
+	}
+	return best, nil
+}
+
+// HasRole проверяет, что у пользователя в чате есть роль не ниже role.
+// При ошибке получения ролей учитываются только уже найденные роли.
+func (s *RoleService) HasRole(platform string, userID int, chatID int64, role models.Role) bool {
+	current, _ := s.UserRole(platform, userID, chatID)
+	return current.Rank() >= role.Rank()
+}
+
+// GrantRole выдаёт пользователю роль в чате (chatID = 0 — во всех чатах).
+// Выдать можно только роль младше своей и только тому, чья роль младше вашей;
+// владельцы задаются только в конфигурации.
+func (s *RoleService) GrantRole(platform string, granterID, userID int, chatID int64, role models.Role) error {
+	if role == models.RoleOwner {
+		return fmt.Errorf("owners are set in configuration: %w", temperrors.ErrPermissionDenied)
+	}
+
+	granterRole, err := s.UserRole(platform, granterID, chatID)
+	if err != nil {
+		return err
+	}
+	if granterRole.Rank() <= role.Rank() {
+		return fmt.Errorf("%s cannot grant %s: %w", granterRole, role, temperrors.ErrPermissionDenied)
+	}
+	current, err := s.UserRole(platform, userID, chatID)
+	if err != nil {
+		return err
+	}
+	if current.Rank() >= granterRole.Rank() {
+		return fmt.Errorf("%s cannot change role of %s: %w", granterRole, current, temperrors.ErrPermissionDenied)
+	}
+
+	return s.storage.SetUserRole(&models.UserRole{
+		Platform:  platform,
+		UserID:    userID,
+		ChatID:    chatID,
+		Role:      role,
+		GrantedBy: granterID,
+	})
+}
+
+// RevokeRole снимает роль, выданную пользователю командой в чате (chatID = 0 — глобальную).
+// Возвращает снятую роль; temperrors.ErrRoleNotFound — если такой роли нет.
+func (s *RoleService) RevokeRole(platform string, granterID, userID int, chatID int64) (models.Role, error) {
+	roles, err := s.storage.GetUserRoles(platform, userID, chatID)
+	if err != nil {
+		return "", err
+	}
+	var role models.Role
+	for _, r := range roles {
+		if r.ChatID == chatID {
+			role = r.Role
+		}
+	}
+	if role == "" {
+		return "", temperrors.ErrRoleNotFound
+	}
+
+	granterRole, err := s.UserRole(platform, granterID, chatID)
+	if err != nil {
+		return "", err
+	}
+	if granterRole.Rank() <= role.Rank() {
+		return "", fmt.Errorf("%s cannot revoke %s: %w", granterRole, role, temperrors.ErrPermissionDenied)
+	}
+
+	if _, err := s.storage.DeleteUserRole(platform, userID, chatID); err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// GetRolesMessage формирует список ролей, действующих в чате, и роль пользователя
+func (s *RoleService) GetRolesMessage(platform string, userID int, chatID int64) (string, error) {
+	own, err := s.UserRole(platform, userID, chatID)
+	if err != nil {
+		return "", err
+	}
+
+	roles, err := s.storage.GetChatRoles(platform, chatID)
+	if err != nil {
+		return "", err
+	}
+	profiles, err := s.storage.GetUserProfiles()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user profiles: %w", err)
+	}
+
+	var sb strings.Builder
+	if own == "" {
+		sb.WriteString("У вас нет роли в этом чате.\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("Ваша роль: %s.\n", own.Title()))
+	}
+
+	sb.WriteString("\n👮 Роли бота:\n")
+	for _, r := range s.static {
+		if r.Platform == platform {
+			sb.WriteString(fmt.Sprintf("%s — %s (из конфигурации)\n", displayName(profiles, r.Platform, r.UserID), r.Role.Title()))
+		}
+	}
+	for _, r := range roles {
+		scope := "во всех чатах"
+		if r.ChatID != 0 {
+			scope = "в этом чате"
+		}
+		sb.WriteString(fmt.Sprintf("%s — %s %s\n", displayName(profiles, r.Platform, r.UserID), r.Role.Title(), scope))
+	}
+	sb.WriteString("\nАдминистраторы беседы — администраторы бота в этой беседе.")
+	return sb.String(), nil
+}
+
+// ParseRole разбирает название роли: "админ", "модератор", "admin", "moderator"
+func ParseRole(name string) (models.Role, error) {
+	role, ok := roleNames[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("role %q: %w", name, temperrors.ErrUnknownRole)
+	}
+	return role, nil
+}
+
+// getChatAdmins возвращает администраторов чата платформы (с кэшем на chatAdminsTTL).
+// Если список получить не удалось (например, у бота нет прав администратора в беседе),
+// администраторов чата нет — до следующего запроса через chatAdminsTTL.
+func (s *RoleService) getChatAdmins(platform string, chatID int64) []int {
+	source, ok := s.chatAdmins[platform]
+	if !ok {
+		return nil
+	}
+
+	key := fmt.Sprintf("%s:%d", platform, chatID)
+	s.mu.Lock()
+	entry, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < chatAdminsTTL {
+		return entry.admins
+	}
+
+	admins, err := source.GetChatAdmins(chatID)
+	if err != nil {
+		admins = nil
+	}
+
+	s.mu.Lock()
+	s.cache[key] = chatAdminsEntry{admins: admins, fetchedAt: time.Now()}
+	s.mu.Unlock()
+	return admins
+}
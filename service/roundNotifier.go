@@ -0,0 +1,133 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	predStorage "racebot-vk/storage/prediction"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Сколько повторять рассылку уведомления о раунде, которое дошло не до всех чатов
+const roundNotificationRetryWindow = 48 * time.Hour
+
+// chatMessage формирует текст уведомления для чата платформы
+type chatMessage func(platform string, chatID int64) string
+
+// roundNotifier рассылает автоматические уведомления о раундах прогнозов: объявления,
+// закрытие приёма и итоги. Уведомление ставится в очередь (pending_notifications), доставка
+// в каждый чат отмечается в sent_notifications, а пока уведомление дошло не до всех чатов,
+// его рассылка повторяется (retry); чаты, в которые оно уже ушло, его не получают повторно.
+type roundNotifier struct {
+	storage   *predStorage.Storage
+	audience  ChatAudience
+	notifiers []Notifier
+}
+
+// notify ставит уведомление key в очередь и сразу рассылает его. race — раунд уведомления:
+// общий раунд объявляется в чатах, подписанных на прогнозы, раунд чата — только в его чате.
+func (n *roundNotifier) notify(log *slog.Logger, key string, race *models.PredictionRace, message chatMessage) {
+	if err := n.storage.AddPendingNotification(key); err != nil {
+		log.Error("failed to queue notification", slog.String("key", key), slog.Any("error", err))
+	}
+	n.deliver(log, key, race, message)
+}
+
+// deliver отправляет уведомление key в чаты раунда, которые его ещё не получили. Когда
+// уведомление доставлено во все чаты, оно убирается из очереди.
+func (n *roundNotifier) deliver(log *slog.Logger, key string, race *models.PredictionRace, message chatMessage) {
+	failed := 0
+	for _, notifier := range n.notifiers {
+		chats, err := n.chats(notifier.Platform(), race)
+		if err != nil {
+			log.Error("failed to get subscribed chats", slog.String("platform", notifier.Platform()), slog.String("key", key), slog.Any("error", err))
+			failed++
+			continue
+		}
+
+		for _, chatID := range chats {
+			sent, err := n.storage.IsNotificationSent(key, notifier.Platform(), chatID)
+			if err != nil {
+				log.Error("failed to check sent notification", slog.String("key", key), slog.Any("error", err))
+				failed++
+				continue
+			}
+			if sent {
+				continue
+			}
+
+			if err := notifier.SendToChat(log, chatID, message(notifier.Platform(), chatID)); err != nil {
+				failed++
+				continue
+			}
+			if err := n.storage.MarkNotificationSent(key, notifier.Platform(), chatID); err != nil {
+				log.Error("failed to mark notification sent", slog.String("key", key), slog.Any("error", err))
+			}
+		}
+	}
+
+	if failed > 0 {
+		log.Warn("Notification not delivered to all chats, will retry", slog.String("key", key), slog.Int("failed", failed))
+		return
+	}
+	n.done(log, key)
+}
+
+// chats возвращает чаты платформы, в которые отправляется уведомление о раунде
+// (nil race — уведомление для всех чатов, подписанных на прогнозы)
+func (n *roundNotifier) chats(platform string, race *models.PredictionRace) ([]int64, error) {
+	if race != nil && race.ChatID != 0 {
+		if race.Platform != platform {
+			return nil, nil
+		}
+		return []int64{race.ChatID}, nil
+	}
+	return n.audience.Chats(platform, models.TopicPredictions)
+}
+
+// pendingRound — недоставленное уведомление о раунде
+type pendingRound struct {
+	key     string
+	roundID int
+}
+
+// pending возвращает недоставленные уведомления вида "<kind>:<id раунда>", поставленные
+// в очередь не раньше roundNotificationRetryWindow назад
+func (n *roundNotifier) pending(log *slog.Logger, kind string, now time.Time) []pendingRound {
+	keys, err := n.storage.GetPendingNotifications(kind+":", now.Add(-roundNotificationRetryWindow))
+	if err != nil {
+		log.Error("failed to get pending notifications", slog.String("kind", kind), slog.Any("error", err))
+		return nil
+	}
+
+	rounds := make([]pendingRound, 0, len(keys))
+	for _, key := range keys {
+		id, err := strconv.Atoi(strings.TrimPrefix(key, kind+":"))
+		if err != nil {
+			log.Warn("invalid pending notification", slog.String("key", key))
+			n.done(log, key)
+			continue
+		}
+		rounds = append(rounds, pendingRound{key: key, roundID: id})
+	}
+	return rounds
+}
+
+// done убирает уведомление из очереди
+func (n *roundNotifier) done(log *slog.Logger, key string) {
+	if err := n.storage.DeletePendingNotification(key); err != nil {
+		log.Error("failed to delete pending notification", slog.String("key", key), slog.Any("error", err))
+	}
+}
+
+// roundNotificationKey — ключ уведомления о раунде: "summary:12"
+func roundNotificationKey(kind string, roundID int) string {
+	return fmt.Sprintf("%s:%d", kind, roundID)
+}
+
+// sameMessage — одинаковый текст уведомления для всех чатов
+func sameMessage(message string) chatMessage {
+	return func(string, int64) string { return message }
+}
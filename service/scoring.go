@@ -0,0 +1,206 @@
+package service
+
+import (
+	"fmt"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"sort"
+	"strings"
+)
+
+// Имя правила подсчёта очков по умолчанию
+const DefaultScoringRule = "classic"
+
+// RaceOutcome — реальные итоги гонки, по которым считаются очки за прогнозы
+type RaceOutcome struct {
+	Podium         []uint8 // номера гонщиков на 1-3 местах
+	Classification []uint8 // полный финишный порядок (пуст, если результаты введены вручную)
+	// Ответы на дополнительные вопросы (пусты, если результаты введены вручную)
+	Extras models.PredictionExtras
+}
+
+// ScoringRule — правило подсчёта очков за прогноз.
+// Раунд прогнозов хранит имя правила, поэтому очки можно пересчитать в любой момент.
+type ScoringRule interface {
+	Name() string
+	Description() string
+	Score(pred models.Prediction, outcome RaceOutcome) int
+}
+
+// pointsRule — настраиваемое правило на основе весов за каждое попадание
+type pointsRule struct {
+	name         string
+	description  string
+	exact        int // гонщик на своей позиции подиума
+	podium       int // гонщик на подиуме, но не на своей позиции
+	top10        int // гонщик в топ-10, но не на подиуме (нужен полный финишный порядок)
+	perfectBonus int // бонус за полностью угаданный подиум
+	multiplier   int // множитель итоговых очков (джокер-гонки)
+	extras       extrasPoints
+}
+
+// extrasPoints — очки за дополнительные вопросы раунда
+type extrasPoints struct {
+	pole         int // поул-позиция
+	fastestLap   int // быстрый круг
+	firstDNF     int // первый сход
+	constructor  int // команда победителя
+	sprintExact  int // гонщик на своей позиции подиума спринта
+	sprintPodium int // гонщик на подиуме спринта, но не на своей позиции
+}
+
+// defaultExtrasPoints — очки за дополнительные вопросы во встроенных правилах
+var defaultExtrasPoints = extrasPoints{
+	pole:         2,
+	fastestLap:   2,
+	firstDNF:     3,
+	constructor:  2,
+	sprintExact:  2,
+	sprintPodium: 1,
+}
+
+// score считает очки за ответы на дополнительные вопросы
+func (e extrasPoints) score(pred, real models.PredictionExtras) int {
+	points := 0
+	if pred.Pole != 0 && pred.Pole == real.Pole {
+		points += e.pole
+	}
+	if pred.FastestLap != 0 && pred.FastestLap == real.FastestLap {
+		points += e.fastestLap
+	}
+	if pred.FirstDNF != 0 && pred.FirstDNF == real.FirstDNF {
+		points += e.firstDNF
+	}
+	if pred.Constructor != "" && NormalizeConstructor(pred.Constructor) == NormalizeConstructor(real.Constructor) {
+		points += e.constructor
+	}
+	for i, predicted := range pred.SprintPodium {
+		switch {
+		case i < len(real.SprintPodium) && predicted == real.SprintPodium[i]:
+			points += e.sprintExact
+		case contains(real.SprintPodium, predicted):
+			points += e.sprintPodium
+		}
+	}
+	return points
+}
+
+// String описывает очки за дополнительные вопросы
+func (e extrasPoints) String() string {
+	return fmt.Sprintf("поул — %d, быстрый круг — %d, первый сход — %d, команда победителя — %d, подиум спринта — %d за точное место и %d за гонщика на подиуме",
+		e.pole, e.fastestLap, e.firstDNF, e.constructor, e.sprintExact, e.sprintPodium)
+}
+
+func (r pointsRule) Name() string {
+	return r.name
+}
+
+func (r pointsRule) Description() string {
+	return r.description
+}
+
+func (r pointsRule) Score(pred models.Prediction, outcome RaceOutcome) int {
+	points := 0
+	perfect := true
+	predDrivers := []uint8{pred.Driver1, pred.Driver2, pred.Driver3}
+
+	for i, predicted := range predDrivers {
+		switch {
+		case i < len(outcome.Podium) && predicted == outcome.Podium[i]:
+			points += r.exact
+			continue
+		case contains(outcome.Podium, predicted):
+			points += r.podium
+		case r.top10 > 0 && contains(topN(outcome.Classification, 10), predicted):
+			points += r.top10
+		}
+		perfect = false
+	}
+
+	if perfect && r.perfectBonus > 0 {
+		points += r.perfectBonus
+	}
+	points += r.extras.score(pred.Extras, outcome.Extras)
+	if r.multiplier > 1 {
+		points *= r.multiplier
+	}
+	return points
+}
+
+// scoringRules — встроенные наборы правил
+var scoringRules = map[string]ScoringRule{
+	"classic": pointsRule{
+		name:        "classic",
+		description: "5 очков за точное место, 3 — за гонщика на подиуме не на своём месте",
+		exact:       5,
+		podium:      3,
+		extras:      defaultExtrasPoints,
+	},
+	"perfect": pointsRule{
+		name:         "perfect",
+		description:  "как classic + 5 бонусных очков за полностью угаданный подиум",
+		exact:        5,
+		podium:       3,
+		perfectBonus: 5,
+		extras:       defaultExtrasPoints,
+	},
+	"top10": pointsRule{
+		name:        "top10",
+		description: "как classic + 1 очко за гонщика, финишировавшего в топ-10 вне подиума",
+		exact:       5,
+		podium:      3,
+		top10:       1,
+		extras:      defaultExtrasPoints,
+	},
+	"joker": pointsRule{
+		name:         "joker",
+		description:  "джокер-гонка: очки по правилам perfect удваиваются",
+		exact:        5,
+		podium:       3,
+		perfectBonus: 5,
+		multiplier:   2,
+		extras:       defaultExtrasPoints,
+	},
+}
+
+// GetScoringRule возвращает правило подсчёта очков по имени
+func GetScoringRule(name string) (ScoringRule, error) {
+	if name == "" {
+		name = DefaultScoringRule
+	}
+	rule, ok := scoringRules[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", temperrors.ErrUnknownScoringRule, name)
+	}
+	return rule, nil
+}
+
+// GetScoringRulesMessage формирует список доступных правил подсчёта очков
+func GetScoringRulesMessage() string {
+	names := make([]string, 0, len(scoringRules))
+	for name := range scoringRules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("Доступные правила подсчёта очков:\n")
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("• %s — %s\n", name, scoringRules[name].Description()))
+	}
+	sb.WriteString(fmt.Sprintf("\nДополнительные вопросы: %s.", defaultExtrasPoints))
+	return sb.String()
+}
+
+// NormalizeConstructor приводит название команды к виду для сравнения:
+// "Red Bull", "red_bull" и "redbull" считаются одной командой
+func NormalizeConstructor(name string) string {
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+}
+
+func topN(classification []uint8, n int) []uint8 {
+	if len(classification) < n {
+		return classification
+	}
+	return classification[:n]
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"testing"
+)
+
+func TestPointsRuleScore(t *testing.T) {
+	// Подиум 1-4-16, дальше в топ-10: 81, 63, 44
+	outcome := RaceOutcome{
+		Podium:         []uint8{1, 4, 16},
+		Classification: []uint8{1, 4, 16, 81, 63, 44, 55, 14, 18, 10, 22},
+	}
+	manual := RaceOutcome{Podium: []uint8{1, 4, 16}}
+	podium := func(d1, d2, d3 uint8) models.Prediction {
+		return models.Prediction{Driver1: d1, Driver2: d2, Driver3: d3}
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		pred    models.Prediction
+		outcome RaceOutcome
+		want    int
+	}{
+		{"classic: точный подиум", "classic", podium(1, 4, 16), outcome, 15},
+		{"classic: подиум не по порядку", "classic", podium(4, 16, 1), outcome, 9},
+		{"classic: одно точное место", "classic", podium(1, 81, 63), outcome, 5},
+		{"classic: мимо подиума", "classic", podium(81, 63, 44), outcome, 0},
+		{"perfect: бонус за точный подиум", "perfect", podium(1, 4, 16), outcome, 20},
+		{"perfect: без бонуса", "perfect", podium(1, 16, 4), outcome, 11},
+		{"top10: гонщики в топ-10", "top10", podium(1, 81, 22), outcome, 6},
+		{"top10: ручной ввод без протокола", "top10", podium(1, 81, 63), manual, 5},
+		{"joker: удвоение с бонусом", "joker", podium(1, 4, 16), outcome, 40},
+		{"joker: удвоение без бонуса", "joker", podium(4, 1, 81), outcome, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := GetScoringRule(tt.rule)
+			if err != nil {
+				t.Fatalf("GetScoringRule(%q): %v", tt.rule, err)
+			}
+			if got := rule.Score(tt.pred, tt.outcome); got != tt.want {
+				t.Errorf("%s.Score(%d %d %d) = %d, want %d", tt.rule, tt.pred.Driver1, tt.pred.Driver2, tt.pred.Driver3, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetScoringRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		{"", DefaultScoringRule, nil},
+		{"perfect", "perfect", nil},
+		{"JOKER", "joker", nil},
+		{"fantasy", "", temperrors.ErrUnknownScoringRule},
+	}
+
+	for _, tt := range tests {
+		rule, err := GetScoringRule(tt.name)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetScoringRule(%q) error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || rule.Name() != tt.want {
+			t.Errorf("GetScoringRule(%q) = %v, %v; want %s", tt.name, rule, err, tt.want)
+		}
+	}
+}
+
+func TestExtrasPointsScore(t *testing.T) {
+	actual := models.PredictionExtras{
+		Pole:         1,
+		FastestLap:   4,
+		FirstDNF:     63,
+		Constructor:  "red_bull",
+		SprintPodium: []uint8{81, 4, 1},
+	}
+
+	tests := []struct {
+		name   string
+		pred   models.PredictionExtras
+		actual models.PredictionExtras
+		want   int
+	}{
+		{"без ответов", models.PredictionExtras{}, actual, 0},
+		{"поул", models.PredictionExtras{Pole: 1}, actual, 2},
+		{"быстрый круг мимо", models.PredictionExtras{FastestLap: 1}, actual, 0},
+		{"первый сход", models.PredictionExtras{FirstDNF: 63}, actual, 3},
+		{"команда другим написанием", models.PredictionExtras{Constructor: "Red Bull"}, actual, 2},
+		{"команда мимо", models.PredictionExtras{Constructor: "ferrari"}, actual, 0},
+		{"точный подиум спринта", models.PredictionExtras{SprintPodium: []uint8{81, 4, 1}}, actual, 6},
+		{"подиум спринта не по порядку", models.PredictionExtras{SprintPodium: []uint8{1, 4, 16}}, actual, 3},
+		{"все ответы верны", actual, actual, 15},
+		// Вопрос без реального ответа (ручной ввод результатов) очков не даёт
+		{"нет реальных ответов", models.PredictionExtras{Pole: 1, FirstDNF: 63, Constructor: "red_bull"}, models.PredictionExtras{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultExtrasPoints.score(tt.pred, tt.actual); got != tt.want {
+				t.Errorf("score(%+v) = %d, want %d", tt.pred, got, tt.want)
+			}
+		})
+	}
+}
+
+// Очки за дополнительные вопросы добавляются к подиуму и удваиваются в джокер-гонке
+func TestPointsRuleScoreWithExtras(t *testing.T) {
+	outcome := RaceOutcome{
+		Podium: []uint8{1, 4, 16},
+		Extras: models.PredictionExtras{Pole: 1, FirstDNF: 63},
+	}
+	pred := models.Prediction{Driver1: 1, Driver2: 16, Driver3: 81, Extras: models.PredictionExtras{Pole: 1, FirstDNF: 63}}
+
+	for rule, want := range map[string]int{"classic": 13, "joker": 26} {
+		r, err := GetScoringRule(rule)
+		if err != nil {
+			t.Fatalf("GetScoringRule(%q): %v", rule, err)
+		}
+		if got := r.Score(pred, outcome); got != want {
+			t.Errorf("%s.Score() = %d, want %d", rule, got, want)
+		}
+	}
+}
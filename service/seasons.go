@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"racebot-vk/temperrors"
+	"strconv"
+	"time"
+)
+
+// FirstSeason — первый сезон чемпионата мира, с него начинаются данные Ergast
+const FirstSeason = 1950
+
+// Больше этапов в сезоне не бывало, номер этапа выше — опечатка
+const maxSeasonRound = 30
+
+// ValidateSeason проверяет, что Ergast хранит данные сезона: с 1950 года по текущий
+func ValidateSeason(season int, now time.Time) error {
+	if season < FirstSeason || season > now.Year() {
+		return fmt.Errorf("season %d: %w", season, temperrors.ErrSeasonOutOfRange)
+	}
+	return nil
+}
+
+// ParseSeasonArgs разбирает аргументы команды "[сезон [этап]]": без аргументов — текущий
+// сезон и последний этап ("last"). Сезон проверяется ValidateSeason, этап — от 1 до 30.
+func ParseSeasonArgs(args []string, now time.Time) (season int, round string, err error) {
+	season, round = now.Year(), "last"
+	if len(args) > 2 {
+		return 0, "", fmt.Errorf("too many arguments %q: %w", args, temperrors.ErrParse)
+	}
+
+	if len(args) > 0 {
+		season, err = strconv.Atoi(args[0])
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid season %q: %w", args[0], temperrors.ErrParse)
+		}
+		if err := ValidateSeason(season, now); err != nil {
+			return 0, "", err
+		}
+	}
+
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > maxSeasonRound {
+			return 0, "", fmt.Errorf("invalid round %q: %w", args[1], temperrors.ErrParse)
+		}
+		round = strconv.Itoa(n)
+	}
+	return season, round, nil
+}
+
+// SeasonRangeMessage — подсказка о доступных сезонах для ответа на неверный год
+func SeasonRangeMessage(now time.Time) string {
+	return fmt.Sprintf("Данные есть за сезоны с %d по %d год.", FirstSeason, now.Year())
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"errors"
+	"racebot-vk/temperrors"
+	"testing"
+	"time"
+)
+
+func TestParseSeasonArgs(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		args    []string
+		season  int
+		round   string
+		wantErr error
+	}{
+		{"без аргументов", nil, 2025, "last", nil},
+		{"сезон", []string{"2008"}, 2008, "last", nil},
+		{"сезон и этап", []string{"2021", "22"}, 2021, "22", nil},
+		{"этап с нулём", []string{"2021", "05"}, 2021, "5", nil},
+		{"первый сезон", []string{"1950"}, 1950, "last", nil},
+		{"сезон раньше 1950", []string{"1949"}, 0, "", temperrors.ErrSeasonOutOfRange},
+		{"будущий сезон", []string{"2026"}, 0, "", temperrors.ErrSeasonOutOfRange},
+		{"малое число", []string{"2"}, 0, "", temperrors.ErrSeasonOutOfRange},
+		{"слово вместо сезона", []string{"раза"}, 0, "", temperrors.ErrParse},
+		{"нулевой этап", []string{"2021", "0"}, 0, "", temperrors.ErrParse},
+		{"этап больше 30", []string{"2021", "31"}, 0, "", temperrors.ErrParse},
+		{"лишний аргумент", []string{"2021", "1", "2"}, 0, "", temperrors.ErrParse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			season, round, err := ParseSeasonArgs(tt.args, now)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseSeasonArgs(%q) error = %v, want %v", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSeasonArgs(%q) error = %v", tt.args, err)
+			}
+			if season != tt.season || round != tt.round {
+				t.Errorf("ParseSeasonArgs(%q) = %d, %q; want %d, %q", tt.args, season, round, tt.season, tt.round)
+			}
+		})
+	}
+}
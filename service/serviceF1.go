@@ -28,26 +28,37 @@ var months = map[string]string{
 }
 
 type f1Storage interface {
-	GetDriversList(userDate time.Time) ([]models.Driver, error)
-	GetDriverStandings(userDate time.Time) ([]models.DriverStandingsItem, error)
+	GetDriversList(season int) ([]models.Driver, error)
+	GetDriverStandings(season int, round string) ([]models.DriverStandingsItem, error)
 	GetCalendar(year int) ([]models.Race, error)
-	GetConstructorStandings(userDate time.Time) ([]models.ConstructorStandingsItem, error)
-	GetRaceResults(userDate time.Time, raceId string) ([]models.Race, error)
-	GetGPInfo(userDate time.Time, raceId string) ([]models.Race, error)
-	GetQualifyingResults(userDate time.Time, raceId string) ([]models.Race, error)
-	GetSprintResults(userDate time.Time, raceId string) []models.Race
+	GetConstructorStandings(season int, round string) ([]models.ConstructorStandingsItem, error)
+	GetRaceResults(season int, raceId string) ([]models.Race, error)
+	GetGPInfo(season int, raceId string) ([]models.Race, error)
+	GetQualifyingResults(season int, raceId string) ([]models.Race, error)
+	GetSprintResults(season int, raceId string) []models.Race
+	GetDriver(driverID string) (models.Driver, error)
+	CountDriverResults(driverID string, position int) (int, error)
+	CountDriverPoles(driverID string) (int, error)
+	GetConstructorsList(season int) ([]models.Constructors, error)
+	GetConstructor(constructorID string) (models.Constructors, error)
+	GetConstructorDrivers(season int, constructorID string) ([]models.Driver, error)
+	GetConstructorResults(season int, constructorID string) ([]models.Race, error)
+	GetConstructorTitles(constructorID string) ([]models.StandingsListItem, error)
+	CountConstructorSeasons(constructorID string) (int, error)
+	CountConstructorWins(constructorID string) (int, error)
 }
 
 type ServiceF1 struct {
-	storage f1Storage
+	storage       f1Storage
+	carouselPhoto string // фото карточек карусели этапов: "-219009582_457239025"
 }
 
-func NewServiceF1(storage f1Storage) *ServiceF1 {
-	return &ServiceF1{storage}
+func NewServiceF1(storage f1Storage, carouselPhoto string) *ServiceF1 {
+	return &ServiceF1{storage: storage, carouselPhoto: carouselPhoto}
 }
 
-func (s *ServiceF1) GetDriversListMessage(userDate time.Time) (string, error) {
-	drivers, err := s.storage.GetDriversList(userDate)
+func (s *ServiceF1) GetDriversListMessage(season int) (string, error) {
+	drivers, err := s.storage.GetDriversList(season)
 	if err != nil {
 		if errors.Is(err, temperrors.ErrEmptyList) {
 			return "Список гонщиков еще не сформирован.", nil
@@ -58,17 +69,22 @@ func (s *ServiceF1) GetDriversListMessage(userDate time.Time) (string, error) {
 	return fmt.Sprintf("Гонщики и их номера: \n%s", driversToString(drivers)), nil
 }
 
-func (s *ServiceF1) GetDriverStandingsMessage(userDate time.Time) (string, error) {
-	driversTable, err := s.storage.GetDriverStandings(userDate)
+// GetDriverStandingsMessage возвращает личный зачёт сезона после этапа round
+// ("last" — после последнего прошедшего)
+func (s *ServiceF1) GetDriverStandingsMessage(season int, round string) (string, error) {
+	driversTable, err := s.storage.GetDriverStandings(season, round)
 	if err != nil {
 		if errors.Is(err, temperrors.ErrEmptyList) {
+			if round != "last" {
+				return fmt.Sprintf("Личного зачёта после этапа №%s сезона %d нет.", round, season), nil
+			}
 			return "Личный зачёт еще не сформирован.", nil
 		}
 		slog.Error("failed to get driver standings", slog.Any("error", err))
 		return "", err
 	}
 
-	race, err := s.storage.GetGPInfo(userDate, "last")
+	race, err := s.storage.GetGPInfo(season, round)
 	if err != nil {
 		slog.Error("failed to get GP info", slog.Any("error", err))
 		return "", err
@@ -77,7 +93,8 @@ func (s *ServiceF1) GetDriverStandingsMessage(userDate time.Time) (string, error
 	return fmt.Sprintf("Личный зачёт F1\nПосле этапа №%s %s сезон %s: \n%s", race[0].Round, race[0].RaceName, race[0].Season, driversStandToString(driversTable)), nil
 }
 
-func (s *ServiceF1) GetCalendarMessage(year int) (string, error) {
+// GetCalendarMessage возвращает календарь сезона со временем этапов в часовом поясе loc
+func (s *ServiceF1) GetCalendarMessage(year int, loc *time.Location) (string, error) {
 	calendar, err := s.storage.GetCalendar(year)
 	if err != nil {
 
@@ -87,15 +104,20 @@ func (s *ServiceF1) GetCalendarMessage(year int) (string, error) {
 		slog.Error("failed to get calendar", slog.Any("error", err))
 		return "", err
 	}
-	return fmt.Sprintf("Календарь F1, сезон %d:\n%s", year, racesToString(calendar)), nil
+	return fmt.Sprintf("Календарь F1, сезон %d (время %s):\n%s", year, zoneLabel(time.Now(), loc), racesToString(calendar, loc)), nil
 }
 
-func (s *ServiceF1) GetNextRaceMessage(userDate time.Time, userTimestamp int) (string, error) {
+// GetNextRaceMessage возвращает расписание следующего этапа в часовом поясе loc
+func (s *ServiceF1) GetNextRaceMessage(userDate time.Time, userTimestamp int, loc *time.Location) (string, error) {
 	nextRace, err := s.GetNextRace(userDate, userTimestamp)
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Cледующий гран-при :\n%s", raceFullInfoToString(formatDateTime(nextRace))), nil
+	msg := fmt.Sprintf("Cледующий гран-при (время %s):\n%s", zoneLabel(userDate, loc), raceFullInfoToString(formatDateTime(nextRace, loc)))
+	if countdown := nextSessionLine(nextRace, userDate); countdown != "" {
+		msg += "\n" + countdown
+	}
+	return msg, nil
 }
 
 func (s *ServiceF1) GetNextRace(userDate time.Time, userTimestamp int) (models.Race, error) {
@@ -120,11 +142,16 @@ func (s *ServiceF1) GetNextRace(userDate time.Time, userTimestamp int) (models.R
 	return FindNextRace(int64(userTimestamp), calendar)
 }
 
-func (s *ServiceF1) GetConstructorStandingsMessage(userDate time.Time) (string, error) {
-	constStr, err := s.storage.GetConstructorStandings(userDate)
+// GetConstructorStandingsMessage возвращает кубок конструкторов сезона после этапа round
+// ("last" — после последнего прошедшего)
+func (s *ServiceF1) GetConstructorStandingsMessage(season int, round string) (string, error) {
+	constStr, err := s.storage.GetConstructorStandings(season, round)
 	if err != nil {
 
 		if errors.Is(err, temperrors.ErrEmptyList) {
+			if round != "last" {
+				return fmt.Sprintf("Кубка конструкторов после этапа №%s сезона %d нет.", round, season), nil
+			}
 			return "Кубок конструктора еще не сформирован.", nil
 		}
 		slog.Error("failed to get constructor standings", slog.Any("error", err))
@@ -132,7 +159,7 @@ func (s *ServiceF1) GetConstructorStandingsMessage(userDate time.Time) (string,
 
 	}
 
-	race, err := s.storage.GetGPInfo(userDate, "last")
+	race, err := s.storage.GetGPInfo(season, round)
 	if err != nil {
 		slog.Error("failed to get GP info", slog.Any("error", err))
 		return "", err
@@ -141,14 +168,19 @@ func (s *ServiceF1) GetConstructorStandingsMessage(userDate time.Time) (string,
 	return fmt.Sprintf("Кубок конструкторов F1\nПосле этапа №%s %s, сезон %s:\n%s", race[0].Round, race[0].RaceName, race[0].Season, constructorsToString(constStr)), nil
 }
 
-func (s *ServiceF1) GetRaceResultsMessage(userDate time.Time, raceId string) (string, error) {
-	results, err := s.storage.GetRaceResults(userDate, raceId)
+// GetRaceResultsMessage возвращает результаты этапа сезона ("last" — последнего прошедшего)
+func (s *ServiceF1) GetRaceResultsMessage(season int, raceId string) (string, error) {
+	results, err := s.storage.GetRaceResults(season, raceId)
 	if err != nil {
 
 		if errors.Is(err, temperrors.ErrEmptyList) && raceId == "last" {
-			results, _ = s.storage.GetRaceResults(userDate.AddDate(-1, 0, 0), raceId)
-		} else {
-			return "Информации о результатах данной гонки нет. Возможно она появится в будущем :)", err
+			results, err = s.storage.GetRaceResults(season-1, raceId)
+		}
+		if errors.Is(err, temperrors.ErrEmptyList) {
+			return "Информации о результатах данной гонки нет. Возможно она появится в будущем :)", nil
+		}
+		if err != nil {
+			return "", err
 		}
 
 	}
@@ -158,13 +190,14 @@ func (s *ServiceF1) GetRaceResultsMessage(userDate time.Time, raceId string) (st
 	return fmt.Sprintf("Результаты гонки F1 %s %s:\n%s", results[0].RaceName, results[0].Season, raceResultsToString(results[0])), nil
 }
 
-func (s *ServiceF1) GetGPInfoCarousel(userDate time.Time, raceId string) (string, error) {
-	races, err := s.storage.GetGPInfo(userDate, raceId)
+// GetGPInfoCarousel возвращает карточку этапа со временем гонки в часовом поясе loc
+func (s *ServiceF1) GetGPInfoCarousel(season int, raceId string, loc *time.Location) (string, error) {
+	races, err := s.storage.GetGPInfo(season, raceId)
 
 	if err != nil {
 
 		if errors.Is(err, temperrors.ErrEmptyList) {
-			races, _ = s.storage.GetGPInfo(userDate.AddDate(-1, 0, 0), raceId)
+			races, _ = s.storage.GetGPInfo(season-1, raceId)
 
 		} else {
 			return "", err
@@ -172,9 +205,9 @@ func (s *ServiceF1) GetGPInfoCarousel(userDate time.Time, raceId string) (string
 
 	}
 
-	lastGP := formatDateTime(races[0])
+	lastGP := formatDateTime(races[0], loc)
 
-	strCrslItem := makeCarouselGPItem(lastGP)
+	strCrslItem := makeCarouselGPItem(lastGP, zoneLabel(time.Now(), loc), s.carouselPhoto)
 	crsl := models.Carousel{Type: "carousel", Elements: []models.CarouselItem{strCrslItem}}
 	jsCrsl, err := json.Marshal(crsl)
 	if err != nil {
@@ -218,12 +251,12 @@ func (s *ServiceF1) GetGPKeyboard() string {
 
 func (s *ServiceF1) GetCountDaysAfterRaceMessage(userDate time.Time, raceId string) (string, error) {
 
-	races, err := s.storage.GetGPInfo(userDate, raceId)
+	races, err := s.storage.GetGPInfo(userDate.Year(), raceId)
 
 	if err != nil {
 
 		if errors.Is(err, temperrors.ErrEmptyList) {
-			races, _ = s.storage.GetGPInfo(userDate.AddDate(-1, 0, 0), raceId)
+			races, _ = s.storage.GetGPInfo(userDate.Year()-1, raceId)
 		} else {
 			return "", err
 		}
@@ -238,7 +271,7 @@ func (s *ServiceF1) GetCountDaysAfterRaceMessage(userDate time.Time, raceId stri
 	difference := userDate.Sub(lastRaceDate)
 
 	if difference < 0 {
-		races, _ = s.storage.GetGPInfo(userDate.AddDate(-1, 0, 0), raceId)
+		races, _ = s.storage.GetGPInfo(userDate.Year()-1, raceId)
 		lastRaceDate, err := parseStringToTime(races[0].Date, races[0].Time)
 		if err != nil {
 			return "", fmt.Errorf("failed to parse last race date: %w", err)
@@ -249,8 +282,9 @@ func (s *ServiceF1) GetCountDaysAfterRaceMessage(userDate time.Time, raceId stri
 	return fmt.Sprintf("Дней без F1 - %d :(\n", int64(difference.Hours()/24)), nil
 }
 
-func (s *ServiceF1) GetQualifyingResultsMessage(userDate time.Time, raceId string) (string, error) {
-	qualRes, err := s.storage.GetQualifyingResults(userDate, raceId)
+// GetQualifyingResultsMessage возвращает результаты квалификации этапа сезона ("last" — последней прошедшей)
+func (s *ServiceF1) GetQualifyingResultsMessage(season int, raceId string) (string, error) {
+	qualRes, err := s.storage.GetQualifyingResults(season, raceId)
 
 	if err != nil {
 
@@ -260,7 +294,10 @@ func (s *ServiceF1) GetQualifyingResultsMessage(userDate time.Time, raceId strin
 				return "Информации о результатах данной квалификации нет. Возможно она появится в будущем :)", nil
 			}
 
-			qualRes, _ = s.storage.GetQualifyingResults(userDate.AddDate(-1, 0, 0), raceId)
+			qualRes, err = s.storage.GetQualifyingResults(season-1, raceId)
+			if err != nil {
+				return "Информации о результатах данной квалификации нет. Возможно она появится в будущем :)", nil
+			}
 		} else {
 			return "", err
 		}
@@ -272,20 +309,21 @@ func (s *ServiceF1) GetQualifyingResultsMessage(userDate time.Time, raceId strin
 	return fmt.Sprintf("Результаты квалификации %s %s:\n%s", qualRes[0].RaceName, qualRes[0].Season, qualifyingResultsToString(qualRes[0])), nil
 }
 
-func (s *ServiceF1) GetSprintResultsMessage(userDate time.Time, raceId string) string {
+// GetSprintResultsMessage возвращает результаты спринта этапа сезона
+func (s *ServiceF1) GetSprintResultsMessage(season int, raceId string) string {
 
 	if raceId == "last" {
 		return "Информации о результатах данной спринт-гонки нет. Возможно она появится в будущем :)"
 	}
-	sprRace := s.storage.GetSprintResults(userDate, raceId)
+	sprRace := s.storage.GetSprintResults(season, raceId)
 	if len(sprRace) > 0 {
 		return fmt.Sprintf("Результаты спринт-гонки %s %s:\n%s", sprRace[0].RaceName, sprRace[0].Season, sprintResultsToString(sprRace[0]))
 	}
 	return "Информации о результатах данной спринт-гонки нет. Возможно она появится в будущем :)"
 }
 
-func (s *ServiceF1) GetCountOfRaces(userDate time.Time) (int, error) {
-	calendar, err := s.storage.GetCalendar(userDate.Year())
+func (s *ServiceF1) GetCountOfRaces(season int) (int, error) {
+	calendar, err := s.storage.GetCalendar(season)
 	if err != nil {
 		slog.Error("failed to get calendar", slog.Any("error", err))
 		return 0, err
@@ -346,13 +384,13 @@ func constructorToString(constructor models.ConstructorStandingsItem) string {
 	return fmt.Sprintf("%2s | %s - %-3s \n", constructor.Position, constructor.Constructor.Name, constructor.Points)
 }
 
-func racesToString(races []models.Race) string {
+func racesToString(races []models.Race, loc *time.Location) string {
 
 	countRaces := len(races)
 	racesList := make([]string, 0, countRaces)
 
 	for _, race := range races {
-		race = formatDateTime(race)
+		race = formatDateTime(race, loc)
 		racesList = append(racesList, raceToString(race))
 	}
 
@@ -385,69 +423,49 @@ func raceResultsToString(race models.Race) string {
 	return message.String()
 }
 
-func formatDateTime(race models.Race) models.Race {
-
-	tzone, err := time.LoadLocation("Europe/Moscow")
-	if err != nil {
-		slog.Error("failed to load timezone", slog.Any("error", err))
-		tzone = time.UTC
-	}
-
-	var raceDate time.Time
-
+// formatDateTime переводит дату и время сессий этапа в часовой пояс loc для вывода
+func formatDateTime(race models.Race, loc *time.Location) models.Race {
 	if race.Time != "" {
-		raceDate, err = parseStringToTime(race.Date, race.Time)
+		raceDate, err := parseStringToTime(race.Date, race.Time)
 		if err != nil {
 			slog.Error("failed to parse race date/time", slog.Any("error", err))
 			return race
 		}
-		race.Date = ruMonth(raceDate.Format("2006-01-02"))
-		race.Time = raceDate.In(tzone).Format("15:04")
+		race.Date, race.Time = localDateTime(raceDate, loc)
 	} else {
 		race.Date = ruMonth(race.Date)
 		race.Time = "неизвестно"
 	}
 
-	if race.FirstPractice.Date != "" {
-		fPracticeDate, err := parseStringToTime(race.FirstPractice.Date, race.FirstPractice.Time)
-		if err == nil {
-			race.FirstPractice.Date = ruMonth(fPracticeDate.Format("2006-01-02"))
-			race.FirstPractice.Time = fPracticeDate.In(tzone).Format("15:04")
-		}
+	// Дата и время каждой сессии уикенда
+	sessions := [][2]*string{
+		{&race.FirstPractice.Date, &race.FirstPractice.Time},
+		{&race.SecondPractice.Date, &race.SecondPractice.Time},
+		{&race.ThirdPractice.Date, &race.ThirdPractice.Time},
+		{&race.SprintQualifying.Date, &race.SprintQualifying.Time},
+		{&race.Sprint.Date, &race.Sprint.Time},
+		{&race.Qualifying.Date, &race.Qualifying.Time},
 	}
-	if race.SecondPractice.Date != "" {
-		sPracticeDate, err := parseStringToTime(race.SecondPractice.Date, race.SecondPractice.Time)
-		if err == nil {
-			race.SecondPractice.Date = ruMonth(sPracticeDate.Format("2006-01-02"))
-			race.SecondPractice.Time = sPracticeDate.In(tzone).Format("15:04")
+	for _, session := range sessions {
+		date, clock := session[0], session[1]
+		if *date == "" {
+			continue
 		}
-	}
-	if race.Qualifying.Date != "" {
-		qualDate, err := parseStringToTime(race.Qualifying.Date, race.Qualifying.Time)
+		start, err := parseStringToTime(*date, *clock)
 		if err == nil {
-			race.Qualifying.Date = ruMonth(qualDate.Format("2006-01-02"))
-			race.Qualifying.Time = qualDate.In(tzone).Format("15:04")
-		}
-	}
-
-	if len(race.Sprint.Date) > 0 {
-		sprDate, err := parseStringToTime(race.Sprint.Date, race.Sprint.Time)
-		if err == nil {
-			race.Sprint.Date = ruMonth(sprDate.Format("2006-01-02"))
-			race.Sprint.Time = sprDate.In(tzone).Format("15:04")
-		}
-	}
-	if race.ThirdPractice.Date != "" {
-		tPracticeDate, err := parseStringToTime(race.ThirdPractice.Date, race.ThirdPractice.Time)
-		if err == nil {
-			race.ThirdPractice.Date = ruMonth(tPracticeDate.Format("2006-01-02"))
-			race.ThirdPractice.Time = tPracticeDate.In(tzone).Format("15:04")
+			*date, *clock = localDateTime(start, loc)
 		}
 	}
 
 	return race
 }
 
+// localDateTime возвращает дату ("03 мая 2025") и время ("23:00") в часовом поясе loc
+func localDateTime(t time.Time, loc *time.Location) (string, string) {
+	t = t.In(loc)
+	return ruMonth(t.Format("2006-01-02")), t.Format("15:04")
+}
+
 func parseStringToTime(dateRace string, timeRace string) (time.Time, error) {
 	tempDateTime, err := time.Parse("2006-01-02 15:04:05Z", fmt.Sprintf("%s %s", dateRace, timeRace))
 	if err != nil {
@@ -520,7 +538,7 @@ func raceFullInfoToString(race models.Race) string {
 		race.Round, race.RaceName, race.Date+" "+race.Time, race.FirstPractice.Date+" "+race.FirstPractice.Time, race.SecondPractice.Date+" "+race.SecondPractice.Time, race.ThirdPractice.Date+" "+race.ThirdPractice.Time, race.Qualifying.Date+" "+race.Qualifying.Time)
 }
 
-func makeCarouselGPItem(curRace models.Race) models.CarouselItem {
+func makeCarouselGPItem(curRace models.Race, zone, photoID string) models.CarouselItem {
 	var buttonsArray = make([]models.Button, 0, 3)
 
 	actionBtn1 := models.ActionBtn{TypeAction: "text", Label: "Результат гонки", Payload: fmt.Sprintf(`{"command" : "raceRes_%s"}`, curRace.Round)}
@@ -538,8 +556,8 @@ func makeCarouselGPItem(curRace models.Race) models.CarouselItem {
 
 	crslItem := models.CarouselItem{
 		Title:       curRace.RaceName,
-		Description: fmt.Sprintf("%s\n%s", curRace.Circuit.CircuitName, curRace.Date+", "+curRace.Time),
-		PhotoID:     "-219009582_457239025",
+		Description: fmt.Sprintf("%s\n%s, %s (%s)", curRace.Circuit.CircuitName, curRace.Date, curRace.Time, zone),
+		PhotoID:     photoID,
 		Action:      models.ActionBtn{TypeAction: "open_link", Link: curRace.Url},
 		Buttons:     buttonsArray}
 
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Сколько мест личного зачёта показывать в динамике по этапам
+const progressionTop = 10
+
+// GetStandingsProgressionMessage возвращает динамику личного зачёта сезона: топ-10 после
+// каждого прошедшего этапа, по строке на этап. Зачёт каждого этапа — отдельный запрос к Ergast.
+func (s *ServiceF1) GetStandingsProgressionMessage(season int) (string, error) {
+	race, err := s.storage.GetGPInfo(season, "last")
+	if err != nil {
+		if errors.Is(err, temperrors.ErrEmptyList) {
+			return "Личный зачёт еще не сформирован.", nil
+		}
+		slog.Error("failed to get GP info", slog.Any("error", err))
+		return "", err
+	}
+
+	rounds, err := strconv.Atoi(race[0].Round)
+	if err != nil {
+		return "", fmt.Errorf("invalid round %q: %w", race[0].Round, temperrors.ErrParse)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📈 Личный зачёт F1 по этапам, сезон %d (топ-%d):\n", season, progressionTop))
+	for round := 1; round <= rounds; round++ {
+		standings, err := s.storage.GetDriverStandings(season, strconv.Itoa(round))
+		if err != nil {
+			if errors.Is(err, temperrors.ErrEmptyList) {
+				// Этап ещё не пересчитан в Ergast
+				break
+			}
+			slog.Error("failed to get driver standings", slog.Int("round", round), slog.Any("error", err))
+			return "", err
+		}
+		sb.WriteString(progressionRow(round, standings))
+	}
+	sb.WriteString("\nСтрока — этап, гонщики — в порядке мест после него.")
+	return sb.String(), nil
+}
+
+// progressionRow — "12 | VER NOR LEC PIA SAI HAM RUS PER ALO HUL"
+func progressionRow(round int, standings []models.DriverStandingsItem) string {
+	codes := make([]string, 0, progressionTop)
+	for _, item := range standings {
+		if len(codes) == progressionTop {
+			break
+		}
+		codes = append(codes, driverCode(item.Driver))
+	}
+	return fmt.Sprintf("%2d | %s\n", round, strings.Join(codes, " "))
+}
+
+// driverCode возвращает трёхбуквенный код гонщика; у гонщиков прошлых десятилетий кода
+// в Ergast нет, тогда берутся первые три буквы фамилии
+func driverCode(driver models.Driver) string {
+	if driver.Code != "" {
+		return driver.Code
+	}
+	name := strings.ToUpper(driver.FamilyName)
+	if utf8.RuneCountInString(name) <= 3 {
+		return name
+	}
+	return string([]rune(name)[:3])
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"fmt"
+	"racebot-vk/models"
+	predStorage "racebot-vk/storage/prediction"
+	"racebot-vk/temperrors"
+	"slices"
+	"strings"
+)
+
+// Названия тем в командах подписки (VK и Telegram)
+var topicNames = map[string][]models.Topic{
+	"гонка":        {models.TopicRace},
+	"гонки":        {models.TopicRace},
+	"race":         {models.TopicRace},
+	"квала":        {models.TopicQualifying},
+	"квалы":        {models.TopicQualifying},
+	"квалификация": {models.TopicQualifying},
+	"qualifying":   {models.TopicQualifying},
+	"quali":        {models.TopicQualifying},
+	"спринт":       {models.TopicSprint},
+	"sprint":       {models.TopicSprint},
+	"практика":     {models.TopicPractice},
+	"практики":     {models.TopicPractice},
+	"practice":     {models.TopicPractice},
+	"результаты":   {models.TopicResults},
+	"results":      {models.TopicResults},
+	"прогнозы":     {models.TopicPredictions},
+	"predictions":  {models.TopicPredictions},
+	"все":          models.AllTopics,
+	"всё":          models.AllTopics,
+	"all":          models.AllTopics,
+}
+
+// Подписка по умолчанию для чатов из конфигурации
+var (
+	// чаты напоминаний: все сессии и результаты
+	defaultReminderTopics = []models.Topic{models.TopicRace, models.TopicQualifying, models.TopicSprint, models.TopicPractice, models.TopicResults}
+	// чаты конкурса прогнозов: объявления и итоги общих раундов
+	defaultPredictionTopics = []models.Topic{models.TopicPredictions}
+)
+
+// ChatAudience возвращает чаты платформы, подписанные на тему
+type ChatAudience interface {
+	Chats(platform string, topic models.Topic) ([]int64, error)
+}
+
+// SubscriptionService управляет подписками чатов на рассылки. Личный диалог — подписка
+// пользователя, беседа — подписка чата, которую меняют администраторы беседы.
+// Чаты из конфигурации подписаны по умолчанию, пока подписку не изменили командой.
+type SubscriptionService struct {
+	storage  *predStorage.Storage
+	roles    *RoleService
+	defaults map[string]map[int64][]models.Topic
+}
+
+// NewSubscriptionService создаёт сервис подписок; reminderChats и predictionChats —
+// чаты платформ из конфигурации (платформа → чаты) с подпиской по умолчанию
+func NewSubscriptionService(storage *predStorage.Storage, roles *RoleService, reminderChats, predictionChats map[string][]int64) *SubscriptionService {
+	defaults := make(map[string]map[int64][]models.Topic)
+	add := func(chats map[string][]int64, topics []models.Topic) {
+		for platform, ids := range chats {
+			if defaults[platform] == nil {
+				defaults[platform] = make(map[int64][]models.Topic)
+			}
+			for _, id := range ids {
+				defaults[platform][id] = mergeTopics(defaults[platform][id], topics)
+			}
+		}
+	}
+	add(reminderChats, defaultReminderTopics)
+	add(predictionChats, defaultPredictionTopics)
+
+	return &SubscriptionService{storage: storage, roles: roles, defaults: defaults}
+}
+
+// Get возвращает подписку чата: сохранённую командой или подписку по умолчанию
+func (s *SubscriptionService) Get(platform string, chatID int64) (*models.Subscription, error) {
+	sub, err := s.storage.GetSubscription(platform, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if sub != nil {
+		return sub, nil
+	}
+	return &models.Subscription{Platform: platform, ChatID: chatID, Topics: s.defaults[platform][chatID]}, nil
+}
+
+// Chats возвращает чаты платформы, подписанные на тему
+func (s *SubscriptionService) Chats(platform string, topic models.Topic) ([]int64, error) {
+	stored, err := s.storage.GetSubscriptions(platform)
+	if err != nil {
+		return nil, err
+	}
+
+	overridden := make(map[int64]bool, len(stored))
+	var chats []int64
+	for i := range stored {
+		overridden[stored[i].ChatID] = true
+		if stored[i].Has(topic) {
+			chats = append(chats, stored[i].ChatID)
+		}
+	}
+	for chatID, topics := range s.defaults[platform] {
+		if !overridden[chatID] && slices.Contains(topics, topic) {
+			chats = append(chats, chatID)
+		}
+	}
+	slices.Sort(chats)
+	return chats, nil
+}
+
+// ReminderChats возвращает чаты, подписанные на напоминания о сессии (ReminderAudience)
+func (s *SubscriptionService) ReminderChats(platform string, session models.SessionType) ([]int64, error) {
+	return s.Chats(platform, models.SessionTopic(session))
+}
+
+// Subscribe добавляет темы в подписку чата; only — заменить подписку этими темами
+func (s *SubscriptionService) Subscribe(platform string, userID int, chatID int64, topics []models.Topic, only bool) (*models.Subscription, error) {
+	return s.update(platform, userID, chatID, func(current []models.Topic) []models.Topic {
+		if only {
+			return mergeTopics(nil, topics)
+		}
+		return mergeTopics(current, topics)
+	})
+}
+
+// Unsubscribe убирает темы из подписки чата; без тем — отписывает от всего
+func (s *SubscriptionService) Unsubscribe(platform string, userID int, chatID int64, topics []models.Topic) (*models.Subscription, error) {
+	return s.update(platform, userID, chatID, func(current []models.Topic) []models.Topic {
+		if len(topics) == 0 {
+			return nil
+		}
+		var rest []models.Topic
+		for _, t := range current {
+			if !slices.Contains(topics, t) {
+				rest = append(rest, t)
+			}
+		}
+		return rest
+	})
+}
+
+// update меняет подписку чата. Подписку беседы меняют только её администраторы,
+// подписку личного диалога — только сам пользователь.
+func (s *SubscriptionService) update(platform string, userID int, chatID int64, change func([]models.Topic) []models.Topic) (*models.Subscription, error) {
+	if isGroupChat(platform, chatID) {
+		if !s.roles.HasRole(platform, userID, chatID, models.RoleAdmin) {
+			return nil, fmt.Errorf("user %d in chat %d: %w", userID, chatID, temperrors.ErrPermissionDenied)
+		}
+	} else if int64(userID) != chatID {
+		return nil, fmt.Errorf("user %d in chat %d: %w", userID, chatID, temperrors.ErrPermissionDenied)
+	}
+
+	sub, err := s.Get(platform, chatID)
+	if err != nil {
+		return nil, err
+	}
+	sub.Topics = change(sub.Topics)
+	sub.UpdatedBy = userID
+	if err := s.storage.SetSubscription(sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// FormatSubscription — "🔔 Подписка: гонка, квалификация, результаты"
+func FormatSubscription(sub *models.Subscription) string {
+	if len(sub.Topics) == 0 {
+		return "🔕 Подписок нет."
+	}
+	titles := make([]string, 0, len(sub.Topics))
+	for _, t := range models.AllTopics {
+		if sub.Has(t) {
+			titles = append(titles, t.Title())
+		}
+	}
+	return "🔔 Подписка: " + strings.Join(titles, ", ") + "."
+}
+
+// ParseTopics разбирает темы подписки; "только"/"only" первым словом — заменить подписку
+func ParseTopics(args []string) (topics []models.Topic, only bool, err error) {
+	for i, arg := range args {
+		arg = strings.ToLower(arg)
+		if i == 0 && (arg == "только" || arg == "only") {
+			only = true
+			continue
+		}
+		named, ok := topicNames[arg]
+		if !ok {
+			return nil, false, fmt.Errorf("topic %q: %w", arg, temperrors.ErrUnknownTopic)
+		}
+		topics = mergeTopics(topics, named)
+	}
+	return topics, only, nil
+}
+
+// mergeTopics объединяет темы без повторов в порядке models.AllTopics
+func mergeTopics(current, added []models.Topic) []models.Topic {
+	var merged []models.Topic
+	for _, t := range models.AllTopics {
+		if slices.Contains(current, t) || slices.Contains(added, t) {
+			merged = append(merged, t)
+		}
+	}
+	return merged
+}
+
+// isGroupChat проверяет, что чат — беседа VK или группа Telegram, а не личный диалог
+func isGroupChat(platform string, chatID int64) bool {
+	if platform == models.PlatformTelegram {
+		return chatID < 0
+	}
+	return chatID >= 2000000000
+}
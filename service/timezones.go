@@ -0,0 +1,306 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	predStorage "racebot-vk/storage/prediction"
+	"racebot-vk/temperrors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Часовой пояс по умолчанию — время расписания, которое бот показывал всегда
+const defaultTimezone = "Europe/Moscow"
+
+// Города, которые можно указать вместо названия часового пояса IANA
+var timezoneAliases = map[string]string{
+	"калининград":     "Europe/Kaliningrad",
+	"москва":          "Europe/Moscow",
+	"мск":             "Europe/Moscow",
+	"питер":           "Europe/Moscow",
+	"спб":             "Europe/Moscow",
+	"санкт-петербург": "Europe/Moscow",
+	"петербург":       "Europe/Moscow",
+	"казань":          "Europe/Moscow",
+	"нижний новгород": "Europe/Moscow",
+	"сочи":            "Europe/Moscow",
+	"краснодар":       "Europe/Moscow",
+	"ростов":          "Europe/Moscow",
+	"ростов-на-дону":  "Europe/Moscow",
+	"воронеж":         "Europe/Moscow",
+	"симферополь":     "Europe/Simferopol",
+	"волгоград":       "Europe/Volgograd",
+	"самара":          "Europe/Samara",
+	"ижевск":          "Europe/Samara",
+	"саратов":         "Europe/Saratov",
+	"ульяновск":       "Europe/Ulyanovsk",
+	"уфа":             "Asia/Yekaterinburg",
+	"пермь":           "Asia/Yekaterinburg",
+	"челябинск":       "Asia/Yekaterinburg",
+	"тюмень":          "Asia/Yekaterinburg",
+	"екатеринбург":    "Asia/Yekaterinburg",
+	"екб":             "Asia/Yekaterinburg",
+	"оренбург":        "Asia/Yekaterinburg",
+	"омск":            "Asia/Omsk",
+	"новосибирск":     "Asia/Novosibirsk",
+	"нск":             "Asia/Novosibirsk",
+	"томск":           "Asia/Tomsk",
+	"барнаул":         "Asia/Barnaul",
+	"кемерово":        "Asia/Novokuznetsk",
+	"новокузнецк":     "Asia/Novokuznetsk",
+	"красноярск":      "Asia/Krasnoyarsk",
+	"иркутск":         "Asia/Irkutsk",
+	"улан-удэ":        "Asia/Irkutsk",
+	"чита":            "Asia/Chita",
+	"якутск":          "Asia/Yakutsk",
+	"благовещенск":    "Asia/Yakutsk",
+	"владивосток":     "Asia/Vladivostok",
+	"хабаровск":       "Asia/Vladivostok",
+	"сахалин":         "Asia/Sakhalin",
+	"южно-сахалинск":  "Asia/Sakhalin",
+	"магадан":         "Asia/Magadan",
+	"камчатка":        "Asia/Kamchatka",
+	"петропавловск-камчатский": "Asia/Kamchatka",
+	"минск":        "Europe/Minsk",
+	"киев":         "Europe/Kyiv",
+	"рига":         "Europe/Riga",
+	"вильнюс":      "Europe/Vilnius",
+	"таллин":       "Europe/Tallinn",
+	"кишинев":      "Europe/Chisinau",
+	"тбилиси":      "Asia/Tbilisi",
+	"ереван":       "Asia/Yerevan",
+	"баку":         "Asia/Baku",
+	"астана":       "Asia/Almaty",
+	"алматы":       "Asia/Almaty",
+	"ташкент":      "Asia/Tashkent",
+	"бишкек":       "Asia/Bishkek",
+	"душанбе":      "Asia/Dushanbe",
+	"стамбул":      "Europe/Istanbul",
+	"лондон":       "Europe/London",
+	"берлин":       "Europe/Berlin",
+	"париж":        "Europe/Paris",
+	"прага":        "Europe/Prague",
+	"варшава":      "Europe/Warsaw",
+	"хельсинки":    "Europe/Helsinki",
+	"дубай":        "Asia/Dubai",
+	"бангкок":      "Asia/Bangkok",
+	"токио":        "Asia/Tokyo",
+	"нью-йорк":     "America/New_York",
+	"лос-анджелес": "America/Los_Angeles",
+	"utc":          "UTC",
+	"gmt":          "UTC",
+}
+
+// Смещение от UTC или Москвы: "utc+5", "+5", "gmt-3", "мск+4", "+5:30"
+var offsetTimezoneRe = regexp.MustCompile(`\A(utc|gmt|мск)?([+-])(\d{1,2})(?::(\d{2}))?\z`)
+
+// ianaPartRe — слово в названии пояса IANA, которое пишется с заглавной буквы
+var ianaPartRe = regexp.MustCompile(`[a-z]+`)
+
+// TimezoneService хранит часовые пояса пользователей и чатов. Время в ответе на команду
+// показывается в поясе пользователя, если он его задал, иначе в поясе чата, иначе по Москве.
+// Рассылки в чат (напоминания) используют пояс чата.
+type TimezoneService struct {
+	storage  *predStorage.Storage
+	roles    *RoleService
+	fallback *time.Location
+}
+
+func NewTimezoneService(storage *predStorage.Storage, roles *RoleService) *TimezoneService {
+	return &TimezoneService{storage: storage, roles: roles, fallback: DefaultLocation()}
+}
+
+// DefaultLocation возвращает часовой пояс по умолчанию (Москва, UTC — если база поясов недоступна)
+func DefaultLocation() *time.Location {
+	loc, err := time.LoadLocation(defaultTimezone)
+	if err != nil {
+		slog.Error("failed to load timezone", slog.Any("error", err))
+		return time.UTC
+	}
+	return loc
+}
+
+// Location возвращает часовой пояс для ответа пользователю в чате
+func (s *TimezoneService) Location(platform string, userID int, chatID int64) *time.Location {
+	if loc := s.stored(platform, int64(userID)); loc != nil {
+		return loc
+	}
+	return s.ChatLocation(platform, chatID)
+}
+
+// ChatLocation возвращает часовой пояс чата (для личного диалога — пояс пользователя)
+func (s *TimezoneService) ChatLocation(platform string, chatID int64) *time.Location {
+	if loc := s.stored(platform, chatID); loc != nil {
+		return loc
+	}
+	return s.fallback
+}
+
+// SetUserTimezone задаёт личный часовой пояс пользователя
+func (s *TimezoneService) SetUserTimezone(platform string, userID int, name string) (*time.Location, error) {
+	loc, err := ParseTimezone(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storage.SetTimezone(platform, int64(userID), loc.String(), userID); err != nil {
+		return nil, err
+	}
+	return loc, nil
+}
+
+// SetChatTimezone задаёт часовой пояс беседы; менять его могут только администраторы беседы
+func (s *TimezoneService) SetChatTimezone(platform string, userID int, chatID int64, name string) (*time.Location, error) {
+	if !isGroupChat(platform, chatID) {
+		return s.SetUserTimezone(platform, userID, name)
+	}
+	if !s.roles.HasRole(platform, userID, chatID, models.RoleAdmin) {
+		return nil, fmt.Errorf("user %d in chat %d: %w", userID, chatID, temperrors.ErrPermissionDenied)
+	}
+
+	loc, err := ParseTimezone(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storage.SetTimezone(platform, chatID, loc.String(), userID); err != nil {
+		return nil, err
+	}
+	return loc, nil
+}
+
+// ResetUserTimezone удаляет личный часовой пояс: время снова показывается в поясе чата
+func (s *TimezoneService) ResetUserTimezone(platform string, userID int) error {
+	_, err := s.storage.DeleteTimezone(platform, int64(userID))
+	return err
+}
+
+// ResetChatTimezone удаляет часовой пояс беседы: время снова показывается по Москве
+func (s *TimezoneService) ResetChatTimezone(platform string, userID int, chatID int64) error {
+	if !isGroupChat(platform, chatID) {
+		return s.ResetUserTimezone(platform, userID)
+	}
+	if !s.roles.HasRole(platform, userID, chatID, models.RoleAdmin) {
+		return fmt.Errorf("user %d in chat %d: %w", userID, chatID, temperrors.ErrPermissionDenied)
+	}
+	_, err := s.storage.DeleteTimezone(platform, chatID)
+	return err
+}
+
+// GetTimezoneMessage описывает часовые пояса пользователя и чата
+func (s *TimezoneService) GetTimezoneMessage(platform string, userID int, chatID int64) string {
+	var sb strings.Builder
+	if loc := s.stored(platform, int64(userID)); loc != nil {
+		sb.WriteString(fmt.Sprintf("🕒 Ваш часовой пояс: %s.\n", FormatTimezone(loc)))
+	} else {
+		sb.WriteString("🕒 Личный часовой пояс не задан.\n")
+	}
+	if isGroupChat(platform, chatID) {
+		sb.WriteString(fmt.Sprintf("Часовой пояс беседы: %s.\n", FormatTimezone(s.ChatLocation(platform, chatID))))
+	}
+	sb.WriteString(fmt.Sprintf("Расписание показывается в поясе %s.", FormatTimezone(s.Location(platform, userID, chatID))))
+	return sb.String()
+}
+
+// stored возвращает сохранённый часовой пояс пользователя или чата (nil, если не задан)
+func (s *TimezoneService) stored(platform string, ownerID int64) *time.Location {
+	name, err := s.storage.GetTimezone(platform, ownerID)
+	if err != nil {
+		slog.Error("failed to get timezone", slog.String("platform", platform), slog.Int64("owner_id", ownerID), slog.Any("error", err))
+		return nil
+	}
+	if name == "" {
+		return nil
+	}
+	loc, err := ParseTimezone(name)
+	if err != nil {
+		slog.Error("invalid stored timezone", slog.String("timezone", name), slog.Any("error", err))
+		return nil
+	}
+	return loc
+}
+
+// ParseTimezone разбирает часовой пояс: город ("екатеринбург"), название IANA в любом
+// регистре ("asia/yekaterinburg") или смещение ("utc+5", "мск+2")
+func ParseTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	lower := strings.ToLower(name)
+
+	if alias, ok := timezoneAliases[strings.ReplaceAll(lower, "ё", "е")]; ok {
+		lower = strings.ToLower(alias)
+	}
+
+	if m := offsetTimezoneRe.FindStringSubmatch(lower); m != nil {
+		return offsetLocation(m)
+	}
+
+	if strings.Contains(lower, "/") || lower == "utc" {
+		// Сообщения VK приходят в нижнем регистре, а названия IANA чувствительны к регистру
+		for _, candidate := range []string{name, ianaPartRe.ReplaceAllStringFunc(lower, capitalize), strings.ToUpper(lower)} {
+			if loc, err := time.LoadLocation(candidate); err == nil {
+				return loc, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("timezone %q: %w", name, temperrors.ErrUnknownTimezone)
+}
+
+// offsetLocation создаёт пояс с фиксированным смещением. Имя пояса ("UTC+05:30")
+// снова разбирается ParseTimezone, поэтому его можно хранить в БД.
+func offsetLocation(m []string) (*time.Location, error) {
+	hours, _ := strconv.Atoi(m[3])
+	minutes := 0
+	if m[4] != "" {
+		minutes, _ = strconv.Atoi(m[4])
+	}
+	offset := hours*60 + minutes
+	if m[2] == "-" {
+		offset = -offset
+	}
+	if m[1] == "мск" {
+		offset += 3 * 60
+	}
+	if offset < -12*60 || offset > 14*60 || minutes >= 60 {
+		return nil, fmt.Errorf("offset %s: %w", m[0], temperrors.ErrUnknownTimezone)
+	}
+
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+	}
+	abs := max(offset, -offset)
+	return time.FixedZone(fmt.Sprintf("UTC%s%02d:%02d", sign, abs/60, abs%60), offset*60), nil
+}
+
+func capitalize(word string) string {
+	return strings.ToUpper(word[:1]) + word[1:]
+}
+
+// FormatTimezone — "Asia/Yekaterinburg (UTC+05:00)", "Europe/Moscow (МСК)"
+func FormatTimezone(loc *time.Location) string {
+	label := zoneLabel(time.Now(), loc)
+	if label == loc.String() {
+		return label
+	}
+	return fmt.Sprintf("%s (%s)", loc, label)
+}
+
+// zoneLabel возвращает подпись времени: "МСК" для Москвы, иначе смещение от UTC
+func zoneLabel(t time.Time, loc *time.Location) string {
+	if loc.String() == defaultTimezone {
+		return "МСК"
+	}
+	_, offset := t.In(loc).Zone()
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, offset/3600, offset%3600/60)
+}
+
+// formatLocalTime форматирует время в часовом поясе: "03 мая 23:00"
+func formatLocalTime(t time.Time, loc *time.Location) string {
+	t = t.In(loc)
+	return fmt.Sprintf("%s %s", ruMonth(t.Format("2006-01-02")), t.Format("15:04"))
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	"racebot-vk/temperrors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// Через сколько имя пользователя считается устаревшим и запрашивается снова
+	userProfileMaxAge = 7 * 24 * time.Hour
+	// Сколько пользователей запрашивать у платформы за один вызов
+	userProfileBatchSize = 100
+	// Максимальная длина никнейма в символах
+	nicknameMaxLength = 32
+)
+
+// Слова, сбрасывающие никнейм
+var nicknameResetWords = map[string]bool{"сброс": true, "reset": true, "-": true}
+
+// UserProfileSource получает имена пользователей с платформы (VK users.get, Telegram getChat)
+type UserProfileSource interface {
+	GetUserProfiles(userIDs []int) ([]models.UserProfile, error)
+}
+
+// SetProfileSource регистрирует источник имён пользователей платформы.
+// Вызывается при старте до начала обработки сообщений.
+func (s *PredictionService) SetProfileSource(platform string, source UserProfileSource) {
+	if s.profileSources == nil {
+		s.profileSources = make(map[string]UserProfileSource)
+	}
+	s.profileSources[platform] = source
+}
+
+// SaveUserProfile сохраняет имя пользователя, уже известное обработчику (например, из сообщения Telegram)
+func (s *PredictionService) SaveUserProfile(profile models.UserProfile) error {
+	return s.storage.SaveUserProfile(&profile)
+}
+
+// EnsureUserProfile запрашивает имя пользователя у платформы, если его ещё нет в кэше
+// или оно устарело. Для пользователей с никнеймом имя не запрашивается.
+func (s *PredictionService) EnsureUserProfile(platform string, userID int) error {
+	profile, err := s.storage.GetUserProfile(platform, userID)
+	if err != nil {
+		return err
+	}
+	if profile != nil && (profile.Nickname != "" || time.Since(profile.UpdatedAt) < userProfileMaxAge) {
+		return nil
+	}
+
+	source, ok := s.profileSources[platform]
+	if !ok {
+		return nil
+	}
+	profiles, err := source.GetUserProfiles([]int{userID})
+	if err != nil {
+		return fmt.Errorf("failed to get user profile from %s: %w", platform, err)
+	}
+	for i := range profiles {
+		if err := s.storage.SaveUserProfile(&profiles[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetNickname задаёт никнейм, который показывается в рейтингах вместо настоящего имени.
+// Слова "сброс" / "reset" отменяют никнейм. Возвращает сохранённый никнейм (пустой при сбросе).
+func (s *PredictionService) SetNickname(platform string, userID int, nickname string) (string, error) {
+	nickname = strings.TrimSpace(nickname)
+	if nicknameResetWords[strings.ToLower(nickname)] {
+		return "", s.storage.SetUserNickname(platform, userID, "")
+	}
+
+	// Скобки и вертикальная черта образуют упоминания в VK
+	nickname = strings.Join(strings.Fields(strings.NewReplacer("[", "", "]", "", "|", "", "@", "").Replace(nickname)), " ")
+	if length := utf8.RuneCountInString(nickname); length < 2 || length > nicknameMaxLength {
+		return "", fmt.Errorf("nickname length must be 2..%d: %w", nicknameMaxLength, temperrors.ErrParse)
+	}
+
+	return nickname, s.storage.SetUserNickname(platform, userID, nickname)
+}
+
+// GetNicknameMessage описывает текущий никнейм пользователя и как его изменить
+func (s *PredictionService) GetNicknameMessage(platform string, userID int) (string, error) {
+	profile, err := s.storage.GetUserProfile(platform, userID)
+	if err != nil {
+		return "", err
+	}
+
+	current := "не задан — в рейтингах показывается ваше имя"
+	if profile != nil && profile.Nickname != "" {
+		current = fmt.Sprintf("«%s»", profile.Nickname)
+	}
+	return fmt.Sprintf("Ваш никнейм: %s.\n\n%s", current, NicknameUsage), nil
+}
+
+// NicknameUsage — подсказка по команде никнейма
+const NicknameUsage = "Чтобы в рейтингах и итогах прогнозов вместо вашего имени показывался никнейм, отправьте «никнейм Ваш_ник» (VK) или «/nickname Ваш_ник» (Telegram). Сбросить никнейм — «никнейм сброс» или «/nickname reset»."
+
+// refreshStaleProfiles обновляет имена участников прогнозов, которых нет в кэше или чьи имена устарели
+func (s *PredictionService) refreshStaleProfiles(log *slog.Logger) {
+	users, err := s.storage.GetUsersForProfileRefresh(time.Now().Add(-userProfileMaxAge))
+	if err != nil {
+		log.Error("profiles: failed to get users for refresh", slog.Any("error", err))
+		return
+	}
+
+	for platform, userIDs := range users {
+		source, ok := s.profileSources[platform]
+		if !ok {
+			continue
+		}
+
+		for start := 0; start < len(userIDs); start += userProfileBatchSize {
+			end := min(start+userProfileBatchSize, len(userIDs))
+			profiles, err := source.GetUserProfiles(userIDs[start:end])
+			if err != nil {
+				log.Error("profiles: failed to get user profiles", slog.String("platform", platform), slog.Any("error", err))
+				break
+			}
+			for i := range profiles {
+				if err := s.storage.SaveUserProfile(&profiles[i]); err != nil {
+					log.Error("profiles: failed to save user profile", slog.String("platform", platform), slog.Int("user_id", profiles[i].UserID), slog.Any("error", err))
+				}
+			}
+		}
+		log.Info("User profiles refreshed", slog.String("platform", platform), slog.Int("users", len(userIDs)))
+	}
+}
+
+// userNames загружает кэш имён для вывода в сообщениях
+func (s *PredictionService) userNames() (map[string]models.UserProfile, error) {
+	profiles, err := s.storage.GetUserProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// displayName возвращает имя пользователя из кэша или подпись с ID, если имя неизвестно
+func displayName(profiles map[string]models.UserProfile, platform string, userID int) string {
+	if profile, ok := profiles[fmt.Sprintf("%s:%d", platform, userID)]; ok {
+		if name := profile.DisplayName(); name != "" {
+			return name
+		}
+	}
+	return userLabel(platform, userID)
+}
+
+// UserProfileRefresher периодически обновляет имена участников прогнозов
+type UserProfileRefresher struct {
+	predictions *PredictionService
+	interval    time.Duration
+}
+
+func NewUserProfileRefresher(predictions *PredictionService, interval time.Duration) *UserProfileRefresher {
+	return &UserProfileRefresher{predictions: predictions, interval: interval}
+}
+
+// Run запускает периодическое обновление (блокирующий вызов)
+func (r *UserProfileRefresher) Run(log *slog.Logger) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	log.Info("Start user profile refresher", slog.Duration("interval", r.interval))
+	r.predictions.refreshStaleProfiles(log)
+
+	for range ticker.C {
+		r.predictions.refreshStaleProfiles(log)
+	}
+}
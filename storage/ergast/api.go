@@ -6,15 +6,22 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"racebot-vk/models"
 	"racebot-vk/temperrors"
+	"strconv"
 	"time"
 )
 
+// Карьерная статистика гонщиков меняется не чаще раза в гоночный уикенд,
+// поэтому хранится в кэше дольше остальных ответов
+const statsCacheTTL = 12 * time.Hour
+
 type ErgastAPI struct {
-	url    string
-	client *http.Client
-	cache  *cache
+	url        string
+	client     *http.Client
+	cache      *cache
+	statsCache *cache
 }
 
 func NewErgastAPI() *ErgastAPI {
@@ -23,12 +30,13 @@ func NewErgastAPI() *ErgastAPI {
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		cache: newCache(15 * time.Minute),
+		cache:      newCache(15 * time.Minute),
+		statsCache: newCache(statsCacheTTL),
 	}
 }
 
-func (erg *ErgastAPI) GetDriversList(userDate time.Time) ([]models.Driver, error) {
-	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/drivers.json", erg.url, userDate.Year()))
+func (erg *ErgastAPI) GetDriversList(season int) ([]models.Driver, error) {
+	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/drivers.json", erg.url, season))
 	if err != nil {
 		return nil, fmt.Errorf("in driversList %w", err)
 	}
@@ -38,8 +46,9 @@ func (erg *ErgastAPI) GetDriversList(userDate time.Time) ([]models.Driver, error
 	return nil, temperrors.ErrEmptyList
 }
 
-func (erg *ErgastAPI) GetDriverStandings(userDate time.Time) ([]models.DriverStandingsItem, error) {
-	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/driverStandings.json", erg.url, userDate.Year()))
+// GetDriverStandings возвращает личный зачёт сезона после этапа round ("last" — после последнего)
+func (erg *ErgastAPI) GetDriverStandings(season int, round string) ([]models.DriverStandingsItem, error) {
+	resp, err := erg.getRequest(fmt.Sprintf("%s/%s/driverStandings.json", erg.url, standingsPath(season, round)))
 	if err != nil {
 		return nil, fmt.Errorf("in driverStanding %w", err)
 	}
@@ -63,8 +72,9 @@ func (erg *ErgastAPI) GetCalendar(year int) ([]models.Race, error) {
 
 }
 
-func (erg *ErgastAPI) GetConstructorStandings(userDate time.Time) ([]models.ConstructorStandingsItem, error) {
-	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/constructorStandings.json", erg.url, userDate.Year()))
+// GetConstructorStandings возвращает кубок конструкторов сезона после этапа round ("last" — после последнего)
+func (erg *ErgastAPI) GetConstructorStandings(season int, round string) ([]models.ConstructorStandingsItem, error) {
+	resp, err := erg.getRequest(fmt.Sprintf("%s/%s/constructorStandings.json", erg.url, standingsPath(season, round)))
 	if err != nil {
 		return nil, fmt.Errorf("in constructorStanding %w", err)
 	}
@@ -75,8 +85,8 @@ func (erg *ErgastAPI) GetConstructorStandings(userDate time.Time) ([]models.Cons
 
 }
 
-func (erg *ErgastAPI) GetRaceResults(userDate time.Time, raceId string) ([]models.Race, error) {
-	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/%s/results.json", erg.url, userDate.Year(), raceId))
+func (erg *ErgastAPI) GetRaceResults(season int, raceId string) ([]models.Race, error) {
+	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/%s/results.json", erg.url, season, raceId))
 	if err != nil {
 		return nil, fmt.Errorf("in raceResults %w", err)
 	}
@@ -86,8 +96,8 @@ func (erg *ErgastAPI) GetRaceResults(userDate time.Time, raceId string) ([]model
 	return nil, temperrors.ErrEmptyList
 }
 
-func (erg *ErgastAPI) GetGPInfo(userDate time.Time, raceId string) ([]models.Race, error) {
-	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/%s.json", erg.url, userDate.Year(), raceId))
+func (erg *ErgastAPI) GetGPInfo(season int, raceId string) ([]models.Race, error) {
+	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/%s.json", erg.url, season, raceId))
 	if err != nil {
 		return nil, fmt.Errorf("in getGPInfo %w", err)
 	}
@@ -98,8 +108,8 @@ func (erg *ErgastAPI) GetGPInfo(userDate time.Time, raceId string) ([]models.Rac
 	return nil, temperrors.ErrEmptyList
 }
 
-func (erg *ErgastAPI) GetQualifyingResults(userDate time.Time, raceID string) ([]models.Race, error) {
-	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/%s/qualifying.json", erg.url, userDate.Year(), raceID))
+func (erg *ErgastAPI) GetQualifyingResults(season int, raceID string) ([]models.Race, error) {
+	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/%s/qualifying.json", erg.url, season, raceID))
 	if err != nil {
 		return nil, fmt.Errorf("in getQualifyingResults %w", err)
 	}
@@ -110,8 +120,8 @@ func (erg *ErgastAPI) GetQualifyingResults(userDate time.Time, raceID string) ([
 	return nil, temperrors.ErrEmptyList
 }
 
-func (erg *ErgastAPI) GetSprintResults(userDate time.Time, raceId string) []models.Race {
-	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/%s/sprint.json", erg.url, userDate.Year(), raceId))
+func (erg *ErgastAPI) GetSprintResults(season int, raceId string) []models.Race {
+	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/%s/sprint.json", erg.url, season, raceId))
 	if err != nil {
 		slog.Error("failed to get sprint results", slog.Any("error", err))
 		return nil
@@ -119,6 +129,159 @@ func (erg *ErgastAPI) GetSprintResults(userDate time.Time, raceId string) []mode
 	return resp.MRData.RaceTable.Races
 }
 
+// FetchRaceResults запрашивает результаты гонки в обход кэша (для рассылки сразу после публикации)
+func (erg *ErgastAPI) FetchRaceResults(season int, round string) ([]models.Race, error) {
+	resp, err := erg.fetchRequest(fmt.Sprintf("%s/%d/%s/results.json", erg.url, season, round))
+	if err != nil {
+		return nil, fmt.Errorf("in fetchRaceResults %w", err)
+	}
+	if len(resp.MRData.RaceTable.Races) > 0 && len(resp.MRData.RaceTable.Races[0].Results) > 0 {
+		return resp.MRData.RaceTable.Races, nil
+	}
+	return nil, temperrors.ErrEmptyList
+}
+
+// FetchQualifyingResults запрашивает результаты квалификации в обход кэша
+func (erg *ErgastAPI) FetchQualifyingResults(season int, round string) ([]models.Race, error) {
+	resp, err := erg.fetchRequest(fmt.Sprintf("%s/%d/%s/qualifying.json", erg.url, season, round))
+	if err != nil {
+		return nil, fmt.Errorf("in fetchQualifyingResults %w", err)
+	}
+	if len(resp.MRData.RaceTable.Races) > 0 && len(resp.MRData.RaceTable.Races[0].QualifyingResults) > 0 {
+		return resp.MRData.RaceTable.Races, nil
+	}
+	return nil, temperrors.ErrEmptyList
+}
+
+// FetchSprintResults запрашивает результаты спринта в обход кэша
+func (erg *ErgastAPI) FetchSprintResults(season int, round string) ([]models.Race, error) {
+	resp, err := erg.fetchRequest(fmt.Sprintf("%s/%d/%s/sprint.json", erg.url, season, round))
+	if err != nil {
+		return nil, fmt.Errorf("in fetchSprintResults %w", err)
+	}
+	if len(resp.MRData.RaceTable.Races) > 0 && len(resp.MRData.RaceTable.Races[0].SprintResults) > 0 {
+		return resp.MRData.RaceTable.Races, nil
+	}
+	return nil, temperrors.ErrEmptyList
+}
+
+// GetDriver возвращает гонщика по идентификатору Ergast ("max_verstappen", "senna")
+func (erg *ErgastAPI) GetDriver(driverID string) (models.Driver, error) {
+	resp, err := erg.getStatsRequest(fmt.Sprintf("%s/drivers/%s.json", erg.url, url.PathEscape(driverID)))
+	if err != nil {
+		return models.Driver{}, fmt.Errorf("in getDriver %w", err)
+	}
+	if len(resp.MRData.DriverTable.Drivers) > 0 {
+		return resp.MRData.DriverTable.Drivers[0], nil
+	}
+	return models.Driver{}, temperrors.ErrEmptyList
+}
+
+// CountDriverResults возвращает число гонок гонщика за карьеру, финишировавших на месте
+// position; position = 0 — все старты
+func (erg *ErgastAPI) CountDriverResults(driverID string, position int) (int, error) {
+	path := "results"
+	if position > 0 {
+		path = fmt.Sprintf("results/%d", position)
+	}
+	return erg.countStats(fmt.Sprintf("%s/drivers/%s/%s.json?limit=1", erg.url, url.PathEscape(driverID), path))
+}
+
+// CountDriverPoles возвращает число поул-позиций гонщика — побед в квалификации. Место на стартовой
+// решётке не подходит: после штрафов с первого места стартует не обладатель поула.
+func (erg *ErgastAPI) CountDriverPoles(driverID string) (int, error) {
+	return erg.countStats(fmt.Sprintf("%s/drivers/%s/qualifying/1.json?limit=1", erg.url, url.PathEscape(driverID)))
+}
+
+// GetConstructorsList возвращает команды сезона
+func (erg *ErgastAPI) GetConstructorsList(season int) ([]models.Constructors, error) {
+	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/constructors.json", erg.url, season))
+	if err != nil {
+		return nil, fmt.Errorf("in constructorsList %w", err)
+	}
+	if len(resp.MRData.ConstructorTable.Constructors) > 0 {
+		return resp.MRData.ConstructorTable.Constructors, nil
+	}
+	return nil, temperrors.ErrEmptyList
+}
+
+// GetConstructor возвращает команду по идентификатору Ergast ("red_bull", "lotus")
+func (erg *ErgastAPI) GetConstructor(constructorID string) (models.Constructors, error) {
+	resp, err := erg.getStatsRequest(fmt.Sprintf("%s/constructors/%s.json", erg.url, url.PathEscape(constructorID)))
+	if err != nil {
+		return models.Constructors{}, fmt.Errorf("in getConstructor %w", err)
+	}
+	if len(resp.MRData.ConstructorTable.Constructors) > 0 {
+		return resp.MRData.ConstructorTable.Constructors[0], nil
+	}
+	return models.Constructors{}, temperrors.ErrEmptyList
+}
+
+// GetConstructorDrivers возвращает гонщиков команды в сезоне
+func (erg *ErgastAPI) GetConstructorDrivers(season int, constructorID string) ([]models.Driver, error) {
+	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/constructors/%s/drivers.json", erg.url, season, url.PathEscape(constructorID)))
+	if err != nil {
+		return nil, fmt.Errorf("in constructorDrivers %w", err)
+	}
+	if len(resp.MRData.DriverTable.Drivers) > 0 {
+		return resp.MRData.DriverTable.Drivers, nil
+	}
+	return nil, temperrors.ErrEmptyList
+}
+
+// GetConstructorResults возвращает результаты обеих машин команды во всех гонках сезона
+func (erg *ErgastAPI) GetConstructorResults(season int, constructorID string) ([]models.Race, error) {
+	resp, err := erg.getRequest(fmt.Sprintf("%s/%d/constructors/%s/results.json?limit=100", erg.url, season, url.PathEscape(constructorID)))
+	if err != nil {
+		return nil, fmt.Errorf("in constructorResults %w", err)
+	}
+	if len(resp.MRData.RaceTable.Races) > 0 {
+		return resp.MRData.RaceTable.Races, nil
+	}
+	return nil, temperrors.ErrEmptyList
+}
+
+// GetConstructorTitles возвращает итоговые зачёты сезонов, в которых команда была первой
+// в кубке конструкторов (включая идущий сезон, если команда лидирует)
+func (erg *ErgastAPI) GetConstructorTitles(constructorID string) ([]models.StandingsListItem, error) {
+	resp, err := erg.getStatsRequest(fmt.Sprintf("%s/constructors/%s/constructorStandings/1.json?limit=100", erg.url, url.PathEscape(constructorID)))
+	if err != nil {
+		return nil, fmt.Errorf("in constructorTitles %w", err)
+	}
+	return resp.MRData.StandingsTable.StandingsLists, nil
+}
+
+// CountConstructorSeasons возвращает число сезонов команды в чемпионате
+func (erg *ErgastAPI) CountConstructorSeasons(constructorID string) (int, error) {
+	return erg.countStats(fmt.Sprintf("%s/constructors/%s/seasons.json?limit=1", erg.url, url.PathEscape(constructorID)))
+}
+
+// CountConstructorWins возвращает число побед команды за всю историю
+func (erg *ErgastAPI) CountConstructorWins(constructorID string) (int, error) {
+	return erg.countStats(fmt.Sprintf("%s/constructors/%s/results/1.json?limit=1", erg.url, url.PathEscape(constructorID)))
+}
+
+// countStats возвращает общее число записей ответа Ergast (MRData.total)
+func (erg *ErgastAPI) countStats(url string) (int, error) {
+	resp, err := erg.getStatsRequest(url)
+	if err != nil {
+		return 0, fmt.Errorf("in countStats %w", err)
+	}
+	total, err := strconv.Atoi(resp.MRData.Total)
+	if err != nil {
+		return 0, fmt.Errorf("invalid total %q: %w", resp.MRData.Total, temperrors.ErrParse)
+	}
+	return total, nil
+}
+
+// standingsPath — путь зачёта в Ergast: "2024" (после последнего этапа) или "2024/5"
+func standingsPath(season int, round string) string {
+	if round == "last" {
+		return strconv.Itoa(season)
+	}
+	return fmt.Sprintf("%d/%s", season, round)
+}
+
 func (erg *ErgastAPI) getRequest(url string) (models.Object, error) {
 
 	if data, ok := erg.cache.get(url); ok {
@@ -126,6 +289,37 @@ func (erg *ErgastAPI) getRequest(url string) (models.Object, error) {
 		return data, nil
 	}
 
+	return erg.fetchRequest(url)
+}
+
+// getStatsRequest — запрос карьерной статистики через долгий кэш statsCache
+func (erg *ErgastAPI) getStatsRequest(url string) (models.Object, error) {
+	if data, ok := erg.statsCache.get(url); ok {
+		slog.Debug("stats cache hit", slog.String("url", url))
+		return data, nil
+	}
+
+	data, err := erg.request(url)
+	if err != nil {
+		return data, err
+	}
+	erg.statsCache.set(url, data)
+	return data, nil
+}
+
+// fetchRequest запрашивает Ergast без чтения кэша и сохраняет ответ в кэш,
+// чтобы команды сразу видели свежие данные
+func (erg *ErgastAPI) fetchRequest(url string) (models.Object, error) {
+	data, err := erg.request(url)
+	if err != nil {
+		return data, err
+	}
+	erg.cache.set(url, data)
+	return data, nil
+}
+
+// request выполняет запрос к Ergast и разбирает ответ
+func (erg *ErgastAPI) request(url string) (models.Object, error) {
 	var temp models.Object
 
 	resp, err := erg.client.Get(url)
@@ -145,6 +339,5 @@ func (erg *ErgastAPI) getRequest(url string) (models.Object, error) {
 		return temp, fmt.Errorf("error unmarshalling response: %w", err)
 	}
 
-	erg.cache.set(url, temp)
 	return temp, nil
 }
@@ -0,0 +1,83 @@
+package prediction
+
+import (
+	"fmt"
+	"time"
+)
+
+// sentNotificationsTableSchema — отправленные автоматические уведомления (напоминания о сессиях,
+// результаты, уведомления о раундах прогнозов).
+// Запись делается после отправки в чат, поэтому после перезапуска бот не повторяет уведомления.
+const sentNotificationsTableSchema = `CREATE TABLE IF NOT EXISTS sent_notifications (
+			key TEXT NOT NULL,
+			platform TEXT NOT NULL,
+			chat_id INTEGER NOT NULL,
+			sent_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY(key, platform, chat_id)
+		)`
+
+// IsNotificationSent проверяет, отправлялось ли уведомление key в чат платформы
+func (s *Storage) IsNotificationSent(key, platform string, chatID int64) (bool, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM sent_notifications WHERE key = ? AND platform = ? AND chat_id = ?`, key, platform, chatID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check sent notification: %w", err)
+	}
+	return count > 0, nil
+}
+
+// MarkNotificationSent отмечает уведомление key отправленным в чат платформы
+func (s *Storage) MarkNotificationSent(key, platform string, chatID int64) error {
+	_, err := s.db.Exec(`INSERT OR IGNORE INTO sent_notifications (key, platform, chat_id) VALUES (?, ?, ?)`, key, platform, chatID)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification sent: %w", err)
+	}
+	return nil
+}
+
+// pendingNotificationsTableSchema — уведомления о раундах прогнозов, которые ещё не доставлены
+// во все чаты. Запись делается, когда уведомление нужно разослать, и удаляется, когда оно
+// дошло до всех чатов; доставка в отдельные чаты отмечается в sent_notifications.
+const pendingNotificationsTableSchema = `CREATE TABLE IF NOT EXISTS pending_notifications (
+			key TEXT PRIMARY KEY,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`
+
+// AddPendingNotification ставит уведомление key в очередь рассылки
+func (s *Storage) AddPendingNotification(key string) error {
+	_, err := s.db.Exec(`INSERT OR IGNORE INTO pending_notifications (key) VALUES (?)`, key)
+	if err != nil {
+		return fmt.Errorf("failed to add pending notification: %w", err)
+	}
+	return nil
+}
+
+// GetPendingNotifications возвращает недоставленные уведомления с ключом, начинающимся
+// с prefix, поставленные в очередь не раньше since
+func (s *Storage) GetPendingNotifications(prefix string, since time.Time) ([]string, error) {
+	rows, err := s.db.Query(`SELECT key FROM pending_notifications WHERE substr(key, 1, ?) = ? AND created_at >= ? ORDER BY created_at, key`,
+		len(prefix), prefix, since.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan pending notification: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// DeletePendingNotification убирает уведомление key из очереди рассылки
+func (s *Storage) DeletePendingNotification(key string) error {
+	_, err := s.db.Exec(`DELETE FROM pending_notifications WHERE key = ?`, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete pending notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package prediction
+
+import (
+	"database/sql"
+	"fmt"
+	"racebot-vk/models"
+	"time"
+)
+
+// userProfilesTableSchema — кэш имён пользователей платформ
+const userProfilesTableSchema = `CREATE TABLE IF NOT EXISTS user_profiles (
+			platform TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			first_name TEXT NOT NULL DEFAULT '',
+			last_name TEXT NOT NULL DEFAULT '',
+			username TEXT NOT NULL DEFAULT '',
+			nickname TEXT,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY(platform, user_id)
+		)`
+
+// SaveUserProfile сохраняет имя пользователя, полученное с платформы.
+// Для пользователей, задавших никнейм, настоящее имя не сохраняется.
+func (s *Storage) SaveUserProfile(profile *models.UserProfile) error {
+	query := `INSERT INTO user_profiles (platform, user_id, first_name, last_name, username, updated_at)
+			  VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+			  ON CONFLICT(platform, user_id) DO UPDATE SET
+			  first_name = CASE WHEN nickname IS NULL THEN excluded.first_name ELSE '' END,
+			  last_name = CASE WHEN nickname IS NULL THEN excluded.last_name ELSE '' END,
+			  username = CASE WHEN nickname IS NULL THEN excluded.username ELSE '' END,
+			  updated_at = CURRENT_TIMESTAMP`
+	_, err := s.db.Exec(query, profile.Platform, profile.UserID, profile.FirstName, profile.LastName, profile.Username)
+	if err != nil {
+		return fmt.Errorf("failed to save user profile: %w", err)
+	}
+	return nil
+}
+
+// SetUserNickname задаёт никнейм пользователя и удаляет сохранённое настоящее имя.
+// Пустой никнейм отменяет отказ: имя будет снова получено с платформы при следующем обновлении.
+func (s *Storage) SetUserNickname(platform string, userID int, nickname string) error {
+	if nickname == "" {
+		// Нулевое время обновления — профиль считается устаревшим и обновится первым
+		query := `UPDATE user_profiles SET nickname = NULL, updated_at = ? WHERE platform = ? AND user_id = ?`
+		if _, err := s.db.Exec(query, time.Time{}.Format(sqliteTimeLayout), platform, userID); err != nil {
+			return fmt.Errorf("failed to reset user nickname: %w", err)
+		}
+		return nil
+	}
+
+	query := `INSERT INTO user_profiles (platform, user_id, nickname) VALUES (?, ?, ?)
+			  ON CONFLICT(platform, user_id) DO UPDATE SET
+			  nickname = excluded.nickname, first_name = '', last_name = '', username = ''`
+	if _, err := s.db.Exec(query, platform, userID, nickname); err != nil {
+		return fmt.Errorf("failed to set user nickname: %w", err)
+	}
+	return nil
+}
+
+// GetUserProfile возвращает профиль пользователя (nil, если профиля нет)
+func (s *Storage) GetUserProfile(platform string, userID int) (*models.UserProfile, error) {
+	query := `SELECT ` + profileColumns + ` FROM user_profiles WHERE platform = ? AND user_id = ?`
+
+	profile, err := scanUserProfile(s.db.QueryRow(query, platform, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
+	}
+	return profile, nil
+}
+
+// GetUserProfiles возвращает все профили по ключу "platform:user_id"
+func (s *Storage) GetUserProfiles() (map[string]models.UserProfile, error) {
+	rows, err := s.db.Query(`SELECT ` + profileColumns + ` FROM user_profiles`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user profiles: %w", err)
+	}
+	defer rows.Close()
+
+	profiles := make(map[string]models.UserProfile)
+	for rows.Next() {
+		profile, err := scanUserProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user profile row: %w", err)
+		}
+		profiles[fmt.Sprintf("%s:%d", profile.Platform, profile.UserID)] = *profile
+	}
+	return profiles, rows.Err()
+}
+
+// GetUsersForProfileRefresh возвращает ID участников прогнозов по платформам, чьи имена
+// ещё не получены или обновлялись раньше updatedBefore. Пользователи с никнеймом пропускаются.
+func (s *Storage) GetUsersForProfileRefresh(updatedBefore time.Time) (map[string][]int, error) {
+	query := `SELECT DISTINCT p.platform, p.user_id FROM predictions p
+			  LEFT JOIN user_profiles u ON u.platform = p.platform AND u.user_id = p.user_id
+			  WHERE u.user_id IS NULL OR (u.nickname IS NULL AND u.updated_at < ?)`
+	rows, err := s.db.Query(query, updatedBefore.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users for profile refresh: %w", err)
+	}
+	defer rows.Close()
+
+	users := make(map[string][]int)
+	for rows.Next() {
+		var platform string
+		var userID int
+		if err := rows.Scan(&platform, &userID); err != nil {
+			return nil, fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users[platform] = append(users[platform], userID)
+	}
+	return users, rows.Err()
+}
+
+// profileColumns — колонки user_profiles в порядке, ожидаемом scanUserProfile
+const profileColumns = `platform, user_id, first_name, last_name, username, nickname, updated_at`
+
+func scanUserProfile(row rowScanner) (*models.UserProfile, error) {
+	profile := &models.UserProfile{}
+	var nickname sql.NullString
+	if err := row.Scan(&profile.Platform, &profile.UserID, &profile.FirstName, &profile.LastName, &profile.Username, &nickname, &profile.UpdatedAt); err != nil {
+		return nil, err
+	}
+	profile.Nickname = nickname.String
+	return profile, nil
+}
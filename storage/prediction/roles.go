@@ -0,0 +1,79 @@
+package prediction
+
+import (
+	"fmt"
+	"racebot-vk/models"
+)
+
+// userRolesTableSchema — роли пользователей, выданные командами бота.
+// В одном чате (или глобально, chat_id = 0) у пользователя одна роль.
+const userRolesTableSchema = `CREATE TABLE IF NOT EXISTS user_roles (
+			platform TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			chat_id INTEGER NOT NULL DEFAULT 0,
+			role TEXT NOT NULL,
+			granted_by INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY(platform, user_id, chat_id)
+		)`
+
+// SetUserRole выдаёт пользователю роль в чате (заменяет прежнюю роль в этом чате)
+func (s *Storage) SetUserRole(role *models.UserRole) error {
+	query := `INSERT INTO user_roles (platform, user_id, chat_id, role, granted_by, created_at)
+			  VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+			  ON CONFLICT(platform, user_id, chat_id) DO UPDATE SET
+			  role = excluded.role, granted_by = excluded.granted_by, created_at = CURRENT_TIMESTAMP`
+	_, err := s.db.Exec(query, role.Platform, role.UserID, role.ChatID, string(role.Role), role.GrantedBy)
+	if err != nil {
+		return fmt.Errorf("failed to set user role: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserRole снимает роль пользователя в чате. Возвращает false, если роли не было.
+func (s *Storage) DeleteUserRole(platform string, userID int, chatID int64) (bool, error) {
+	result, err := s.db.Exec(`DELETE FROM user_roles WHERE platform = ? AND user_id = ? AND chat_id = ?`, platform, userID, chatID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user role: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// GetUserRoles возвращает роли пользователя: глобальную и роль в чате chatID
+func (s *Storage) GetUserRoles(platform string, userID int, chatID int64) ([]models.UserRole, error) {
+	query := `SELECT ` + roleColumns + ` FROM user_roles
+			  WHERE platform = ? AND user_id = ? AND (chat_id = 0 OR chat_id = ?)`
+	return s.queryRoles(query, platform, userID, chatID)
+}
+
+// GetChatRoles возвращает роли, действующие в чате платформы: глобальные и роли этого чата
+func (s *Storage) GetChatRoles(platform string, chatID int64) ([]models.UserRole, error) {
+	query := `SELECT ` + roleColumns + ` FROM user_roles
+			  WHERE platform = ? AND (chat_id = 0 OR chat_id = ?)
+			  ORDER BY chat_id ASC, created_at ASC`
+	return s.queryRoles(query, platform, chatID)
+}
+
+func (s *Storage) queryRoles(query string, args ...any) ([]models.UserRole, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []models.UserRole
+	for rows.Next() {
+		var role models.UserRole
+		var name string
+		if err := rows.Scan(&role.Platform, &role.UserID, &role.ChatID, &name, &role.GrantedBy, &role.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user role row: %w", err)
+		}
+		role.Role = models.Role(name)
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// roleColumns — колонки user_roles в порядке, ожидаемом queryRoles
+const roleColumns = `platform, user_id, chat_id, role, granted_by, created_at`
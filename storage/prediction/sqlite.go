@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"racebot-vk/models"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -42,182 +44,610 @@ func (s *Storage) Close() error {
 // initDB создаёт таблицы, если их нет
 func (s *Storage) initDB() error {
 	queries := []string{
-		`CREATE TABLE IF NOT EXISTS prediction_races (
+		racesTableSchema,
+		predictionsTableSchema,
+		`CREATE TABLE IF NOT EXISTS prediction_history (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			race_id TEXT NOT NULL UNIQUE,
+			platform TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			race_id TEXT NOT NULL,
+			driver_1 INTEGER NOT NULL,
+			driver_2 INTEGER NOT NULL,
+			driver_3 INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		userProfilesTableSchema,
+		userRolesTableSchema,
+		sentNotificationsTableSchema,
+		pendingNotificationsTableSchema,
+		subscriptionsTableSchema,
+		timezonesTableSchema,
+	}
+
+	for _, q := range queries {
+		if _, err := s.db.Exec(q); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	if err := s.migrate(); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_predictions_user_id ON predictions(platform, user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_predictions_race_id ON predictions(race_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_predictions_round_id ON predictions(round_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_prediction_races_active ON prediction_races(is_active)`,
+		`CREATE INDEX IF NOT EXISTS idx_prediction_races_race_id ON prediction_races(race_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_prediction_history_user ON prediction_history(platform, user_id, race_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_prediction_history_round ON prediction_history(platform, user_id, round_id)`,
+	}
+
+	for _, q := range indexes {
+		if _, err := s.db.Exec(q); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// racesTableSchema — актуальная схема таблицы раундов прогнозов.
+// На одну гонку может быть открыто несколько раундов: общий (platform = ”, chat_id = 0)
+// и раунды отдельных чатов, поэтому раунд определяется тройкой (race_id, platform, chat_id).
+const racesTableSchema = `CREATE TABLE IF NOT EXISTS prediction_races (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			race_id TEXT NOT NULL,
 			race_name TEXT NOT NULL,
+			platform TEXT NOT NULL DEFAULT '',
+			chat_id INTEGER NOT NULL DEFAULT 0,
 			is_active INTEGER NOT NULL DEFAULT 1,
 			driver_1 INTEGER,
 			driver_2 INTEGER,
 			driver_3 INTEGER,
 			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			closed_at DATETIME
-		)`,
-		`CREATE TABLE IF NOT EXISTS predictions (
+			closed_at DATETIME,
+			race_start DATETIME,
+			closes_at DATETIME,
+			qualifying_start DATETIME,
+			sprint_start DATETIME,
+			scoring TEXT NOT NULL DEFAULT 'classic',
+			classification TEXT,
+			is_sprint INTEGER NOT NULL DEFAULT 0,
+			pole INTEGER,
+			fastest_lap INTEGER,
+			first_dnf INTEGER,
+			constructor TEXT,
+			sprint_podium TEXT,
+			UNIQUE(race_id, platform, chat_id)
+		)`
+
+// predictionsTableSchema — актуальная схема таблицы прогнозов.
+// Пользователь определяется парой (platform, user_id), чтобы ID из VK и Telegram не пересекались.
+// Прогноз относится к раунду (round_id → prediction_races.id): один прогноз пользователя на раунд.
+const predictionsTableSchema = `CREATE TABLE IF NOT EXISTS predictions (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			platform TEXT NOT NULL DEFAULT 'vk',
 			user_id INTEGER NOT NULL,
 			race_id TEXT NOT NULL,
+			round_id INTEGER NOT NULL DEFAULT 0,
+			chat_id INTEGER NOT NULL DEFAULT 0,
 			driver_1 INTEGER NOT NULL,
 			driver_2 INTEGER NOT NULL,
 			driver_3 INTEGER NOT NULL,
 			points INTEGER NOT NULL DEFAULT 0,
 			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(user_id, race_id)
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_predictions_user_id ON predictions(user_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_predictions_race_id ON predictions(race_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_prediction_races_active ON prediction_races(is_active)`,
+			updated_at DATETIME,
+			pole INTEGER,
+			fastest_lap INTEGER,
+			first_dnf INTEGER,
+			constructor TEXT,
+			sprint_podium TEXT,
+			UNIQUE(platform, user_id, round_id)
+		)`
+
+// roundIDForRace — раунд гонки для данных, созданных до появления нескольких раундов
+// (тогда на гонку был ровно один раунд). Прогнозы без раунда получают отрицательный
+// номер, чтобы не нарушить уникальность.
+const roundIDForRace = `COALESCE((SELECT r.id FROM prediction_races r WHERE r.race_id = %[1]s.race_id), -%[1]s.id)`
+
+// migrate приводит БД, созданную старыми версиями бота, к актуальной схеме
+func (s *Storage) migrate() error {
+	hasPlatform, err := s.hasColumn("predictions", "platform")
+	if err != nil {
+		return err
+	}
+	if !hasPlatform {
+		// До появления Telegram все прогнозы приходили из VK. Уникальный ключ
+		// в SQLite нельзя изменить через ALTER TABLE, поэтому пересоздаём таблицу.
+		if err := s.execInTx(
+			`ALTER TABLE predictions RENAME TO predictions_old`,
+			predictionsTableSchema,
+			`INSERT INTO predictions (id, platform, user_id, race_id, round_id, driver_1, driver_2, driver_3, points, created_at)
+			 SELECT id, 'vk', user_id, race_id, `+fmt.Sprintf(roundIDForRace, "predictions_old")+`, driver_1, driver_2, driver_3, points, created_at FROM predictions_old`,
+			`DROP TABLE predictions_old`,
+		); err != nil {
+			return fmt.Errorf("failed to add platform to predictions: %w", err)
+		}
 	}
 
-	for _, q := range queries {
-		if _, err := s.db.Exec(q); err != nil {
-			return fmt.Errorf("failed to execute query: %w", err)
+	// Время старта гонки и дедлайн приёма прогнозов
+	if err := s.addColumnIfMissing("prediction_races", "race_start", "DATETIME"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("prediction_races", "closes_at", "DATETIME"); err != nil {
+		return err
+	}
+
+	// Правило подсчёта очков и полный финишный порядок для пересчёта
+	if err := s.addColumnIfMissing("prediction_races", "scoring", "TEXT NOT NULL DEFAULT 'classic'"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("prediction_races", "classification", "TEXT"); err != nil {
+		return err
+	}
+
+	// Время последнего изменения прогноза (NULL — прогноз не менялся)
+	if err := s.addColumnIfMissing("predictions", "updated_at", "DATETIME"); err != nil {
+		return err
+	}
+
+	// Чат, в котором сделан прогноз (0 — прогнозы, сделанные до появления колонки)
+	if err := s.addColumnIfMissing("predictions", "chat_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Дополнительные вопросы: реальные ответы в раунде, ответы пользователей в прогнозах и истории
+	if err := s.addColumnIfMissing("prediction_races", "is_sprint", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	for _, table := range []string{"prediction_races", "predictions", "prediction_history"} {
+		for _, column := range extrasColumnDefs {
+			if err := s.addColumnIfMissing(table, column.name, column.definition); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Старт квалификации и спринта — дедлайны отдельных дополнительных вопросов
+	if err := s.addColumnIfMissing("prediction_races", "qualifying_start", "DATETIME"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("prediction_races", "sprint_start", "DATETIME"); err != nil {
+		return err
+	}
+
+	// Несколько раундов на гонку: раунды чатов и привязка прогнозов к раунду
+	if err := s.migrateRounds(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// migrateRounds переводит раунды с уникального race_id на ключ (race_id, platform, chat_id)
+// и привязывает прогнозы и историю к раундам. Уникальные ключи в SQLite нельзя изменить
+// через ALTER TABLE, поэтому таблицы пересоздаются с сохранением id.
+func (s *Storage) migrateRounds() error {
+	hasChat, err := s.hasColumn("prediction_races", "chat_id")
+	if err != nil {
+		return err
+	}
+	if !hasChat {
+		columns := `id, race_id, race_name, is_active, driver_1, driver_2, driver_3, created_at, closed_at,
+			race_start, closes_at, qualifying_start, sprint_start, scoring, classification, is_sprint, ` + extrasColumns
+		if err := s.execInTx(
+			`ALTER TABLE prediction_races RENAME TO prediction_races_old`,
+			racesTableSchema,
+			`INSERT INTO prediction_races (`+columns+`) SELECT `+columns+` FROM prediction_races_old`,
+			`DROP TABLE prediction_races_old`,
+		); err != nil {
+			return fmt.Errorf("failed to add chat to prediction races: %w", err)
 		}
 	}
 
+	hasRound, err := s.hasColumn("predictions", "round_id")
+	if err != nil {
+		return err
+	}
+	if !hasRound {
+		columns := `id, platform, user_id, race_id, chat_id, driver_1, driver_2, driver_3, points, created_at, updated_at, ` + extrasColumns
+		if err := s.execInTx(
+			`ALTER TABLE predictions RENAME TO predictions_old`,
+			predictionsTableSchema,
+			`INSERT INTO predictions (`+columns+`, round_id)
+			 SELECT `+columns+`, `+fmt.Sprintf(roundIDForRace, "predictions_old")+` FROM predictions_old`,
+			`DROP TABLE predictions_old`,
+		); err != nil {
+			return fmt.Errorf("failed to add round to predictions: %w", err)
+		}
+	}
+
+	hasHistoryRound, err := s.hasColumn("prediction_history", "round_id")
+	if err != nil {
+		return err
+	}
+	if !hasHistoryRound {
+		if err := s.execInTx(
+			`ALTER TABLE prediction_history ADD COLUMN round_id INTEGER NOT NULL DEFAULT 0`,
+			`UPDATE prediction_history SET round_id = `+fmt.Sprintf(roundIDForRace, "prediction_history"),
+		); err != nil {
+			return fmt.Errorf("failed to add round to prediction history: %w", err)
+		}
+	}
 	return nil
 }
 
+// extrasColumnDefs — колонки ответов на дополнительные вопросы (NULL — ответа нет)
+var extrasColumnDefs = []struct {
+	name       string
+	definition string
+}{
+	{"pole", "INTEGER"},
+	{"fastest_lap", "INTEGER"},
+	{"first_dnf", "INTEGER"},
+	{"constructor", "TEXT"},
+	{"sprint_podium", "TEXT"},
+}
+
+// addColumnIfMissing добавляет колонку в таблицу, если её ещё нет
+func (s *Storage) addColumnIfMissing(table, column, definition string) error {
+	exists, err := s.hasColumn(table, column)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// hasColumn проверяет наличие колонки в таблице
+func (s *Storage) hasColumn(table, column string) (bool, error) {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("failed to get table info: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
+			return false, fmt.Errorf("failed to scan table info: %w", err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// execInTx выполняет запросы в одной транзакции
+func (s *Storage) execInTx(queries ...string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, q := range queries {
+		if _, err := tx.Exec(q); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
 // CreateRace создаёт новый раунд прогнозов
 func (s *Storage) CreateRace(race *models.PredictionRace) error {
-	query := `INSERT INTO prediction_races (race_id, race_name, is_active) VALUES (?, ?, ?)`
-	_, err := s.db.Exec(query, race.RaceID, race.RaceName, boolToInt(race.IsActive))
+	query := `INSERT INTO prediction_races (race_id, race_name, platform, chat_id, is_active, race_start, closes_at,
+			  qualifying_start, sprint_start, scoring, is_sprint)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := s.db.Exec(query, race.RaceID, race.RaceName, race.Platform, race.ChatID, boolToInt(race.IsActive), race.RaceStart, race.ClosesAt,
+		race.QualifyingStart, race.SprintStart, race.Scoring, boolToInt(race.IsSprint))
 	if err != nil {
 		return fmt.Errorf("failed to create race: %w", err)
 	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get race round id: %w", err)
+	}
+	race.ID = int(id)
 	return nil
 }
 
-// CloseRace закрывает приём прогнозов для указанной гонки
-func (s *Storage) CloseRace(raceID string) error {
-	query := `UPDATE prediction_races SET is_active = 0, closed_at = CURRENT_TIMESTAMP WHERE race_id = ?`
-	result, err := s.db.Exec(query, raceID)
+// CloseRace закрывает приём прогнозов в раунде
+func (s *Storage) CloseRace(roundID int) error {
+	query := `UPDATE prediction_races SET is_active = 0, closed_at = CURRENT_TIMESTAMP WHERE id = ?`
+	result, err := s.db.Exec(query, roundID)
 	if err != nil {
 		return fmt.Errorf("failed to close race: %w", err)
 	}
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
-		return fmt.Errorf("race %s not found", raceID)
+		return fmt.Errorf("round %d not found", roundID)
 	}
 	return nil
 }
 
-// SetRaceResults сохраняет реальные результаты гонки
-func (s *Storage) SetRaceResults(raceID string, d1, d2, d3 uint8) error {
-	query := `UPDATE prediction_races SET driver_1 = ?, driver_2 = ?, driver_3 = ? WHERE race_id = ?`
-	result, err := s.db.Exec(query, d1, d2, d3, raceID)
+// SetRaceResults сохраняет реальные результаты гонки во все её закрытые раунды. Открытые
+// раунды остаются без результатов и получают их после закрытия.
+// classification — полный финишный порядок, может быть пустым при ручном вводе.
+func (s *Storage) SetRaceResults(raceID string, d1, d2, d3 uint8, classification []uint8) error {
+	query := `UPDATE prediction_races SET driver_1 = ?, driver_2 = ?, driver_3 = ?, classification = ? WHERE race_id = ? AND is_active = 0`
+	result, err := s.db.Exec(query, d1, d2, d3, joinNumbers(classification), raceID)
 	if err != nil {
 		return fmt.Errorf("failed to set race results: %w", err)
 	}
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
-		return fmt.Errorf("race %s not found", raceID)
+		return fmt.Errorf("race %s has no closed rounds", raceID)
 	}
 	return nil
 }
 
-// GetActiveRace возвращает активный раунд прогнозов (если есть)
-func (s *Storage) GetActiveRace() (*models.PredictionRace, error) {
-	query := `SELECT id, race_id, race_name, is_active, driver_1, driver_2, driver_3, created_at, closed_at 
-			  FROM prediction_races WHERE is_active = 1 LIMIT 1`
-
-	race := &models.PredictionRace{}
-	var isActive int
-	var driver1, driver2, driver3 sql.NullInt64
-	var closedAt sql.NullTime
+// SettleRound сохраняет в закрытый раунд реальный подиум, финишный порядок, ответы
+// на дополнительные вопросы и очки прогнозов (id прогноза → очки) в одной транзакции.
+// Раунд, который уже получил результаты, не меняется.
+func (s *Storage) SettleRound(roundID int, podium, classification []uint8, extras models.PredictionExtras, points map[int]int) error {
+	if len(podium) != 3 {
+		return fmt.Errorf("round %d: podium needs 3 drivers, got %d", roundID, len(podium))
+	}
 
-	err := s.db.QueryRow(query).Scan(
-		&race.ID, &race.RaceID, &race.RaceName, &isActive,
-		&driver1, &driver2, &driver3,
-		&race.CreatedAt, &closedAt,
-	)
+	tx, err := s.db.Begin()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for id, p := range points {
+		if _, err := tx.Exec(`UPDATE predictions SET points = ? WHERE id = ? AND round_id = ?`, p, id, roundID); err != nil {
+			return fmt.Errorf("failed to update prediction points: %w", err)
 		}
-		return nil, fmt.Errorf("failed to get active race: %w", err)
 	}
 
-	race.IsActive = intToBool(isActive)
-	if driver1.Valid {
-		v := uint8(driver1.Int64)
-		race.Driver1 = &v
+	query := `UPDATE prediction_races SET driver_1 = ?, driver_2 = ?, driver_3 = ?, classification = ?,
+			  pole = ?, fastest_lap = ?, first_dnf = ?, constructor = ?, sprint_podium = ?
+			  WHERE id = ? AND is_active = 0 AND driver_1 IS NULL`
+	args := append([]any{podium[0], podium[1], podium[2], joinNumbers(classification)}, extrasArgs(extras)...)
+	result, err := tx.Exec(query, append(args, roundID)...)
+	if err != nil {
+		return fmt.Errorf("failed to set round results: %w", err)
 	}
-	if driver2.Valid {
-		v := uint8(driver2.Int64)
-		race.Driver2 = &v
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("round %d is not closed or already settled", roundID)
 	}
-	if driver3.Valid {
-		v := uint8(driver3.Int64)
-		race.Driver3 = &v
+	return tx.Commit()
+}
+
+// SetRaceScoring меняет правило подсчёта очков для раунда
+func (s *Storage) SetRaceScoring(roundID int, scoring string) error {
+	query := `UPDATE prediction_races SET scoring = ? WHERE id = ?`
+	result, err := s.db.Exec(query, scoring, roundID)
+	if err != nil {
+		return fmt.Errorf("failed to set race scoring: %w", err)
 	}
-	if closedAt.Valid {
-		race.ClosedAt = &closedAt.Time
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("round %d not found", roundID)
 	}
+	return nil
+}
 
-	return race, nil
+// GetActiveRaces возвращает все открытые раунды прогнозов (по возрастанию старта гонки)
+func (s *Storage) GetActiveRaces() ([]models.PredictionRace, error) {
+	query := `SELECT ` + raceColumns + ` FROM prediction_races WHERE is_active = 1 ORDER BY race_start ASC, id ASC`
+	return s.queryRaces(query)
 }
 
-// GetRaceByID возвращает раунд прогнозов по race_id
-func (s *Storage) GetRaceByID(raceID string) (*models.PredictionRace, error) {
-	query := `SELECT id, race_id, race_name, is_active, driver_1, driver_2, driver_3, created_at, closed_at 
-			  FROM prediction_races WHERE race_id = ?`
+// GetChatRaces возвращает раунды, доступные в чате платформы: общие раунды и раунды этого чата
+// (от новых к старым)
+func (s *Storage) GetChatRaces(platform string, chatID int64) ([]models.PredictionRace, error) {
+	query := `SELECT ` + raceColumns + ` FROM prediction_races
+			  WHERE chat_id = 0 OR (platform = ? AND chat_id = ?)
+			  ORDER BY created_at DESC, id DESC`
+	return s.queryRaces(query, platform, chatID)
+}
 
-	race := &models.PredictionRace{}
-	var isActive int
-	var driver1, driver2, driver3 sql.NullInt64
-	var closedAt sql.NullTime
+// GetRaceRounds возвращает все раунды на гонку race_id
+func (s *Storage) GetRaceRounds(raceID string) ([]models.PredictionRace, error) {
+	query := `SELECT ` + raceColumns + ` FROM prediction_races WHERE race_id = ? ORDER BY id ASC`
+	return s.queryRaces(query, raceID)
+}
 
-	err := s.db.QueryRow(query, raceID).Scan(
-		&race.ID, &race.RaceID, &race.RaceName, &isActive,
-		&driver1, &driver2, &driver3,
-		&race.CreatedAt, &closedAt,
-	)
+// GetRound возвращает раунд прогнозов по id (nil, если раунда нет)
+func (s *Storage) GetRound(roundID int) (*models.PredictionRace, error) {
+	query := `SELECT ` + raceColumns + ` FROM prediction_races WHERE id = ?`
+
+	race, err := scanRace(s.db.QueryRow(query, roundID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("failed to get race by id: %w", err)
+		return nil, fmt.Errorf("failed to get round: %w", err)
 	}
 
-	race.IsActive = intToBool(isActive)
-	if driver1.Valid {
-		v := uint8(driver1.Int64)
-		race.Driver1 = &v
+	return race, nil
+}
+
+// queryRaces выполняет запрос к prediction_races с колонками raceColumns
+func (s *Storage) queryRaces(query string, args ...any) ([]models.PredictionRace, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get races: %w", err)
 	}
-	if driver2.Valid {
-		v := uint8(driver2.Int64)
-		race.Driver2 = &v
+	defer rows.Close()
+
+	var races []models.PredictionRace
+	for rows.Next() {
+		race, err := scanRace(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan race row: %w", err)
+		}
+		races = append(races, *race)
 	}
-	if driver3.Valid {
-		v := uint8(driver3.Int64)
-		race.Driver3 = &v
+	return races, rows.Err()
+}
+
+// SavePrediction сохраняет прогноз пользователя и добавляет запись в историю прогнозов
+func (s *Storage) SavePrediction(pred *models.Prediction) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	if closedAt.Valid {
-		race.ClosedAt = &closedAt.Time
+	defer tx.Rollback()
+
+	var existing int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM predictions WHERE platform = ? AND user_id = ? AND round_id = ?`,
+		pred.Platform, pred.UserID, pred.RoundID).Scan(&existing)
+	if err != nil {
+		return fmt.Errorf("failed to check existing prediction: %w", err)
 	}
 
-	return race, nil
-}
+	action := models.PredictionActionSubmit
+	if existing > 0 {
+		action = models.PredictionActionEdit
+	}
 
-// SavePrediction сохраняет прогноз пользователя
-func (s *Storage) SavePrediction(pred *models.Prediction) error {
-	query := `INSERT INTO predictions (user_id, race_id, driver_1, driver_2, driver_3) 
-			  VALUES (?, ?, ?, ?, ?)
-			  ON CONFLICT(user_id, race_id) DO UPDATE SET 
-			  driver_1 = excluded.driver_1, driver_2 = excluded.driver_2, driver_3 = excluded.driver_3`
-	_, err := s.db.Exec(query, pred.UserID, pred.RaceID, pred.Driver1, pred.Driver2, pred.Driver3)
+	// Чат прогноза не меняется при правке: прогноз остаётся в рейтинге чата, где был отправлен
+	query := `INSERT INTO predictions (platform, user_id, race_id, round_id, chat_id, driver_1, driver_2, driver_3) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			  ON CONFLICT(platform, user_id, round_id) DO UPDATE SET 
+			  driver_1 = excluded.driver_1, driver_2 = excluded.driver_2, driver_3 = excluded.driver_3,
+			  updated_at = CURRENT_TIMESTAMP`
+	_, err = tx.Exec(query, pred.Platform, pred.UserID, pred.RaceID, pred.RoundID, pred.ChatID, pred.Driver1, pred.Driver2, pred.Driver3)
 	if err != nil {
 		return fmt.Errorf("failed to save prediction: %w", err)
 	}
+
+	if err := insertHistory(tx, pred, action); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SavePredictionExtras сохраняет ответы на дополнительные вопросы в существующий прогноз
+// и добавляет запись в историю прогнозов
+func (s *Storage) SavePredictionExtras(pred *models.Prediction) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE predictions SET pole = ?, fastest_lap = ?, first_dnf = ?, constructor = ?, sprint_podium = ?,
+			  updated_at = CURRENT_TIMESTAMP
+			  WHERE platform = ? AND user_id = ? AND round_id = ?`
+	result, err := tx.Exec(query, append(extrasArgs(pred.Extras), pred.Platform, pred.UserID, pred.RoundID)...)
+	if err != nil {
+		return fmt.Errorf("failed to save prediction extras: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("prediction %s:%d for round %d not found", pred.Platform, pred.UserID, pred.RoundID)
+	}
+
+	if err := insertHistory(tx, pred, models.PredictionActionExtras); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertHistory добавляет запись в историю прогнозов
+func insertHistory(tx *sql.Tx, pred *models.Prediction, action string) error {
+	query := `INSERT INTO prediction_history (platform, user_id, race_id, round_id, driver_1, driver_2, driver_3, action,
+			  pole, fastest_lap, first_dnf, constructor, sprint_podium)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	args := append([]any{pred.Platform, pred.UserID, pred.RaceID, pred.RoundID, pred.Driver1, pred.Driver2, pred.Driver3, action}, extrasArgs(pred.Extras)...)
+	if _, err := tx.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to save prediction history: %w", err)
+	}
 	return nil
 }
 
+// GetPredictionHistory возвращает историю прогнозов пользователя в раунде (от старых к новым)
+func (s *Storage) GetPredictionHistory(platform string, userID int, roundID int) ([]models.PredictionHistoryEntry, error) {
+	query := `SELECT id, platform, user_id, race_id, round_id, driver_1, driver_2, driver_3, action, created_at, ` + extrasColumns + `
+			  FROM prediction_history WHERE platform = ? AND user_id = ? AND round_id = ? ORDER BY id ASC`
+	rows, err := s.db.Query(query, platform, userID, roundID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prediction history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.PredictionHistoryEntry
+	for rows.Next() {
+		var h models.PredictionHistoryEntry
+		var extras nullExtras
+		if err := rows.Scan(append([]any{&h.ID, &h.Platform, &h.UserID, &h.RaceID, &h.RoundID, &h.Driver1, &h.Driver2, &h.Driver3, &h.Action, &h.CreatedAt}, extras.dest()...)...); err != nil {
+			return nil, fmt.Errorf("failed to scan prediction history row: %w", err)
+		}
+		h.Extras = extras.value()
+		history = append(history, h)
+	}
+	return history, nil
+}
+
+// CountPredictionEdits возвращает количество правок каждого прогноза в раунде по ключу "platform:user_id"
+func (s *Storage) CountPredictionEdits(roundID int) (map[string]int, error) {
+	query := `SELECT platform, user_id, COUNT(*) FROM prediction_history 
+			  WHERE round_id = ? AND action = ? GROUP BY platform, user_id`
+	rows, err := s.db.Query(query, roundID, models.PredictionActionEdit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count prediction edits: %w", err)
+	}
+	defer rows.Close()
+
+	edits := make(map[string]int)
+	for rows.Next() {
+		var platform string
+		var userID, count int
+		if err := rows.Scan(&platform, &userID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan prediction edits row: %w", err)
+		}
+		edits[fmt.Sprintf("%s:%d", platform, userID)] = count
+	}
+	return edits, nil
+}
+
+// GetUserPrediction возвращает прогноз пользователя в раунде (если есть)
+func (s *Storage) GetUserPrediction(platform string, userID int, roundID int) (*models.Prediction, error) {
+	query := `SELECT ` + predictionColumns + ` FROM predictions WHERE platform = ? AND user_id = ? AND round_id = ?`
+	rows, err := s.db.Query(query, platform, userID, roundID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user prediction: %w", err)
+	}
+	defer rows.Close()
+
+	predictions, err := scanPredictions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(predictions) == 0 {
+		return nil, nil
+	}
+	return &predictions[0], nil
+}
+
 // GetUserPredictions возвращает все прогнозы пользователя
-func (s *Storage) GetUserPredictions(userID int) ([]models.Prediction, error) {
-	query := `SELECT id, user_id, race_id, driver_1, driver_2, driver_3, points, created_at 
-			  FROM predictions WHERE user_id = ? ORDER BY created_at DESC`
-	rows, err := s.db.Query(query, userID)
+func (s *Storage) GetUserPredictions(platform string, userID int) ([]models.Prediction, error) {
+	query := `SELECT ` + predictionColumns + ` FROM predictions WHERE platform = ? AND user_id = ? ORDER BY created_at DESC`
+	rows, err := s.db.Query(query, platform, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user predictions: %w", err)
 	}
@@ -226,11 +656,10 @@ func (s *Storage) GetUserPredictions(userID int) ([]models.Prediction, error) {
 	return scanPredictions(rows)
 }
 
-// GetRacePredictions возвращает все прогнозы на указанную гонку
-func (s *Storage) GetRacePredictions(raceID string) ([]models.Prediction, error) {
-	query := `SELECT id, user_id, race_id, driver_1, driver_2, driver_3, points, created_at 
-			  FROM predictions WHERE race_id = ? ORDER BY points DESC, created_at ASC`
-	rows, err := s.db.Query(query, raceID)
+// GetRoundPredictions возвращает все прогнозы раунда
+func (s *Storage) GetRoundPredictions(roundID int) ([]models.Prediction, error) {
+	query := `SELECT ` + predictionColumns + ` FROM predictions WHERE round_id = ? ORDER BY points DESC, created_at ASC`
+	rows, err := s.db.Query(query, roundID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get race predictions: %w", err)
 	}
@@ -249,14 +678,42 @@ func (s *Storage) UpdatePredictionPoints(id, points int) error {
 	return nil
 }
 
-// GetLeaderboard возвращает общую таблицу лидеров
-func (s *Storage) GetLeaderboard() ([]models.UserStats, error) {
-	query := `SELECT user_id, SUM(points) as total_points, COUNT(*) as total_races
-			  FROM predictions
-			  GROUP BY user_id
+// GetLeaderboard возвращает таблицу лидеров в указанной области (сезон, чат, период)
+func (s *Storage) GetLeaderboard(scope models.LeaderboardScope) ([]models.UserStats, error) {
+	var conditions []string
+	var args []any
+
+	if scope.Season != 0 {
+		conditions = append(conditions, `substr(p.race_id, 1, instr(p.race_id, '_') - 1) = ?`)
+		args = append(args, strconv.Itoa(scope.Season))
+	}
+	if scope.ChatID != 0 {
+		conditions = append(conditions, `p.platform = ? AND p.chat_id = ?`)
+		args = append(args, scope.Platform, scope.ChatID)
+	}
+	// Период — по старту гонки; для раундов без времени старта — по времени прогноза
+	if !scope.From.IsZero() {
+		conditions = append(conditions, `COALESCE(r.race_start, p.created_at) >= ?`)
+		args = append(args, scope.From.UTC().Format(sqliteTimeLayout))
+	}
+	if !scope.To.IsZero() {
+		conditions = append(conditions, `COALESCE(r.race_start, p.created_at) < ?`)
+		args = append(args, scope.To.UTC().Format(sqliteTimeLayout))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := `SELECT p.platform, p.user_id, SUM(p.points) as total_points, COUNT(*) as total_races
+			  FROM predictions p
+			  LEFT JOIN prediction_races r ON r.id = p.round_id
+			  ` + where + `
+			  GROUP BY p.platform, p.user_id
 			  ORDER BY total_points DESC, total_races ASC`
 
-	rows, err := s.db.Query(query)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get leaderboard: %w", err)
 	}
@@ -265,7 +722,7 @@ func (s *Storage) GetLeaderboard() ([]models.UserStats, error) {
 	var stats []models.UserStats
 	for rows.Next() {
 		var st models.UserStats
-		if err := rows.Scan(&st.UserID, &st.TotalPoints, &st.TotalRaces); err != nil {
+		if err := rows.Scan(&st.Platform, &st.UserID, &st.TotalPoints, &st.TotalRaces); err != nil {
 			return nil, fmt.Errorf("failed to scan leaderboard row: %w", err)
 		}
 		if st.TotalRaces > 0 {
@@ -278,18 +735,18 @@ func (s *Storage) GetLeaderboard() ([]models.UserStats, error) {
 }
 
 // GetUserStats возвращает статистику пользователя
-func (s *Storage) GetUserStats(userID int) (*models.UserStats, error) {
-	query := `SELECT user_id, SUM(points) as total_points, COUNT(*) as total_races,
+func (s *Storage) GetUserStats(platform string, userID int) (*models.UserStats, error) {
+	query := `SELECT COALESCE(SUM(points), 0) as total_points, COUNT(*) as total_races,
 			  COALESCE(MAX(points), 0) as best_points
 			  FROM predictions
-			  WHERE user_id = ?`
+			  WHERE platform = ? AND user_id = ?`
 
-	st := &models.UserStats{}
+	st := &models.UserStats{Platform: platform, UserID: userID}
 	var bestPoints int
-	err := s.db.QueryRow(query, userID).Scan(&st.UserID, &st.TotalPoints, &st.TotalRaces, &bestPoints)
+	err := s.db.QueryRow(query, platform, userID).Scan(&st.TotalPoints, &st.TotalRaces, &bestPoints)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return &models.UserStats{UserID: userID}, nil
+			return st, nil
 		}
 		return nil, fmt.Errorf("failed to get user stats: %w", err)
 	}
@@ -299,8 +756,8 @@ func (s *Storage) GetUserStats(userID int) (*models.UserStats, error) {
 	}
 
 	// Находим лучшую гонку
-	bestQuery := `SELECT race_id FROM predictions WHERE user_id = ? AND points = ? LIMIT 1`
-	err = s.db.QueryRow(bestQuery, userID, bestPoints).Scan(&st.BestRaceID)
+	bestQuery := `SELECT race_id FROM predictions WHERE platform = ? AND user_id = ? AND points = ? LIMIT 1`
+	err = s.db.QueryRow(bestQuery, platform, userID, bestPoints).Scan(&st.BestRaceID)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, fmt.Errorf("failed to get best race: %w", err)
 	}
@@ -311,66 +768,168 @@ func (s *Storage) GetUserStats(userID int) (*models.UserStats, error) {
 
 // GetAllRaces возвращает все раунды прогнозов
 func (s *Storage) GetAllRaces() ([]models.PredictionRace, error) {
-	query := `SELECT id, race_id, race_name, is_active, driver_1, driver_2, driver_3, created_at, closed_at 
-			  FROM prediction_races ORDER BY created_at DESC`
-	rows, err := s.db.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get all races: %w", err)
-	}
-	defer rows.Close()
+	query := `SELECT ` + raceColumns + ` FROM prediction_races ORDER BY created_at DESC, id DESC`
+	return s.queryRaces(query)
+}
 
-	var races []models.PredictionRace
-	for rows.Next() {
-		var race models.PredictionRace
-		var isActive int
-		var driver1, driver2, driver3 sql.NullInt64
-		var closedAt sql.NullTime
-
-		if err := rows.Scan(
-			&race.ID, &race.RaceID, &race.RaceName, &isActive,
-			&driver1, &driver2, &driver3,
-			&race.CreatedAt, &closedAt,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan race row: %w", err)
-		}
+// --- Вспомогательные функции ---
 
-		race.IsActive = intToBool(isActive)
-		if driver1.Valid {
-			v := uint8(driver1.Int64)
-			race.Driver1 = &v
-		}
-		if driver2.Valid {
-			v := uint8(driver2.Int64)
-			race.Driver2 = &v
-		}
-		if driver3.Valid {
-			v := uint8(driver3.Int64)
-			race.Driver3 = &v
-		}
-		if closedAt.Valid {
-			race.ClosedAt = &closedAt.Time
-		}
+// sqliteTimeLayout — формат CURRENT_TIMESTAMP; с ним корректно сравниваются строки дат в SQLite
+const sqliteTimeLayout = "2006-01-02 15:04:05"
 
-		races = append(races, race)
-	}
+// raceColumns — колонки prediction_races в порядке, ожидаемом scanRace
+const raceColumns = `id, race_id, race_name, platform, chat_id, is_active, driver_1, driver_2, driver_3, created_at, closed_at, race_start, closes_at, qualifying_start, sprint_start, scoring, classification, is_sprint, ` + extrasColumns
+
+// predictionColumns — колонки predictions в порядке, ожидаемом scanPredictions
+const predictionColumns = `id, platform, user_id, race_id, round_id, chat_id, driver_1, driver_2, driver_3, points, created_at, updated_at, ` + extrasColumns
 
-	return races, nil
+// extrasColumns — колонки дополнительных вопросов в порядке, ожидаемом nullExtras.dest
+const extrasColumns = `pole, fastest_lap, first_dnf, constructor, sprint_podium`
+
+// rowScanner — общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
 }
 
-// --- Вспомогательные функции ---
+func scanRace(row rowScanner) (*models.PredictionRace, error) {
+	race := &models.PredictionRace{}
+	var isActive, isSprint int
+	var driver1, driver2, driver3 sql.NullInt64
+	var closedAt, raceStart, closesAt, qualifyingStart, sprintStart sql.NullTime
+	var classification sql.NullString
+	var extras nullExtras
+
+	err := row.Scan(append([]any{
+		&race.ID, &race.RaceID, &race.RaceName, &race.Platform, &race.ChatID, &isActive,
+		&driver1, &driver2, &driver3,
+		&race.CreatedAt, &closedAt, &raceStart, &closesAt, &qualifyingStart, &sprintStart,
+		&race.Scoring, &classification, &isSprint,
+	}, extras.dest()...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	race.IsActive = intToBool(isActive)
+	race.IsSprint = intToBool(isSprint)
+	race.Extras = extras.value()
+	if driver1.Valid {
+		v := uint8(driver1.Int64)
+		race.Driver1 = &v
+	}
+	if driver2.Valid {
+		v := uint8(driver2.Int64)
+		race.Driver2 = &v
+	}
+	if driver3.Valid {
+		v := uint8(driver3.Int64)
+		race.Driver3 = &v
+	}
+	if closedAt.Valid {
+		race.ClosedAt = &closedAt.Time
+	}
+	if raceStart.Valid {
+		race.RaceStart = &raceStart.Time
+	}
+	if closesAt.Valid {
+		race.ClosesAt = &closesAt.Time
+	}
+	if qualifyingStart.Valid {
+		race.QualifyingStart = &qualifyingStart.Time
+	}
+	if sprintStart.Valid {
+		race.SprintStart = &sprintStart.Time
+	}
+	if classification.Valid {
+		race.Classification = splitNumbers(classification.String)
+	}
+
+	return race, nil
+}
 
 func scanPredictions(rows *sql.Rows) ([]models.Prediction, error) {
 	var predictions []models.Prediction
 	for rows.Next() {
 		var p models.Prediction
-		if err := rows.Scan(&p.ID, &p.UserID, &p.RaceID, &p.Driver1, &p.Driver2, &p.Driver3, &p.Points, &p.CreatedAt); err != nil {
+		var updatedAt sql.NullTime
+		var extras nullExtras
+		if err := rows.Scan(append([]any{&p.ID, &p.Platform, &p.UserID, &p.RaceID, &p.RoundID, &p.ChatID, &p.Driver1, &p.Driver2, &p.Driver3, &p.Points, &p.CreatedAt, &updatedAt}, extras.dest()...)...); err != nil {
 			return nil, fmt.Errorf("failed to scan prediction row: %w", err)
 		}
+		p.Extras = extras.value()
+		p.UpdatedAt = p.CreatedAt
+		if updatedAt.Valid {
+			p.UpdatedAt = updatedAt.Time
+		}
 		predictions = append(predictions, p)
 	}
 	return predictions, nil
 }
 
+// nullExtras — приёмник для сканирования колонок extrasColumns
+type nullExtras struct {
+	pole, fastestLap, firstDNF sql.NullInt64
+	constructor, sprintPodium  sql.NullString
+}
+
+func (e *nullExtras) dest() []any {
+	return []any{&e.pole, &e.fastestLap, &e.firstDNF, &e.constructor, &e.sprintPodium}
+}
+
+func (e *nullExtras) value() models.PredictionExtras {
+	extras := models.PredictionExtras{
+		Pole:        uint8(e.pole.Int64),
+		FastestLap:  uint8(e.fastestLap.Int64),
+		FirstDNF:    uint8(e.firstDNF.Int64),
+		Constructor: e.constructor.String,
+	}
+	if e.sprintPodium.Valid {
+		extras.SprintPodium = splitNumbers(e.sprintPodium.String)
+	}
+	return extras
+}
+
+// extrasArgs возвращает значения колонок extrasColumns (NULL для пропущенных ответов)
+func extrasArgs(extras models.PredictionExtras) []any {
+	var constructor any
+	if extras.Constructor != "" {
+		constructor = extras.Constructor
+	}
+	return []any{nullNumber(extras.Pole), nullNumber(extras.FastestLap), nullNumber(extras.FirstDNF), constructor, joinNumbers(extras.SprintPodium)}
+}
+
+// nullNumber возвращает nil для нулевого номера гонщика
+func nullNumber(n uint8) any {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+// joinNumbers сериализует номера гонщиков в строку "1,4,16" (nil для пустого списка)
+func joinNumbers(nums []uint8) any {
+	if len(nums) == 0 {
+		return nil
+	}
+	parts := make([]string, 0, len(nums))
+	for _, n := range nums {
+		parts = append(parts, strconv.Itoa(int(n)))
+	}
+	return strings.Join(parts, ",")
+}
+
+// splitNumbers разбирает строку "1,4,16" в номера гонщиков
+func splitNumbers(str string) []uint8 {
+	var nums []uint8
+	for _, part := range strings.Split(str, ",") {
+		n, err := strconv.ParseUint(strings.TrimSpace(part), 10, 8)
+		if err != nil {
+			continue
+		}
+		nums = append(nums, uint8(n))
+	}
+	return nums
+}
+
 func boolToInt(b bool) int {
 	if b {
 		return 1
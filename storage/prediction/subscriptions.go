@@ -0,0 +1,88 @@
+package prediction
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"racebot-vk/models"
+	"strings"
+)
+
+// subscriptionsTableSchema — подписки чатов на рассылки. Запись заменяет подписку
+// по умолчанию из конфигурации; пустой список тем — чат отписан от всего.
+const subscriptionsTableSchema = `CREATE TABLE IF NOT EXISTS subscriptions (
+			platform TEXT NOT NULL,
+			chat_id INTEGER NOT NULL,
+			topics TEXT NOT NULL DEFAULT '',
+			updated_by INTEGER NOT NULL DEFAULT 0,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY(platform, chat_id)
+		)`
+
+// SetSubscription сохраняет подписку чата
+func (s *Storage) SetSubscription(sub *models.Subscription) error {
+	query := `INSERT INTO subscriptions (platform, chat_id, topics, updated_by, updated_at)
+			  VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+			  ON CONFLICT(platform, chat_id) DO UPDATE SET
+			  topics = excluded.topics, updated_by = excluded.updated_by, updated_at = CURRENT_TIMESTAMP`
+	_, err := s.db.Exec(query, sub.Platform, sub.ChatID, joinTopics(sub.Topics), sub.UpdatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to set subscription: %w", err)
+	}
+	return nil
+}
+
+// GetSubscription возвращает сохранённую подписку чата (nil, если чат её не менял)
+func (s *Storage) GetSubscription(platform string, chatID int64) (*models.Subscription, error) {
+	var sub models.Subscription
+	var topics string
+	err := s.db.QueryRow(`SELECT platform, chat_id, topics, updated_by, updated_at FROM subscriptions WHERE platform = ? AND chat_id = ?`, platform, chatID).
+		Scan(&sub.Platform, &sub.ChatID, &topics, &sub.UpdatedBy, &sub.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	sub.Topics = splitTopics(topics)
+	return &sub, nil
+}
+
+// GetSubscriptions возвращает все сохранённые подписки платформы
+func (s *Storage) GetSubscriptions(platform string) ([]models.Subscription, error) {
+	rows, err := s.db.Query(`SELECT platform, chat_id, topics, updated_by, updated_at FROM subscriptions WHERE platform = ?`, platform)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []models.Subscription
+	for rows.Next() {
+		var sub models.Subscription
+		var topics string
+		if err := rows.Scan(&sub.Platform, &sub.ChatID, &topics, &sub.UpdatedBy, &sub.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan subscription row: %w", err)
+		}
+		sub.Topics = splitTopics(topics)
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func joinTopics(topics []models.Topic) string {
+	parts := make([]string, 0, len(topics))
+	for _, t := range topics {
+		parts = append(parts, string(t))
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitTopics(value string) []models.Topic {
+	var topics []models.Topic
+	for _, part := range strings.Split(value, ",") {
+		if part != "" {
+			topics = append(topics, models.Topic(part))
+		}
+	}
+	return topics
+}
@@ -0,0 +1,54 @@
+package prediction
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// timezonesTableSchema — часовые пояса пользователей и чатов. Идентификаторы пользователей
+// и бесед на платформах не пересекаются (беседы VK — peer_id от 2000000000, группы Telegram —
+// отрицательные id), а личный диалог совпадает с пользователем, поэтому ключ у них общий.
+const timezonesTableSchema = `CREATE TABLE IF NOT EXISTS timezones (
+			platform TEXT NOT NULL,
+			owner_id INTEGER NOT NULL,
+			timezone TEXT NOT NULL,
+			updated_by INTEGER NOT NULL DEFAULT 0,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY(platform, owner_id)
+		)`
+
+// SetTimezone сохраняет часовой пояс пользователя или чата
+func (s *Storage) SetTimezone(platform string, ownerID int64, timezone string, updatedBy int) error {
+	query := `INSERT INTO timezones (platform, owner_id, timezone, updated_by, updated_at)
+			  VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+			  ON CONFLICT(platform, owner_id) DO UPDATE SET
+			  timezone = excluded.timezone, updated_by = excluded.updated_by, updated_at = CURRENT_TIMESTAMP`
+	if _, err := s.db.Exec(query, platform, ownerID, timezone, updatedBy); err != nil {
+		return fmt.Errorf("failed to set timezone: %w", err)
+	}
+	return nil
+}
+
+// GetTimezone возвращает часовой пояс пользователя или чата (пустая строка, если не задан)
+func (s *Storage) GetTimezone(platform string, ownerID int64) (string, error) {
+	var timezone string
+	err := s.db.QueryRow(`SELECT timezone FROM timezones WHERE platform = ? AND owner_id = ?`, platform, ownerID).Scan(&timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get timezone: %w", err)
+	}
+	return timezone, nil
+}
+
+// DeleteTimezone удаляет часовой пояс пользователя или чата. Возвращает false, если он не был задан.
+func (s *Storage) DeleteTimezone(platform string, ownerID int64) (bool, error) {
+	result, err := s.db.Exec(`DELETE FROM timezones WHERE platform = ? AND owner_id = ?`, platform, ownerID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete timezone: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
@@ -2,8 +2,15 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"racebot-vk/models"
+	"racebot-vk/service"
+	"racebot-vk/temperrors"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mymmrac/telego"
@@ -12,23 +19,34 @@ import (
 )
 
 type messageService interface {
-	GetDriverStandingsMessage(userDate time.Time) (string, error)
-	GetConstructorStandingsMessage(userDate time.Time) (string, error)
-	GetCalendarMessage(year int) (string, error)
-	GetNextRaceMessage(userDate time.Time, userTimestamp int) (string, error)
-	GetRaceResultsMessage(userDate time.Time, raceId string) (string, error)
+	GetDriversListMessage(season int) (string, error)
+	GetDriverStandingsMessage(season int, round string) (string, error)
+	GetStandingsProgressionMessage(season int) (string, error)
+	GetConstructorStandingsMessage(season int, round string) (string, error)
+	GetCalendarMessage(year int, loc *time.Location) (string, error)
+	GetNextRaceMessage(userDate time.Time, userTimestamp int, loc *time.Location) (string, error)
+	GetCountdownMessage(now time.Time, loc *time.Location) (string, error)
+	GetRaceResultsMessage(season int, raceId string) (string, error)
 	GetCountDaysAfterRaceMessage(userDate time.Time, raceId string) (string, error)
+	GetNextRace(userDate time.Time, userTimestamp int) (models.Race, error)
+	GetDriverProfileMessage(query string, now time.Time) (string, error)
+	GetConstructorProfileMessage(query string, now time.Time) (string, error)
+	GetHeadToHeadMessage(first, second string, season int) (string, error)
 }
 
 type TgAPI struct {
-	bot            *telego.Bot
-	updates        <-chan telego.Update
-	messageService messageService
-	handler        *th.BotHandler
-	cancel         context.CancelFunc
+	bot               *telego.Bot
+	updates           <-chan telego.Update
+	messageService    messageService
+	predictionService *service.PredictionService
+	roles             *service.RoleService
+	subscriptions     *service.SubscriptionService
+	timezones         *service.TimezoneService
+	handler           *th.BotHandler
+	cancel            context.CancelFunc
 }
 
-func NewTGAPI(token string, messageService messageService) (*TgAPI, error) {
+func NewTGAPI(token string, messageService messageService, predictionService *service.PredictionService, roles *service.RoleService, subscriptions *service.SubscriptionService, timezones *service.TimezoneService) (*TgAPI, error) {
 	bot, err := telego.NewBot(token)
 	if err != nil {
 		return nil, fmt.Errorf("error create tg bot from token: %w", err)
@@ -42,7 +60,16 @@ func NewTGAPI(token string, messageService messageService) (*TgAPI, error) {
 		return nil, fmt.Errorf("error taking updates from longpool: %w", err)
 	}
 
-	return &TgAPI{bot: bot, updates: updates, messageService: messageService, cancel: cancel}, nil
+	return &TgAPI{
+		bot:               bot,
+		updates:           updates,
+		messageService:    messageService,
+		predictionService: predictionService,
+		roles:             roles,
+		subscriptions:     subscriptions,
+		timezones:         timezones,
+		cancel:            cancel,
+	}, nil
 
 }
 
@@ -54,6 +81,10 @@ func (tg *TgAPI) Run(log *slog.Logger) {
 		log.Error("failed to create bot handler", slog.Any("error", err))
 	}
 	tg.messageHandler(log)
+	tg.predictionHandler(log)
+	tg.roleHandler(log)
+	tg.subscriptionHandler(log)
+	tg.timezoneHandler(log)
 	tg.handler.Start()
 	defer tg.handler.Stop()
 	defer tg.cancel()
@@ -73,6 +104,46 @@ func (tg *TgAPI) sendReply(ctx *th.Context, log *slog.Logger, chatID int64, mess
 	}
 }
 
+const seasonUsage = `Сезон и этап указываются после команды:
+/driverstandings 2008, /driverstandings 2008 5 — после 5-го этапа
+/constructorstandings 2010
+/progression 2021
+/calendar 1988
+/lastrace 2021 22 — сезон и номер этапа`
+
+const driverProfileUsage = `Профиль гонщика: /driver номер|код|фамилия
+/driver 1, /driver VER, /driver ферстаппен
+Гонщиков прошлых лет можно найти по фамилии латиницей: /driver senna, /driver michael schumacher`
+
+const constructorProfileUsage = `Профиль команды: /team название
+/team феррари, /team red bull, /team mclaren
+Команды прошлых лет ищутся по названию латиницей: /team tyrrell, /team brabham`
+
+const headToHeadUsage = `Сравнение гонщиков: /compare гонщик гонщик [год]
+/compare ферстаппен норрис — текущий сезон
+/compare HAM ROS 2016
+Гонщики указываются номером, кодом или фамилией.`
+
+// seasonArgs возвращает сезон и этап из аргументов команды: без аргументов — текущий сезон
+// и последний этап. withRound = false — команда принимает только сезон. При ошибке
+// возвращает ok = false и подсказку для ответа.
+func seasonArgs(message *telego.Message, withRound bool) (season int, round string, reply string, ok bool) {
+	userDate := getDateFromMessage(message.Date)
+	_, _, args := tu.ParseCommand(message.Text)
+	if !withRound && len(args) > 1 {
+		return 0, "", seasonUsage, false
+	}
+
+	season, round, err := service.ParseSeasonArgs(args, userDate)
+	if err != nil {
+		if errors.Is(err, temperrors.ErrSeasonOutOfRange) {
+			return 0, "", service.SeasonRangeMessage(userDate) + "\n\n" + seasonUsage, false
+		}
+		return 0, "", seasonUsage, false
+	}
+	return season, round, "", true
+}
+
 func (tg *TgAPI) messageHandler(log *slog.Logger) {
 
 	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
@@ -82,9 +153,13 @@ func (tg *TgAPI) messageHandler(log *slog.Logger) {
 			slog.Int64("peer_id", update.Message.Chat.ID),
 			slog.String("text", update.Message.Text))
 
-		userDate := getDateFromMessage(update.Message.Date)
+		season, round, reply, ok := seasonArgs(update.Message, true)
+		if !ok {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, reply, "driverstandings")
+			return nil
+		}
 
-		messageToUser, err := tg.messageService.GetDriverStandingsMessage(userDate)
+		messageToUser, err := tg.messageService.GetDriverStandingsMessage(season, round)
 		if err != nil {
 			log.Error("failed to get driver standings", slog.Any("error", err))
 		}
@@ -100,9 +175,35 @@ func (tg *TgAPI) messageHandler(log *slog.Logger) {
 			slog.Int64("peer_id", update.Message.Chat.ID),
 			slog.String("text", update.Message.Text))
 
-		userDate := getDateFromMessage(update.Message.Date)
+		season, _, reply, ok := seasonArgs(update.Message, false)
+		if !ok {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, reply, "progression")
+			return nil
+		}
+
+		messageToUser, err := tg.messageService.GetStandingsProgressionMessage(season)
+		if err != nil {
+			log.Error("failed to get standings progression", slog.Any("error", err))
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, messageToUser, "progression")
+		return nil
+	}, th.CommandEqual("progression"))
+
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		season, _, reply, ok := seasonArgs(update.Message, false)
+		if !ok {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, reply, "calendar")
+			return nil
+		}
 
-		messageToUser, err := tg.messageService.GetCalendarMessage(userDate.Year())
+		messageToUser, err := tg.messageService.GetCalendarMessage(season, tg.userLocation(update.Message))
 		if err != nil {
 			log.Error("failed to get calendar", slog.Any("error", err))
 		}
@@ -118,9 +219,13 @@ func (tg *TgAPI) messageHandler(log *slog.Logger) {
 			slog.Int64("peer_id", update.Message.Chat.ID),
 			slog.String("text", update.Message.Text))
 
-		userDate := getDateFromMessage(update.Message.Date)
+		season, round, reply, ok := seasonArgs(update.Message, true)
+		if !ok {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, reply, "constructorstandings")
+			return nil
+		}
 
-		messageToUser, err := tg.messageService.GetConstructorStandingsMessage(userDate)
+		messageToUser, err := tg.messageService.GetConstructorStandingsMessage(season, round)
 		if err != nil {
 			log.Error("failed to get constructor standings", slog.Any("error", err))
 		}
@@ -136,9 +241,13 @@ func (tg *TgAPI) messageHandler(log *slog.Logger) {
 			slog.Int64("peer_id", update.Message.Chat.ID),
 			slog.String("text", update.Message.Text))
 
-		userDate := getDateFromMessage(update.Message.Date)
+		season, round, reply, ok := seasonArgs(update.Message, true)
+		if !ok {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, reply, "lastrace")
+			return nil
+		}
 
-		messageToUser, err := tg.messageService.GetRaceResultsMessage(userDate, "last")
+		messageToUser, err := tg.messageService.GetRaceResultsMessage(season, round)
 		if err != nil {
 			log.Error("failed to get last race", slog.Any("error", err))
 		}
@@ -156,7 +265,7 @@ func (tg *TgAPI) messageHandler(log *slog.Logger) {
 
 		userDate := getDateFromMessage(update.Message.Date)
 
-		messageToUser, err := tg.messageService.GetNextRaceMessage(userDate, int(update.Message.Date))
+		messageToUser, err := tg.messageService.GetNextRaceMessage(userDate, int(update.Message.Date), tg.userLocation(update.Message))
 		if err != nil {
 			log.Error("failed to get next race", slog.Any("error", err))
 		}
@@ -165,6 +274,24 @@ func (tg *TgAPI) messageHandler(log *slog.Logger) {
 		return nil
 	}, th.CommandEqual("nextrace"))
 
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		userDate := getDateFromMessage(update.Message.Date)
+
+		messageToUser, err := tg.messageService.GetCountdownMessage(userDate, tg.userLocation(update.Message))
+		if err != nil {
+			log.Error("failed to get countdown", slog.Any("error", err))
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, messageToUser, "countdown")
+		return nil
+	}, th.CommandEqual("countdown"))
+
 	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
 
 		log.Info(
@@ -182,6 +309,1300 @@ func (tg *TgAPI) messageHandler(log *slog.Logger) {
 		tg.sendReply(ctx, log, update.Message.Chat.ID, messageToUser, "daysafterrace")
 		return nil
 	}, th.CommandEqual("daysafterrace"))
+
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		_, _, args := tu.ParseCommand(update.Message.Text)
+		if len(args) == 0 {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, driverProfileUsage, "driver")
+			return nil
+		}
+
+		query := strings.Join(args, " ")
+		messageToUser, err := tg.messageService.GetDriverProfileMessage(query, getDateFromMessage(update.Message.Date))
+		if err != nil {
+			log.Error("failed to get driver profile", slog.String("query", query), slog.Any("error", err))
+			messageToUser = "Не удалось получить профиль гонщика. Повторите попытку позже."
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, messageToUser, "driver")
+		return nil
+	}, th.CommandEqual("driver"))
+
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		_, _, args := tu.ParseCommand(update.Message.Text)
+		if len(args) == 0 {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, constructorProfileUsage, "team")
+			return nil
+		}
+
+		query := strings.Join(args, " ")
+		messageToUser, err := tg.messageService.GetConstructorProfileMessage(query, getDateFromMessage(update.Message.Date))
+		if err != nil {
+			log.Error("failed to get constructor profile", slog.String("query", query), slog.Any("error", err))
+			messageToUser = "Не удалось получить профиль команды. Повторите попытку позже."
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, messageToUser, "team")
+		return nil
+	}, th.CommandEqual("team"))
+
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		userDate := getDateFromMessage(update.Message.Date)
+		_, _, args := tu.ParseCommand(update.Message.Text)
+		first, second, season, err := service.ParseHeadToHeadArgs(args, userDate)
+		if err != nil {
+			reply := headToHeadUsage
+			if errors.Is(err, temperrors.ErrSeasonOutOfRange) {
+				reply = service.SeasonRangeMessage(userDate) + "\n\n" + headToHeadUsage
+			}
+			tg.sendReply(ctx, log, update.Message.Chat.ID, reply, "compare")
+			return nil
+		}
+
+		messageToUser, err := tg.messageService.GetHeadToHeadMessage(first, second, season)
+		if err != nil {
+			log.Error("failed to get head-to-head", slog.String("first", first), slog.String("second", second), slog.Any("error", err))
+			messageToUser = "Не удалось сравнить гонщиков. Повторите попытку позже."
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, messageToUser, "compare")
+		return nil
+	}, th.CommandEqual("compare"))
+}
+
+// SendToChat отправляет сообщение в один чат (для раундов прогнозов отдельного чата)
+func (tg *TgAPI) SendToChat(log *slog.Logger, chatID int64, message string) error {
+	_, err := tg.bot.SendMessage(context.Background(), tu.Message(tu.ID(chatID), message))
+	if err != nil {
+		log.Error("failed to send message",
+			slog.String("command", "broadcast"),
+			slog.Int64("chat_id", chatID),
+			slog.Any("error", err))
+		return fmt.Errorf("failed to send message to chat %d: %w", chatID, err)
+	}
+	return nil
+}
+
+// Platform возвращает платформу, в которую рассылает сообщения TgAPI
+func (tg *TgAPI) Platform() string {
+	return models.PlatformTelegram
+}
+
+// GetUserProfiles получает имена пользователей Telegram через getChat
+func (tg *TgAPI) GetUserProfiles(userIDs []int) ([]models.UserProfile, error) {
+	profiles := make([]models.UserProfile, 0, len(userIDs))
+	for _, id := range userIDs {
+		chat, err := tg.bot.GetChat(context.Background(), &telego.GetChatParams{ChatID: tu.ID(int64(id))})
+		if err != nil {
+			// Пользователь мог заблокировать бота — остальных обновляем
+			continue
+		}
+		profiles = append(profiles, models.UserProfile{
+			Platform:  models.PlatformTelegram,
+			UserID:    id,
+			FirstName: chat.FirstName,
+			LastName:  chat.LastName,
+			Username:  chat.Username,
+		})
+	}
+	return profiles, nil
+}
+
+// saveUserProfile обновляет имя автора сообщения в кэше профилей
+func (tg *TgAPI) saveUserProfile(log *slog.Logger, user *telego.User) {
+	err := tg.predictionService.SaveUserProfile(models.UserProfile{
+		Platform:  models.PlatformTelegram,
+		UserID:    int(user.ID),
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Username:  user.Username,
+	})
+	if err != nil {
+		log.Warn("failed to save user profile", slog.Int64("user_id", user.ID), slog.Any("error", err))
+	}
+}
+
+// hasRole проверяет роль автора сообщения в чате сообщения
+func (tg *TgAPI) hasRole(message *telego.Message, role models.Role) bool {
+	return message.From != nil && tg.roles.HasRole(models.PlatformTelegram, int(message.From.ID), message.Chat.ID, role)
+}
+
+// hasRoundRole проверяет роль автора сообщения для управления раундом:
+// общим раундом управляют роли бота, раундом чата — ещё и роли этого чата
+func (tg *TgAPI) hasRoundRole(ctx *th.Context, log *slog.Logger, message *telego.Message, race *models.PredictionRace, role models.Role, commandName string) bool {
+	if message.From != nil && tg.roles.HasRole(models.PlatformTelegram, int(message.From.ID), race.ChatID, role) {
+		return true
+	}
+	tg.sendReply(ctx, log, message.Chat.ID, "Недостаточно прав: общим конкурсом прогнозов управляют администраторы бота.", commandName)
+	return false
+}
+
+// predictionHandler регистрирует команды конкурса прогнозов
+func (tg *TgAPI) predictionHandler(log *slog.Logger) {
+
+	// /startpredict — открывает конкурс прогнозов (для администраторов; администратор
+	// группы, не являющийся администратором бота, открывает раунд только для своей группы)
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if !tg.hasRole(update.Message, models.RoleAdmin) {
+			return nil
+		}
+
+		userDate := getDateFromMessage(update.Message.Date)
+
+		nxtRc, err := tg.messageService.GetNextRace(userDate, int(update.Message.Date))
+		if err != nil {
+			log.Error("failed to get next race for prediction", slog.Any("error", err))
+			return nil
+		}
+
+		// Необязательные аргументы: chat — раунд только для этого чата,
+		// правило подсчёта очков — /startpredict joker, /startpredict chat joker
+		scoring := ""
+		var chatID int64
+		_, _, args := tu.ParseCommand(update.Message.Text)
+		for _, arg := range args {
+			if strings.EqualFold(arg, "chat") || strings.EqualFold(arg, "чат") {
+				chatID = update.Message.Chat.ID
+				continue
+			}
+			scoring = arg
+		}
+		if chatID == 0 && !tg.roles.HasRole(models.PlatformTelegram, int(update.Message.From.ID), 0, models.RoleAdmin) {
+			chatID = update.Message.Chat.ID
+		}
+
+		predRace, err := tg.predictionService.StartPrediction(nxtRc, scoring, models.PlatformTelegram, chatID)
+		if err != nil {
+			log.Error("failed to start prediction", slog.Any("error", err))
+			switch {
+			case errors.Is(err, temperrors.ErrUnknownScoringRule):
+				tg.sendReply(ctx, log, update.Message.Chat.ID, service.GetScoringRulesMessage(), "startpredict")
+			case errors.Is(err, temperrors.ErrRoundAlreadyOpen):
+				tg.sendReply(ctx, log, update.Message.Chat.ID, predictionErrorMessage(err, tg.userLocation(update.Message)), "startpredict")
+			}
+			return nil
+		}
+
+		driversMessage, err := tg.messageService.GetDriversListMessage(userDate.Year())
+		if err != nil {
+			log.Error("failed to get drivers list", slog.Any("error", err))
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, tg.predictionService.GetAnnouncementMessage(predRace, tg.timezones.ChatLocation(models.PlatformTelegram, update.Message.Chat.ID)), "startpredict")
+		tg.sendReply(ctx, log, update.Message.Chat.ID, driversMessage, "startpredict")
+
+		log.Info("Prediction started", slog.String("race_id", predRace.RaceID), slog.Int("round_id", predRace.ID), slog.Int64("chat_id", chatID))
+		return nil
+	}, th.CommandEqual("startpredict"))
+
+	// /predict [#этап] N1 N2 N3 — принимает прогноз пользователя
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if update.Message.From == nil {
+			return nil
+		}
+
+		activeRace, args := tg.resolveActiveRound(ctx, log, update.Message, "predict")
+		if activeRace == nil {
+			return nil
+		}
+
+		if args == "" {
+			// Без аргументов — выбор гонщиков кнопками
+			tg.startPredictWizard(ctx, log, update.Message, activeRace)
+			return nil
+		}
+		d1, d2, d3, err := tg.predictionService.ParsePrediction(activeRace.RaceID, args)
+		if err != nil {
+			msg := "Неверный формат сообщения! Укажите гонщиков, которые на ваш взгляд займут первые 3 места (номер, код или фамилия), в формате:\n\n/predict №_топ1 №_топ2 №_топ3\n\nПример: /predict VER NOR LEC"
+			if errors.Is(err, temperrors.ErrUnknownDriver) {
+				msg = predictionErrorMessage(err, tg.userLocation(update.Message))
+			}
+			tg.sendReply(ctx, log, update.Message.Chat.ID, msg, "predict")
+			return nil
+		}
+
+		userID := int(update.Message.From.ID)
+		err = tg.predictionService.SubmitPrediction(models.PlatformTelegram, userID, update.Message.Chat.ID, activeRace.ID, d1, d2, d3)
+		if err != nil {
+			log.Error("failed to save prediction", slog.Any("error", err))
+			tg.sendReply(ctx, log, update.Message.Chat.ID, predictionErrorMessage(err, tg.userLocation(update.Message)), "predict")
+			return nil
+		}
+
+		tg.saveUserProfile(log, update.Message.From)
+		tg.sendReply(ctx, log, update.Message.Chat.ID, fmt.Sprintf("Ваш прогноз принят: 1. №%d, 2. №%d, 3. №%d", d1, d2, d3), "predict")
+		log.Info("Prediction recorded", slog.Int("user_id", userID), slog.Int("d1", int(d1)), slog.Int("d2", int(d2)), slog.Int("d3", int(d3)))
+		return nil
+	}, th.CommandEqual("predict"))
+
+	// Кнопки мастера прогноза (/predict без аргументов)
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+		query := update.CallbackQuery
+
+		log.Info(
+			"CALLBACK info",
+			slog.Int64("user_id", query.From.ID),
+			slog.String("data", query.Data))
+
+		tg.handlePredictWizard(ctx, log, query)
+		return nil
+	}, th.CallbackDataPrefix(predictWizardPrefix))
+
+	// /mypredict [#этап] — показывает прогноз пользователя на активную гонку
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if update.Message.From == nil {
+			return nil
+		}
+
+		activeRace, _ := tg.resolveActiveRound(ctx, log, update.Message, "mypredict")
+		if activeRace == nil {
+			return nil
+		}
+
+		pred, err := tg.predictionService.GetUserPrediction(models.PlatformTelegram, int(update.Message.From.ID), activeRace.ID)
+		if err != nil {
+			log.Error("failed to get user prediction", slog.Any("error", err))
+			return nil
+		}
+		if pred == nil {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, fmt.Sprintf("Вы ещё не оставили прогноз на гонку '%s'. Используйте /predict №_топ1 №_топ2 №_топ3", activeRace.RaceName), "mypredict")
+			return nil
+		}
+
+		msg := fmt.Sprintf("Ваш прогноз на гонку '%s': 1. №%d, 2. №%d, 3. №%d", activeRace.RaceName, pred.Driver1, pred.Driver2, pred.Driver3)
+		if !pred.Extras.IsEmpty() {
+			msg += fmt.Sprintf("\nДополнительные вопросы: %s", service.FormatExtras(pred.Extras))
+		}
+		tg.sendReply(ctx, log, update.Message.Chat.ID, msg, "mypredict")
+		return nil
+	}, th.CommandEqual("mypredict"))
+
+	// /predictextra [#этап] key=value ... — ответы на дополнительные вопросы к прогнозу
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if update.Message.From == nil {
+			return nil
+		}
+
+		activeRace, args := tg.resolveActiveRound(ctx, log, update.Message, "predictextra")
+		if activeRace == nil {
+			return nil
+		}
+
+		extras, err := tg.predictionService.ParseExtras(activeRace.RaceID, args)
+		if err != nil {
+			msg := "Неверный формат сообщения! Укажите ответы в формате:\n\n/predictextra pole=№ fastest=№ dnf=№ team=название sprint=№,№,№\n\nЛюбой вопрос можно пропустить."
+			if errors.Is(err, temperrors.ErrUnknownDriver) {
+				msg = predictionErrorMessage(err, tg.userLocation(update.Message))
+			}
+			tg.sendReply(ctx, log, update.Message.Chat.ID, msg, "predictextra")
+			return nil
+		}
+
+		userID := int(update.Message.From.ID)
+		pred, err := tg.predictionService.SubmitExtras(models.PlatformTelegram, userID, activeRace.ID, extras)
+		if err != nil {
+			log.Error("failed to save prediction extras", slog.Any("error", err))
+			tg.sendReply(ctx, log, update.Message.Chat.ID, predictionErrorMessage(err, tg.userLocation(update.Message)), "predictextra")
+			return nil
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, fmt.Sprintf("Ответы на дополнительные вопросы приняты: %s", service.FormatExtras(pred.Extras)), "predictextra")
+		log.Info("Prediction extras recorded", slog.Int("user_id", userID))
+		return nil
+	}, th.CommandEqual("predictextra"))
+
+	// /nickname [ник | reset] — никнейм вместо настоящего имени в рейтингах
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if update.Message.From == nil {
+			return nil
+		}
+		userID := int(update.Message.From.ID)
+
+		_, _, args := tu.ParseCommand(update.Message.Text)
+		if len(args) == 0 {
+			msg, err := tg.predictionService.GetNicknameMessage(models.PlatformTelegram, userID)
+			if err != nil {
+				log.Error("failed to get nickname", slog.Any("error", err))
+				return nil
+			}
+			tg.sendReply(ctx, log, update.Message.Chat.ID, msg, "nickname")
+			return nil
+		}
+
+		nickname, err := tg.predictionService.SetNickname(models.PlatformTelegram, userID, strings.Join(args, " "))
+		if err != nil {
+			log.Error("failed to set nickname", slog.Any("error", err))
+			tg.sendReply(ctx, log, update.Message.Chat.ID, service.NicknameUsage, "nickname")
+			return nil
+		}
+
+		if nickname == "" {
+			tg.saveUserProfile(log, update.Message.From)
+			tg.sendReply(ctx, log, update.Message.Chat.ID, "Никнейм сброшен: в рейтингах снова будет показано ваше имя.", "nickname")
+			return nil
+		}
+		tg.sendReply(ctx, log, update.Message.Chat.ID, fmt.Sprintf("Теперь в рейтингах и итогах прогнозов вместо вашего имени будет показан никнейм «%s».", nickname), "nickname")
+		return nil
+	}, th.CommandEqual("nickname"))
+
+	// /leaderboard [сезон | чат | всё | дата дата] — таблица лидеров
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		_, _, args := tu.ParseCommand(update.Message.Text)
+		userDate := getDateFromMessage(update.Message.Date)
+		scope, err := service.ParseLeaderboardScope(args, models.PlatformTelegram, update.Message.Chat.ID, userDate, tg.userLocation(update.Message))
+		if err != nil {
+			msg := service.LeaderboardUsage
+			if errors.Is(err, temperrors.ErrSeasonOutOfRange) {
+				msg = service.SeasonRangeMessage(userDate) + "\n\n" + msg
+			}
+			tg.sendReply(ctx, log, update.Message.Chat.ID, msg, "leaderboard")
+			return nil
+		}
+
+		messageToUser, err := tg.predictionService.GetLeaderboardMessage(scope)
+		if err != nil {
+			log.Error("failed to get leaderboard", slog.Any("error", err))
+			return nil
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, messageToUser, "leaderboard")
+		return nil
+	}, th.CommandEqual("leaderboard"))
+
+	// /mystats — статистика пользователя
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if update.Message.From == nil {
+			return nil
+		}
+
+		messageToUser, err := tg.predictionService.GetUserStatsMessage(models.PlatformTelegram, int(update.Message.From.ID))
+		if err != nil {
+			log.Error("failed to get user stats", slog.Any("error", err))
+			return nil
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, messageToUser, "mystats")
+		return nil
+	}, th.CommandEqual("mystats"))
+
+	// /predicthistory — история прогноза пользователя на текущую гонку
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if update.Message.From == nil {
+			return nil
+		}
+
+		race, _ := tg.resolveLatestRound(ctx, log, update.Message, "predicthistory")
+		if race == nil {
+			return nil
+		}
+
+		messageToUser, err := tg.predictionService.GetUserHistoryMessage(models.PlatformTelegram, int(update.Message.From.ID), race, tg.userLocation(update.Message))
+		if err != nil {
+			log.Error("failed to get prediction history", slog.Any("error", err))
+			return nil
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, messageToUser, "predicthistory")
+		return nil
+	}, th.CommandEqual("predicthistory"))
+
+	// /predictions — все прогнозы на текущую гонку со временем правок (для модераторов)
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if !tg.hasRole(update.Message, models.RoleModerator) {
+			return nil
+		}
+
+		race, _ := tg.resolveLatestRound(ctx, log, update.Message, "predictions")
+		if race == nil {
+			return nil
+		}
+		if !tg.hasRoundRole(ctx, log, update.Message, race, models.RoleModerator, "predictions") {
+			return nil
+		}
+
+		messageToUser, err := tg.predictionService.GetRaceAuditMessage(race, tg.userLocation(update.Message))
+		if err != nil {
+			log.Error("failed to get prediction audit", slog.Any("error", err))
+			return nil
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, messageToUser, "predictions")
+		return nil
+	}, th.CommandEqual("predictions"))
+
+	// /predictrules [rule] — правила подсчёта очков; с аргументом меняет правило текущего раунда (для администраторов)
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if !tg.hasRole(update.Message, models.RoleAdmin) {
+			return nil
+		}
+
+		if _, _, args := tu.ParseCommand(update.Message.Text); len(args) == 0 {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, service.GetScoringRulesMessage(), "predictrules")
+			return nil
+		}
+
+		race, args := tg.resolveLatestRound(ctx, log, update.Message, "predictrules")
+		if race == nil {
+			return nil
+		}
+		if !tg.hasRoundRole(ctx, log, update.Message, race, models.RoleAdmin, "predictrules") {
+			return nil
+		}
+
+		rule, err := tg.predictionService.SetRaceScoring(race.ID, args)
+		if err != nil {
+			log.Error("failed to set race scoring", slog.Any("error", err))
+			if errors.Is(err, temperrors.ErrUnknownScoringRule) {
+				tg.sendReply(ctx, log, update.Message.Chat.ID, service.GetScoringRulesMessage(), "predictrules")
+			}
+			return nil
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, fmt.Sprintf("Для гонки '%s' установлено правило подсчёта очков: %s — %s.", race.RaceName, rule.Name(), rule.Description()), "predictrules")
+		return nil
+	}, th.CommandEqual("predictrules"))
+
+	// /closepredict — закрывает приём прогнозов (для модераторов)
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if !tg.hasRole(update.Message, models.RoleModerator) {
+			return nil
+		}
+
+		activeRace, _ := tg.resolveActiveRound(ctx, log, update.Message, "closepredict")
+		if activeRace == nil {
+			return nil
+		}
+		if !tg.hasRoundRole(ctx, log, update.Message, activeRace, models.RoleModerator, "closepredict") {
+			return nil
+		}
+
+		if err := tg.predictionService.ClosePrediction(activeRace.ID); err != nil {
+			log.Error("failed to close prediction", slog.Any("error", err))
+			return nil
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, fmt.Sprintf("Приём прогнозов на гонку '%s' закрыт.", activeRace.RaceName), "closepredict")
+		return nil
+	}, th.CommandEqual("closepredict"))
+
+	// /predictresult N1 N2 N3 — сохраняет реальные результаты гонки (для администраторов бота:
+	// результаты общие для всех раундов гонки)
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if !tg.hasRole(update.Message, models.RoleAdmin) {
+			return nil
+		}
+		if !tg.roles.HasRole(models.PlatformTelegram, int(update.Message.From.ID), 0, models.RoleAdmin) {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, "Недостаточно прав: результаты гонки вводят администраторы бота.", "predictresult")
+			return nil
+		}
+
+		targetRace, err := tg.predictionService.GetRaceAwaitingResults(models.PlatformTelegram, update.Message.Chat.ID)
+		if err != nil {
+			log.Error("failed to get race awaiting results", slog.Any("error", err))
+			return nil
+		}
+		if targetRace == nil {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, "Нет закрытых гонок без результатов.", "predictresult")
+			return nil
+		}
+
+		_, _, args := tu.ParseCommand(update.Message.Text)
+		d1, d2, d3, err := tg.predictionService.ParsePrediction(targetRace.RaceID, strings.Join(args, " "))
+		if err != nil {
+			msg := "Неверный формат. Используйте: /predictresult №_топ1 №_топ2 №_топ3"
+			if errors.Is(err, temperrors.ErrUnknownDriver) {
+				msg = predictionErrorMessage(err, tg.userLocation(update.Message))
+			}
+			tg.sendReply(ctx, log, update.Message.Chat.ID, msg, "predictresult")
+			return nil
+		}
+
+		if err := tg.predictionService.SetRaceResult(targetRace.RaceID, d1, d2, d3, nil); err != nil {
+			log.Error("failed to set race result", slog.Any("error", err))
+			return nil
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, fmt.Sprintf("Результаты гонки '%s' сохранены: 1. №%d, 2. №%d, 3. №%d", targetRace.RaceName, d1, d2, d3), "predictresult")
+		return nil
+	}, th.CommandEqual("predictresult"))
+
+	// /predictsummary — подводит итоги прогнозов (для администраторов)
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if !tg.hasRole(update.Message, models.RoleAdmin) {
+			return nil
+		}
+
+		targetRace, err := tg.predictionService.GetRaceAwaitingSummary(models.PlatformTelegram, update.Message.Chat.ID)
+		if err != nil {
+			log.Error("failed to get race awaiting summary", slog.Any("error", err))
+			return nil
+		}
+		if targetRace == nil {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, "Нет закрытых гонок с результатами.", "predictsummary")
+			return nil
+		}
+		if !tg.hasRoundRole(ctx, log, update.Message, targetRace, models.RoleAdmin, "predictsummary") {
+			return nil
+		}
+
+		results, err := tg.predictionService.CalculateResults(targetRace.ID)
+		if err != nil {
+			log.Error("failed to calculate results", slog.Any("error", err))
+			return nil
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, tg.predictionService.GetSummaryMessage(targetRace, results), "predictsummary")
+		return nil
+	}, th.CommandEqual("predictsummary"))
+}
+
+// ---------- Роли ----------
+
+const rolesUsage = `Назначение ролей (ответом на сообщение пользователя или по его id):
+/grant [id] moderator|admin [global]
+/revoke [id] [global]
+
+Без global роль действует только в этой группе.`
+
+// GetChatAdmins возвращает администраторов группы (для service.RoleService)
+func (tg *TgAPI) GetChatAdmins(chatID int64) ([]int, error) {
+	if chatID > 0 {
+		return nil, nil
+	}
+
+	members, err := tg.bot.GetChatAdministrators(context.Background(), &telego.GetChatAdministratorsParams{ChatID: tu.ID(chatID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat administrators: %w", err)
+	}
+
+	var admins []int
+	for _, member := range members {
+		if user := member.MemberUser(); !user.IsBot {
+			admins = append(admins, int(user.ID))
+		}
+	}
+	return admins, nil
+}
+
+// roleCommandArgs разбирает аргументы /grant и /revoke: пользователь (id или автор
+// сообщения, на которое ответили) и чат, в котором действует роль (0 — во всех чатах)
+func roleCommandArgs(message *telego.Message) (userID int, chatID int64, args []string) {
+	_, _, args = tu.ParseCommand(message.Text)
+
+	chatID = message.Chat.ID
+	if n := len(args); n > 0 && strings.EqualFold(args[n-1], "global") {
+		chatID, args = 0, args[:n-1]
+	}
+	if message.Chat.Type == telego.ChatTypePrivate {
+		chatID = 0
+	}
+
+	if len(args) > 0 {
+		if id, err := strconv.Atoi(args[0]); err == nil {
+			return id, chatID, args[1:]
+		}
+	}
+	if reply := message.ReplyToMessage; reply != nil && reply.From != nil && !reply.From.IsBot {
+		return int(reply.From.ID), chatID, args
+	}
+	return 0, chatID, args
+}
+
+// roleErrorMessage переводит ошибку назначения роли в сообщение для пользователя
+func roleErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, temperrors.ErrPermissionDenied):
+		return "Недостаточно прав: можно назначать и снимать только роли младше своей."
+	case errors.Is(err, temperrors.ErrRoleNotFound):
+		return "У пользователя нет назначенной роли в этой области."
+	case errors.Is(err, temperrors.ErrUnknownRole):
+		return rolesUsage
+	}
+	return "Не удалось изменить роль. Повторите попытку позже."
+}
+
+// roleHandler регистрирует команды управления ролями
+func (tg *TgAPI) roleHandler(log *slog.Logger) {
+
+	// /roles — роли бота и роль пользователя в чате
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if update.Message.From == nil {
+			return nil
+		}
+
+		messageToUser, err := tg.roles.GetRolesMessage(models.PlatformTelegram, int(update.Message.From.ID), update.Message.Chat.ID)
+		if err != nil {
+			log.Error("failed to get roles", slog.Any("error", err))
+			return nil
+		}
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, messageToUser, "roles")
+		return nil
+	}, th.CommandEqual("roles"))
+
+	// /grant [id] <роль> [global] — назначает роль (для администраторов)
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if !tg.hasRole(update.Message, models.RoleAdmin) {
+			return nil
+		}
+
+		userID, chatID, args := roleCommandArgs(update.Message)
+		if userID == 0 || len(args) != 1 {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, rolesUsage, "grant")
+			return nil
+		}
+
+		role, err := service.ParseRole(args[0])
+		if err == nil {
+			err = tg.roles.GrantRole(models.PlatformTelegram, int(update.Message.From.ID), userID, chatID, role)
+		}
+		if err != nil {
+			log.Warn("failed to grant role", slog.Int("user_id", userID), slog.Any("error", err))
+			tg.sendReply(ctx, log, update.Message.Chat.ID, roleErrorMessage(err), "grant")
+			return nil
+		}
+
+		if reply := update.Message.ReplyToMessage; reply != nil && reply.From != nil && int(reply.From.ID) == userID {
+			tg.saveUserProfile(log, reply.From)
+		}
+		log.Info("Role granted", slog.Int("user_id", userID), slog.String("role", string(role)), slog.Int64("chat_id", chatID), slog.Int64("granted_by", update.Message.From.ID))
+
+		scope := "в этой группе"
+		if chatID == 0 {
+			scope = "во всех чатах"
+		}
+		tg.sendReply(ctx, log, update.Message.Chat.ID, fmt.Sprintf("Пользователь назначен: %s %s.", role.Title(), scope), "grant")
+		return nil
+	}, th.CommandEqual("grant"))
+
+	// /revoke [id] [global] — снимает роль (для администраторов)
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if !tg.hasRole(update.Message, models.RoleAdmin) {
+			return nil
+		}
+
+		userID, chatID, args := roleCommandArgs(update.Message)
+		if userID == 0 || len(args) != 0 {
+			tg.sendReply(ctx, log, update.Message.Chat.ID, rolesUsage, "revoke")
+			return nil
+		}
+
+		role, err := tg.roles.RevokeRole(models.PlatformTelegram, int(update.Message.From.ID), userID, chatID)
+		if err != nil {
+			log.Warn("failed to revoke role", slog.Int("user_id", userID), slog.Any("error", err))
+			tg.sendReply(ctx, log, update.Message.Chat.ID, roleErrorMessage(err), "revoke")
+			return nil
+		}
+		log.Info("Role revoked", slog.Int("user_id", userID), slog.String("role", string(role)), slog.Int64("chat_id", chatID), slog.Int64("revoked_by", update.Message.From.ID))
+
+		tg.sendReply(ctx, log, update.Message.Chat.ID, fmt.Sprintf("Роль «%s» снята.", role.Title()), "revoke")
+		return nil
+	}, th.CommandEqual("revoke"))
+}
+
+// ---------- Подписки ----------
+
+const subscriptionUsage = `Темы: race, qualifying, sprint, practice, results, predictions, all
+(можно по-русски: гонка, квала, спринт, практики, результаты, прогнозы, все).
+
+/subscribe race qualifying — добавить темы
+/subscribe only race — оставить только эти темы
+/unsubscribe sprint — убрать темы
+/unsubscribe — отписаться от всего
+
+В группе подписку меняют администраторы группы.`
+
+// subscriptionErrorMessage переводит ошибку изменения подписки в сообщение для пользователя
+func subscriptionErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, temperrors.ErrPermissionDenied):
+		return "Подписку группы меняют администраторы группы. Подписаться лично можно в личных сообщениях бота."
+	case errors.Is(err, temperrors.ErrUnknownTopic):
+		return subscriptionUsage
+	}
+	return "Не удалось изменить подписку. Повторите попытку позже."
+}
+
+// subscriptionHandler регистрирует команды подписки на рассылки
+func (tg *TgAPI) subscriptionHandler(log *slog.Logger) {
+
+	// /subscribe [only] [темы] — без тем показывает подписку чата, с темами добавляет их
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if update.Message.From == nil {
+			return nil
+		}
+
+		_, _, args := tu.ParseCommand(update.Message.Text)
+		if len(args) == 0 {
+			sub, err := tg.subscriptions.Get(models.PlatformTelegram, update.Message.Chat.ID)
+			if err != nil {
+				log.Error("failed to get subscription", slog.Any("error", err))
+				return nil
+			}
+			tg.sendReply(ctx, log, update.Message.Chat.ID, service.FormatSubscription(sub)+"\n\n"+subscriptionUsage, "subscribe")
+			return nil
+		}
+
+		topics, only, err := service.ParseTopics(args)
+		var sub *models.Subscription
+		if err == nil {
+			sub, err = tg.subscriptions.Subscribe(models.PlatformTelegram, int(update.Message.From.ID), update.Message.Chat.ID, topics, only)
+		}
+		tg.replySubscription(ctx, log, update.Message, sub, err, "subscribe")
+		return nil
+	}, th.CommandEqual("subscribe"))
+
+	// /unsubscribe [темы] — убирает темы из подписки чата, без тем — отписывает от всего
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if update.Message.From == nil {
+			return nil
+		}
+
+		_, _, args := tu.ParseCommand(update.Message.Text)
+		topics, _, err := service.ParseTopics(args)
+		var sub *models.Subscription
+		if err == nil {
+			sub, err = tg.subscriptions.Unsubscribe(models.PlatformTelegram, int(update.Message.From.ID), update.Message.Chat.ID, topics)
+		}
+		tg.replySubscription(ctx, log, update.Message, sub, err, "unsubscribe")
+		return nil
+	}, th.CommandEqual("unsubscribe"))
+}
+
+// replySubscription отвечает новой подпиской чата или ошибкой её изменения
+func (tg *TgAPI) replySubscription(ctx *th.Context, log *slog.Logger, message *telego.Message, sub *models.Subscription, err error, commandName string) {
+	if err != nil {
+		log.Warn("failed to update subscription", slog.Any("error", err))
+		tg.sendReply(ctx, log, message.Chat.ID, subscriptionErrorMessage(err), commandName)
+		return
+	}
+
+	log.Info("Subscription updated", slog.Int64("chat_id", message.Chat.ID), slog.Any("topics", sub.Topics), slog.Int64("user_id", message.From.ID))
+	tg.sendReply(ctx, log, message.Chat.ID, service.FormatSubscription(sub), commandName)
+}
+
+// ---------- Часовой пояс ----------
+
+const timezoneUsage = `Часовой пояс расписания:
+/timezone Екатеринбург — задать свой пояс (город, Asia/Yekaterinburg, UTC+5 или МСК+2)
+/timezone chat Владивосток — задать пояс группы (администраторы группы)
+/timezone reset, /timezone chat reset — вернуть пояс по умолчанию
+
+Без личного пояса время показывается в поясе группы, а без него — по Москве.`
+
+// userLocation возвращает часовой пояс автора сообщения в текущем чате
+func (tg *TgAPI) userLocation(message *telego.Message) *time.Location {
+	if message.From == nil {
+		return tg.timezones.ChatLocation(models.PlatformTelegram, message.Chat.ID)
+	}
+	return tg.timezones.Location(models.PlatformTelegram, int(message.From.ID), message.Chat.ID)
+}
+
+// timezoneErrorMessage переводит ошибку смены часового пояса в сообщение для пользователя
+func timezoneErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, temperrors.ErrPermissionDenied):
+		return "Часовой пояс группы меняют администраторы группы. Свой пояс можно задать командой /timezone город."
+	case errors.Is(err, temperrors.ErrUnknownTimezone):
+		return "Не знаю такого часового пояса.\n\n" + timezoneUsage
+	}
+	return "Не удалось изменить часовой пояс. Повторите попытку позже."
+}
+
+// timezoneHandler регистрирует команду часового пояса
+func (tg *TgAPI) timezoneHandler(log *slog.Logger) {
+
+	// /timezone [chat] [город | reset] — без аргументов показывает текущие пояса
+	tg.handler.Handle(func(ctx *th.Context, update telego.Update) error {
+
+		log.Info(
+			"MESSAGE info",
+			slog.Int64("peer_id", update.Message.Chat.ID),
+			slog.String("text", update.Message.Text))
+
+		if update.Message.From == nil {
+			return nil
+		}
+		userID, chatID := int(update.Message.From.ID), update.Message.Chat.ID
+
+		_, _, args := tu.ParseCommand(update.Message.Text)
+		if len(args) == 0 {
+			tg.sendReply(ctx, log, chatID, tg.timezones.GetTimezoneMessage(models.PlatformTelegram, userID, chatID)+"\n\n"+timezoneUsage, "timezone")
+			return nil
+		}
+
+		forChat := strings.EqualFold(args[0], "chat")
+		if forChat {
+			args = args[1:]
+		}
+		name := strings.Join(args, " ")
+		reset := strings.EqualFold(name, "reset") || strings.EqualFold(name, "сброс")
+
+		var loc *time.Location
+		var err error
+		switch {
+		case name == "":
+			err = fmt.Errorf("empty timezone: %w", temperrors.ErrUnknownTimezone)
+		case reset && forChat:
+			err = tg.timezones.ResetChatTimezone(models.PlatformTelegram, userID, chatID)
+		case reset:
+			err = tg.timezones.ResetUserTimezone(models.PlatformTelegram, userID)
+		case forChat:
+			loc, err = tg.timezones.SetChatTimezone(models.PlatformTelegram, userID, chatID, name)
+		default:
+			loc, err = tg.timezones.SetUserTimezone(models.PlatformTelegram, userID, name)
+		}
+		if err != nil {
+			log.Warn("failed to change timezone", slog.String("timezone", name), slog.Any("error", err))
+			tg.sendReply(ctx, log, chatID, timezoneErrorMessage(err), "timezone")
+			return nil
+		}
+
+		msg := "Часовой пояс сброшен. " + tg.timezones.GetTimezoneMessage(models.PlatformTelegram, userID, chatID)
+		if loc != nil {
+			log.Info("Timezone changed", slog.String("timezone", loc.String()), slog.Bool("chat", forChat), slog.Int("user_id", userID))
+			msg = fmt.Sprintf("Часовой пояс установлен: %s.", service.FormatTimezone(loc))
+		}
+		tg.sendReply(ctx, log, chatID, msg, "timezone")
+		return nil
+	}, th.CommandEqual("timezone"))
+}
+
+// ---------- Мастер прогноза ----------
+
+// Префикс callback_data кнопок мастера прогноза
+const predictWizardPrefix = "pw:"
+
+// Гонщиков в ряду клавиатуры мастера
+const predictWizardCols = 4
+
+// predictWizardState — состояние мастера прогноза. Хранится целиком в callback_data
+// кнопок (до 64 байт), поэтому бот не держит черновики в памяти: каждая кнопка
+// несёт состояние, в которое переводит мастер.
+type predictWizardState struct {
+	owner   int64 // пользователь, начавший ввод прогноза
+	roundID int   // раунд, на который делается прогноз (prediction_races.id)
+	cancel  bool  // кнопка "Отмена"
+	picks   []uint8
+}
+
+// data кодирует состояние: "pw:<владелец>:<раунд>:<s|c>:<номер>.<номер>"
+func (st predictWizardState) data() string {
+	action := "s"
+	if st.cancel {
+		action = "c"
+	}
+	picks := make([]string, 0, len(st.picks))
+	for _, num := range st.picks {
+		picks = append(picks, strconv.Itoa(int(num)))
+	}
+	return fmt.Sprintf("%s%d:%d:%s:%s", predictWizardPrefix, st.owner, st.roundID, action, strings.Join(picks, "."))
+}
+
+// withPicks возвращает состояние с другим набором выбранных гонщиков
+func (st predictWizardState) withPicks(picks ...uint8) predictWizardState {
+	st.picks = picks
+	st.cancel = false
+	return st
+}
+
+func parsePredictWizardData(data string) (predictWizardState, error) {
+	var st predictWizardState
+	parts := strings.Split(strings.TrimPrefix(data, predictWizardPrefix), ":")
+	if len(parts) != 4 {
+		return st, fmt.Errorf("invalid wizard data %q: %w", data, temperrors.ErrParse)
+	}
+
+	owner, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return st, fmt.Errorf("invalid wizard owner in %q: %w", data, temperrors.ErrParse)
+	}
+	st.owner = owner
+	roundID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return st, fmt.Errorf("invalid wizard round in %q: %w", data, temperrors.ErrParse)
+	}
+	st.roundID = roundID
+	st.cancel = parts[2] == "c"
+
+	if parts[3] != "" {
+		for _, p := range strings.Split(parts[3], ".") {
+			num, err := strconv.ParseUint(p, 10, 8)
+			if err != nil {
+				return st, fmt.Errorf("invalid wizard pick in %q: %w", data, temperrors.ErrParse)
+			}
+			st.picks = append(st.picks, uint8(num))
+		}
+	}
+	if len(st.picks) > 3 {
+		return st, fmt.Errorf("too many wizard picks in %q: %w", data, temperrors.ErrParse)
+	}
+	return st, nil
+}
+
+// predictWizardKeyboard — кнопки выбора гонщика на следующую позицию (выбранные скрыты)
+func predictWizardKeyboard(st predictWizardState, grid []service.GridDriver) *telego.InlineKeyboardMarkup {
+	buttons := make([]telego.InlineKeyboardButton, 0, len(grid))
+	for _, g := range grid {
+		if slices.Contains(st.picks, g.Number) {
+			continue
+		}
+		next := st.withPicks(append(slices.Clone(st.picks), g.Number)...)
+		buttons = append(buttons, tu.InlineKeyboardButton(fmt.Sprintf("%d %s", g.Number, g.Label())).WithCallbackData(next.data()))
+	}
+
+	rows := tu.InlineKeyboardCols(predictWizardCols, buttons...)
+	var nav []telego.InlineKeyboardButton
+	if len(st.picks) > 0 {
+		back := st.withPicks(st.picks[:len(st.picks)-1]...)
+		nav = append(nav, tu.InlineKeyboardButton("Назад").WithCallbackData(back.data()))
+	}
+	cancel := st.withPicks()
+	cancel.cancel = true
+	nav = append(nav, tu.InlineKeyboardButton("Отмена").WithCallbackData(cancel.data()))
+	rows = append(rows, nav)
+	return tu.InlineKeyboardGrid(rows)
+}
+
+// predictWizardEditKeyboard — кнопка "Изменить" под принятым прогнозом
+func predictWizardEditKeyboard(st predictWizardState) *telego.InlineKeyboardMarkup {
+	return tu.InlineKeyboard(tu.InlineKeyboardRow(tu.InlineKeyboardButton("Изменить").WithCallbackData(st.withPicks().data())))
+}
+
+// predictWizardText — текст шага: уже выбранные гонщики и позиция для выбора
+func predictWizardText(raceName string, grid []service.GridDriver, picks []uint8) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Прогноз на %s\n", raceName))
+	for i, num := range picks {
+		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, service.GridDriverName(grid, num)))
+	}
+	sb.WriteString(fmt.Sprintf("\nВыберите гонщика на %d место:", len(picks)+1))
+	return sb.String()
+}
+
+// startPredictWizard отправляет сообщение с клавиатурой выбора победителя
+func (tg *TgAPI) startPredictWizard(ctx *th.Context, log *slog.Logger, message *telego.Message, race *models.PredictionRace) {
+	grid, err := tg.predictionService.GetRaceGrid(race.RaceID)
+	if err != nil || len(grid) == 0 {
+		log.Error("failed to get race grid", slog.String("race_id", race.RaceID), slog.Any("error", err))
+		tg.sendReply(ctx, log, message.Chat.ID, "Не удалось загрузить список гонщиков. Отправьте прогноз текстом:\n\n/predict №_топ1 №_топ2 №_топ3", "predict")
+		return
+	}
+
+	st := predictWizardState{owner: message.From.ID, roundID: race.ID}
+	_, err = ctx.Bot().SendMessage(ctx.Context(), tu.Message(
+		tu.ID(message.Chat.ID),
+		predictWizardText(race.RaceName, grid, nil),
+	).WithReplyMarkup(predictWizardKeyboard(st, grid)))
+	if err != nil {
+		log.Error("failed to send message",
+			slog.String("command", "predict"),
+			slog.Int64("chat_id", message.Chat.ID),
+			slog.Any("error", err))
+	}
+}
+
+// handlePredictWizard обрабатывает нажатие кнопки мастера и редактирует сообщение на месте
+func (tg *TgAPI) handlePredictWizard(ctx *th.Context, log *slog.Logger, query *telego.CallbackQuery) {
+	if query.Message == nil || !query.Message.IsAccessible() {
+		tg.answerCallback(ctx, log, query, "Сообщение устарело. Отправьте /predict, чтобы начать заново.")
+		return
+	}
+	chatID := query.Message.GetChat().ID
+	messageID := query.Message.GetMessageID()
+
+	st, err := parsePredictWizardData(query.Data)
+	if err != nil {
+		log.Error("failed to parse wizard data", slog.Any("error", err))
+		tg.answerCallback(ctx, log, query, "")
+		return
+	}
+	if st.owner != query.From.ID {
+		tg.answerCallback(ctx, log, query, "Это чужой прогноз. Отправьте /predict, чтобы сделать свой.")
+		return
+	}
+	if st.cancel {
+		tg.answerCallback(ctx, log, query, "")
+		tg.editWizardMessage(ctx, log, chatID, messageID, "Ввод прогноза отменён.", nil)
+		return
+	}
+
+	activeRace, err := tg.predictionService.GetRound(st.roundID)
+	if err != nil {
+		log.Error("failed to get prediction round", slog.Int("round_id", st.roundID), slog.Any("error", err))
+		tg.answerCallback(ctx, log, query, "Не удалось получить раунд прогнозов, повторите попытку позже.")
+		return
+	}
+	if activeRace == nil || !activeRace.IsActive {
+		tg.answerCallback(ctx, log, query, "")
+		tg.editWizardMessage(ctx, log, chatID, messageID, "Приём прогнозов на эту гонку закрыт. Отправьте /predict, чтобы сделать прогноз на текущий раунд.", nil)
+		return
+	}
+
+	grid, err := tg.predictionService.GetRaceGrid(activeRace.RaceID)
+	if err != nil {
+		log.Error("failed to get race grid", slog.String("race_id", activeRace.RaceID), slog.Any("error", err))
+		tg.answerCallback(ctx, log, query, "Не удалось загрузить список гонщиков, повторите попытку позже.")
+		return
+	}
+
+	if len(st.picks) < 3 {
+		tg.answerCallback(ctx, log, query, "")
+		tg.editWizardMessage(ctx, log, chatID, messageID, predictWizardText(activeRace.RaceName, grid, st.picks), predictWizardKeyboard(st, grid))
+		return
+	}
+
+	// Выбраны все три позиции — отправляем прогноз
+	userID := int(query.From.ID)
+	d1, d2, d3 := st.picks[0], st.picks[1], st.picks[2]
+	err = tg.predictionService.SubmitPrediction(models.PlatformTelegram, userID, chatID, st.roundID, d1, d2, d3)
+	if err != nil {
+		log.Error("failed to save prediction", slog.Any("error", err))
+		tg.answerCallback(ctx, log, query, "")
+		tg.editWizardMessage(ctx, log, chatID, messageID, predictionErrorMessage(err, tg.timezones.Location(models.PlatformTelegram, userID, chatID)), predictWizardEditKeyboard(st))
+		return
+	}
+
+	tg.saveUserProfile(log, &query.From)
+	log.Info("Prediction recorded", slog.Int("user_id", userID), slog.Int("d1", int(d1)), slog.Int("d2", int(d2)), slog.Int("d3", int(d3)), slog.String("source", "keyboard"))
+
+	tg.answerCallback(ctx, log, query, "Прогноз принят!")
+	msg := fmt.Sprintf("Ваш прогноз на %s принят:\n1. %s\n2. %s\n3. %s", activeRace.RaceName,
+		service.GridDriverName(grid, d1), service.GridDriverName(grid, d2), service.GridDriverName(grid, d3))
+	tg.editWizardMessage(ctx, log, chatID, messageID, msg, predictWizardEditKeyboard(st))
+}
+
+// answerCallback отвечает на нажатие кнопки; непустой текст показывается всплывающим уведомлением
+func (tg *TgAPI) answerCallback(ctx *th.Context, log *slog.Logger, query *telego.CallbackQuery, text string) {
+	params := tu.CallbackQuery(query.ID)
+	if text != "" {
+		params = params.WithText(text)
+	}
+	if err := ctx.Bot().AnswerCallbackQuery(ctx.Context(), params); err != nil {
+		log.Error("failed to answer callback query", slog.Int64("user_id", query.From.ID), slog.Any("error", err))
+	}
+}
+
+// editWizardMessage заменяет текст и кнопки сообщения мастера (nil — убрать кнопки)
+func (tg *TgAPI) editWizardMessage(ctx *th.Context, log *slog.Logger, chatID int64, messageID int, text string, kb *telego.InlineKeyboardMarkup) {
+	params := tu.EditMessageText(tu.ID(chatID), messageID, text)
+	if kb != nil {
+		params = params.WithReplyMarkup(kb)
+	}
+	if _, err := ctx.Bot().EditMessageText(ctx.Context(), params); err != nil {
+		log.Error("failed to edit message", slog.Int64("chat_id", chatID), slog.Int("message_id", messageID), slog.Any("error", err))
+	}
+}
+
+// resolveActiveRound выбирает открытый раунд чата по номеру этапа из аргументов команды
+// ("/predict #6 ..."), возвращает раунд и оставшиеся аргументы. Если раунд не выбран
+// однозначно или открытых раундов нет, отвечает пояснением и возвращает nil.
+func (tg *TgAPI) resolveActiveRound(ctx *th.Context, log *slog.Logger, message *telego.Message, command string) (*models.PredictionRace, string) {
+	_, _, args := tu.ParseCommand(message.Text)
+	selector, rest := service.SplitRoundSelector(strings.Join(args, " "))
+
+	race, err := tg.predictionService.ResolveActiveRound(models.PlatformTelegram, message.Chat.ID, selector)
+	if err != nil {
+		log.Warn("failed to resolve prediction round", slog.String("selector", selector), slog.Any("error", err))
+		tg.sendReply(ctx, log, message.Chat.ID, predictionErrorMessage(err, tg.userLocation(message)), command)
+		return nil, rest
+	}
+	if race == nil {
+		tg.sendReply(ctx, log, message.Chat.ID, "Сейчас нет активного конкурса прогнозов.", command)
+	}
+	return race, rest
+}
+
+// resolveLatestRound выбирает раунд чата для просмотра: по номеру этапа из аргументов,
+// иначе открытый раунд, иначе последний проведённый
+func (tg *TgAPI) resolveLatestRound(ctx *th.Context, log *slog.Logger, message *telego.Message, command string) (*models.PredictionRace, string) {
+	_, _, args := tu.ParseCommand(message.Text)
+	selector, rest := service.SplitRoundSelector(strings.Join(args, " "))
+
+	race, err := tg.predictionService.ResolveLatestRound(models.PlatformTelegram, message.Chat.ID, selector)
+	if err != nil {
+		log.Warn("failed to resolve prediction round", slog.String("selector", selector), slog.Any("error", err))
+		tg.sendReply(ctx, log, message.Chat.ID, predictionErrorMessage(err, tg.userLocation(message)), command)
+		return nil, rest
+	}
+	if race == nil {
+		tg.sendReply(ctx, log, message.Chat.ID, "Конкурсов прогнозов ещё не было.", command)
+	}
+	return race, rest
+}
+
+// predictionErrorMessage переводит ошибку сохранения прогноза в сообщение для пользователя,
+// дедлайны раундов указываются в часовом поясе loc
+func predictionErrorMessage(err error, loc *time.Location) string {
+	switch {
+	case errors.Is(err, temperrors.ErrPredictionClosed):
+		return "Приём прогнозов на эту гонку уже закрыт."
+	case errors.Is(err, temperrors.ErrPredictionNotFound):
+		return "Сначала отправьте прогноз на подиум гонки, затем ответьте на дополнительные вопросы."
+	case errors.Is(err, temperrors.ErrNotSprintWeekend):
+		return "На этом этапе нет спринта — прогноз на подиум спринта не принимается."
+	case errors.Is(err, temperrors.ErrQuestionClosed):
+		var closed *service.QuestionClosedError
+		if errors.As(err, &closed) {
+			return fmt.Sprintf("Ответы на вопрос «%s» принимались до старта %s — %s.", closed.Question, closed.Session, service.FormatZonedTime(closed.Deadline, loc))
+		}
+		return "Приём ответов на этот вопрос уже закрыт."
+	case errors.Is(err, temperrors.ErrUnknownDriver):
+		var unknown *service.UnknownDriverError
+		if errors.As(err, &unknown) {
+			return fmt.Sprintf("Гонщик «%s» не найден в заявке сезона. Укажите номер машины, код (VER) или фамилию (Ферстаппен).", unknown.Token)
+		}
+		return "Не удалось распознать гонщиков. Укажите номер машины, код (VER) или фамилию (Ферстаппен)."
+	case errors.Is(err, temperrors.ErrDuplicateDriver):
+		var duplicate *service.DuplicateDriverError
+		if errors.As(err, &duplicate) {
+			return fmt.Sprintf("Гонщик №%d указан несколько раз. Выберите трёх разных гонщиков.", duplicate.Number)
+		}
+		return "Гонщики в прогнозе не должны повторяться."
+	case errors.Is(err, temperrors.ErrAmbiguousRound):
+		var ambiguous *service.AmbiguousRoundError
+		if errors.As(err, &ambiguous) {
+			return "Сейчас открыто несколько конкурсов прогнозов. Укажите номер этапа после команды, например «/predict #" + ambiguous.Rounds[0].Round() + " VER NOR LEC»:\n\n" + service.FormatRoundChoice(ambiguous.Rounds, loc)
+		}
+		return "Сейчас открыто несколько конкурсов прогнозов. Укажите номер этапа после команды, например «#6»."
+	case errors.Is(err, temperrors.ErrRoundNotFound):
+		return "В этом чате нет конкурса прогнозов на указанный этап."
+	case errors.Is(err, temperrors.ErrRoundAlreadyOpen):
+		return "Конкурс прогнозов на эту гонку уже открыт."
+	case errors.Is(err, temperrors.ErrDriverNotOnGrid):
+		var notOnGrid *service.DriverNotOnGridError
+		if errors.As(err, &notOnGrid) {
+			return fmt.Sprintf("Под номером %d никто не выступает в сезоне %d. Проверьте номер по списку гонщиков в объявлении конкурса.", notOnGrid.Number, notOnGrid.Season)
+		}
+		return "Указанного гонщика нет в заявке сезона."
+	}
+	return "Не удалось сохранить прогноз. Повторите попытку позже."
 }
 
 func getDateFromMessage(userTimestamp int64) time.Time {
@@ -7,4 +7,23 @@ import (
 var (
 	ErrEmptyList = errors.New("empty list")
 	ErrParse     = errors.New("parse error")
+
+	ErrPredictionClosed   = errors.New("prediction closed")
+	ErrUnknownScoringRule = errors.New("unknown scoring rule")
+	ErrPredictionNotFound = errors.New("prediction not found")
+	ErrNotSprintWeekend   = errors.New("not a sprint weekend")
+	ErrQuestionClosed     = errors.New("prediction question closed")
+	ErrUnknownDriver      = errors.New("unknown driver")
+	ErrDuplicateDriver    = errors.New("duplicate driver")
+	ErrDriverNotOnGrid    = errors.New("driver not on grid")
+	ErrAmbiguousRound     = errors.New("ambiguous prediction round")
+	ErrRoundNotFound      = errors.New("prediction round not found")
+	ErrRoundAlreadyOpen   = errors.New("prediction round already open")
+	ErrUnknownRole        = errors.New("unknown role")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrUnknownTopic       = errors.New("unknown subscription topic")
+	ErrUnknownTimezone    = errors.New("unknown timezone")
+	ErrSeasonOutOfRange   = errors.New("season out of range")
+	ErrUnknownConstructor = errors.New("unknown constructor")
 )
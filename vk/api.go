@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"racebot-vk/config"
 	"racebot-vk/models"
 	"racebot-vk/service"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,44 +19,39 @@ import (
 	"github.com/SevereCloud/vksdk/v3/longpoll-bot"
 )
 
-const (
-	f1memesChatId = 2000000003
-	//testChatId      = 2000000005
-	//alphaTestChatId = 2000000006
-	//f1memesStreamer = 152819213
-	botAdminId = 147506714
-	f1memesId  = -211183989
-	//testGroupId     = -210295709
-)
-
-var lastStreamId = 0
-
-// Состояние отслеживания новых видео (защита от повторного запуска/остановки)
-var (
-	streamCheckMu  sync.Mutex
-	streamChecking bool
-	streamQuit     chan bool
-	streamTicker   *time.Ticker
-)
+// streamState — отслеживание трансляций одного сообщества (защита от повторного запуска/остановки)
+type streamState struct {
+	community    config.Community
+	mu           sync.Mutex
+	checking     bool
+	quit         chan bool
+	ticker       *time.Ticker
+	lastStreamID int
+}
 
 type messageService interface {
-	GetDriversListMessage(userDate time.Time) (string, error)
-	GetDriverStandingsMessage(userDate time.Time) (string, error)
-	GetCalendarMessage(year int) (string, error)
-	GetNextRaceMessage(userDate time.Time, userTimestamp int) (string, error)
-	GetConstructorStandingsMessage(uerDate time.Time) (string, error)
-	GetRaceResultsMessage(userDate time.Time, raceId string) (string, error)
-	GetGPInfoCarousel(userDate time.Time, raceId string) (string, error)
+	GetDriversListMessage(season int) (string, error)
+	GetDriverStandingsMessage(season int, round string) (string, error)
+	GetStandingsProgressionMessage(season int) (string, error)
+	GetCalendarMessage(year int, loc *time.Location) (string, error)
+	GetNextRaceMessage(userDate time.Time, userTimestamp int, loc *time.Location) (string, error)
+	GetCountdownMessage(now time.Time, loc *time.Location) (string, error)
+	GetConstructorStandingsMessage(season int, round string) (string, error)
+	GetRaceResultsMessage(season int, raceId string) (string, error)
+	GetGPInfoCarousel(season int, raceId string, loc *time.Location) (string, error)
 	GetGPKeyboard() string
 	GetCountDaysAfterRaceMessage(userDate time.Time, raceId string) (string, error)
-	GetQualifyingResultsMessage(userDate time.Time, raceId string) (string, error)
-	GetSprintResultsMessage(userDate time.Time, raceId string) string
-	GetCountOfRaces(userDate time.Time) (int, error)
+	GetQualifyingResultsMessage(season int, raceId string) (string, error)
+	GetSprintResultsMessage(season int, raceId string) string
+	GetCountOfRaces(season int) (int, error)
 	GetNextRace(userDate time.Time, userTimestamp int) (models.Race, error)
+	GetDriverProfileMessage(query string, now time.Time) (string, error)
+	GetConstructorProfileMessage(query string, now time.Time) (string, error)
+	GetHeadToHeadMessage(first, second string, season int) (string, error)
 }
 
 type eventService interface {
-	GetGPInfoCarousel(userDate time.Time, raceId string) (string, error)
+	GetGPInfoCarousel(season int, raceId string, loc *time.Location) (string, error)
 }
 
 type VkAPI struct {
@@ -63,9 +60,16 @@ type VkAPI struct {
 	messageService    messageService
 	eventService      eventService
 	predictionService *service.PredictionService
+	subscriptions     *service.SubscriptionService
+	timezones         *service.TimezoneService
+	adminID           int
+	streams           []*streamState
+	media             config.VkMedia
+	roles             *service.RoleService
+	wizard            *predictionWizard
 }
 
-func NewVKAPI(groupToken, userToken string, messageService messageService, eventService eventService, predictionService *service.PredictionService) (*VkAPI, error) {
+func NewVKAPI(groupToken, userToken string, adminID int, communities []config.Community, media config.VkMedia, messageService messageService, eventService eventService, predictionService *service.PredictionService, roles *service.RoleService, subscriptions *service.SubscriptionService, timezones *service.TimezoneService) (*VkAPI, error) {
 	vk := api.NewVK(groupToken)
 
 	lp, err := longpoll.NewLongPollCommunity(vk)
@@ -73,12 +77,24 @@ func NewVKAPI(groupToken, userToken string, messageService messageService, event
 		return nil, fmt.Errorf("error creating new log pool: %w", err)
 	}
 
+	streams := make([]*streamState, 0, len(communities))
+	for _, community := range communities {
+		streams = append(streams, &streamState{community: community})
+	}
+
 	return &VkAPI{
 		usrVk:             api.NewVK(userToken),
 		lp:                lp,
 		messageService:    messageService,
 		eventService:      eventService,
 		predictionService: predictionService,
+		subscriptions:     subscriptions,
+		timezones:         timezones,
+		adminID:           adminID,
+		streams:           streams,
+		media:             media,
+		roles:             roles,
+		wizard:            newPredictionWizard(),
 	}, nil
 }
 
@@ -120,6 +136,46 @@ func (vk *VkAPI) sendAndLog(log *slog.Logger, message string, peerID int, keyboa
 	return resp, nil
 }
 
+// SendToChat отправляет сообщение в один чат (для раундов прогнозов отдельного чата)
+func (vk *VkAPI) SendToChat(log *slog.Logger, chatID int64, message string) error {
+	_, err := vk.sendAndLog(log, message, int(chatID), nil, nil, nil, "broadcast")
+	return err
+}
+
+// Platform возвращает платформу, в которую рассылает сообщения VkAPI
+func (vk *VkAPI) Platform() string {
+	return models.PlatformVK
+}
+
+// GetUserProfiles получает имена пользователей VK через users.get
+func (vk *VkAPI) GetUserProfiles(userIDs []int) ([]models.UserProfile, error) {
+	ids := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		ids = append(ids, strconv.Itoa(id))
+	}
+
+	b := params.NewUsersGetBuilder()
+	b.UserIDs(ids)
+	b.Fields([]string{"screen_name"})
+
+	users, err := vk.lp.VK.UsersGet(b.Params)
+	if err != nil {
+		return nil, fmt.Errorf("error in users.get: %w", err)
+	}
+
+	profiles := make([]models.UserProfile, 0, len(users))
+	for _, u := range users {
+		profiles = append(profiles, models.UserProfile{
+			Platform:  models.PlatformVK,
+			UserID:    u.ID,
+			FirstName: u.FirstName,
+			LastName:  u.LastName,
+			Username:  u.ScreenName,
+		})
+	}
+	return profiles, nil
+}
+
 func (vk *VkAPI) messageHandler(log *slog.Logger) {
 	var myUsrVk MyVk = MyVk{vk.usrVk}
 
@@ -150,14 +206,15 @@ func (vk *VkAPI) messageHandler(log *slog.Logger) {
 		}
 
 		if textPayload != nil {
-			cmd := getCommand(*textPayload)
+			cmd, _ := getCommand(*textPayload)
 			ctx.raceID = (strings.Split(*textPayload, "_"))[1]
 
 			if handler, ok := payloadHandlers[cmd]; ok {
 				handler(ctx)
 			}
 		} else {
-			cmd := getCommand(messageText)
+			cmd, args := getCommand(messageText)
+			ctx.args = args
 
 			if handler, ok := messageHandlers[cmd]; ok {
 				handler(ctx)
@@ -236,6 +293,42 @@ func sendEventMessageToUser(vk *api.VK, peerID int, eventID string, userID int)
 	return resp, nil
 }
 
+// sendEventSnackbar отвечает на нажатие callback-кнопки всплывающим уведомлением
+func sendEventSnackbar(vk *api.VK, peerID int, eventID string, userID int, text string) (int, error) {
+	eventData, err := json.Marshal(map[string]string{"type": "show_snackbar", "text": text})
+	if err != nil {
+		return 0, fmt.Errorf("error marshal event data: %w", err)
+	}
+
+	prms := params.NewMessagesSendMessageEventAnswerBuilder()
+	prms.PeerID(peerID)
+	prms.EventID(eventID)
+	prms.UserID(userID)
+	prms.EventData(string(eventData))
+
+	resp, err := vk.MessagesSendMessageEventAnswer(prms.Params)
+	if err != nil {
+		return resp, fmt.Errorf("error sending event answer: %w", err)
+	}
+	return resp, nil
+}
+
+// editMessage заменяет текст и клавиатуру сообщения бота
+func editMessage(vk *api.VK, peerID, conversationMessageID int, message string, keyboard *string) error {
+	prms := params.NewMessagesEditBuilder()
+	prms.PeerID(peerID)
+	prms.ConversationMessageID(conversationMessageID)
+	prms.Message(message)
+	if keyboard != nil {
+		prms.Keyboard(*keyboard)
+	}
+
+	if _, err := vk.MessagesEdit(prms.Params); err != nil {
+		return fmt.Errorf("error editing message: %w", err)
+	}
+	return nil
+}
+
 func deleteMessages(vk *api.VK, messageIds []int, peerID int, deleteForAllFlag bool) error {
 	prms := params.NewMessagesDeleteBuilder()
 	prms.PeerID(peerID)
@@ -308,19 +401,19 @@ func makeKeyboard(row, col, numPage, countEl int, inline bool) (Kb, error) {
 	return Kb{Inline: inline, Buttons: buttons}, nil
 }
 
-func getLastVideos(vk MyVk, count int) ([]MyVideo, error) {
+func getLastVideos(vk MyVk, groupID int64, count int) ([]MyVideo, error) {
 	prms := params.NewVideoGetBuilder()
-	prms.OwnerID(f1memesId)
+	prms.OwnerID(int(groupID))
 	prms.Count(count)
 
 	slog.Debug("video.get request",
-		slog.String("owner_id", fmt.Sprintf("%d", f1memesId)),
+		slog.Int64("owner_id", groupID),
 		slog.Int("count", count),
 	)
 
 	resp, err := vk.VideoGet(prms.Params)
 	if err != nil {
-		slog.Error("video.get failed", slog.String("owner_id", fmt.Sprintf("%d", f1memesId)), slog.Any("error", err))
+		slog.Error("video.get failed", slog.Int64("owner_id", groupID), slog.Any("error", err))
 		return nil, fmt.Errorf("error in video.get: %w", err)
 	}
 
@@ -332,13 +425,33 @@ func getLastVideos(vk MyVk, count int) ([]MyVideo, error) {
 	return resp.Items, nil
 }
 
-// checkStreamOnce выполняет одну проверку новых видео и возвращает false,
+// streamAdmin возвращает, кому сообщать об ошибках отслеживания трансляций сообщества
+func (vk *VkAPI) streamAdmin(st *streamState) int {
+	if st.community.AdminID != 0 {
+		return int(st.community.AdminID)
+	}
+	return vk.adminID
+}
+
+// streamsForPeer возвращает сообщества, трансляции которых отслеживаются командой из чата:
+// в беседе сообщества — только это сообщество, в остальных чатах — все сообщества
+func (vk *VkAPI) streamsForPeer(peerID int) []*streamState {
+	for _, st := range vk.streams {
+		if st.community.ChatID == int64(peerID) {
+			return []*streamState{st}
+		}
+	}
+	return vk.streams
+}
+
+// checkStreamOnce выполняет одну проверку новых видео сообщества и возвращает false,
 // если отслеживание нужно прекратить (произошла ошибка получения видео).
-func checkStreamOnce(log *slog.Logger, vk *VkAPI, myUsrVk *MyVk, obj events.MessageNewObject) bool {
-	lastVideo, err := getLastVideos(*myUsrVk, 2)
+func checkStreamOnce(log *slog.Logger, vk *VkAPI, st *streamState, myUsrVk *MyVk, obj events.MessageNewObject) bool {
+	lastVideo, err := getLastVideos(*myUsrVk, st.community.GroupID, 2)
 	if err != nil {
 		log.Error(err.Error())
-		_, err := sendMessageToUser("Ошибка получения новых видео. Перезапустите отслеживание.", botAdminId, vk.lp.VK, nil, nil, nil)
+		msg := fmt.Sprintf("Ошибка получения новых видео «%s». Перезапустите отслеживание.", st.community.Name)
+		_, err := sendMessageToUser(msg, vk.streamAdmin(st), vk.lp.VK, nil, nil, nil)
 		if err != nil {
 			log.Error("Error with sending message-answer to command `checkStream` to user", slog.Int("peer_id", obj.Message.PeerID), slog.Any("error", err))
 		}
@@ -346,7 +459,8 @@ func checkStreamOnce(log *slog.Logger, vk *VkAPI, myUsrVk *MyVk, obj events.Mess
 	}
 	if len(lastVideo) == 0 {
 		slog.Error("video.get returned empty list")
-		_, err := sendMessageToUser("Получен пустой список видео. Перезапустите отслеживание.", botAdminId, vk.lp.VK, nil, nil, nil)
+		msg := fmt.Sprintf("Получен пустой список видео «%s». Перезапустите отслеживание.", st.community.Name)
+		_, err := sendMessageToUser(msg, vk.streamAdmin(st), vk.lp.VK, nil, nil, nil)
 		if err != nil {
 			slog.Error("Error with sending message-answer to command `checkStream` to user", slog.Int("peer_id", obj.Message.PeerID), slog.Any("error", err))
 		}
@@ -354,13 +468,13 @@ func checkStreamOnce(log *slog.Logger, vk *VkAPI, myUsrVk *MyVk, obj events.Mess
 	}
 	log.Info("Video id", slog.Int("ID", lastVideo[0].ID))
 
-	if lastVideo[0].ID != lastStreamId {
+	if lastVideo[0].ID != st.lastStreamID {
 		if lastVideo[0].Live && lastVideo[0].LiveStatus == "started" {
-			lastStreamId = lastVideo[0].ID
-			streamLink := fmt.Sprintf("video%d_%d", f1memesId, lastStreamId)
+			st.lastStreamID = lastVideo[0].ID
+			streamLink := fmt.Sprintf("video%d_%d", st.community.GroupID, st.lastStreamID)
 
-			messageToUser := fmt.Sprintf("'F1 Memes TV' начали трансляцию '%s'!\n", lastVideo[0].Title)
-			resp, err := sendMessageToUser(messageToUser, f1memesChatId, vk.lp.VK, nil, nil, &streamLink)
+			messageToUser := fmt.Sprintf("'%s' начали трансляцию '%s'!\n", st.community.Name, lastVideo[0].Title)
+			resp, err := sendMessageToUser(messageToUser, int(st.community.ChatID), vk.lp.VK, nil, nil, &streamLink)
 			if err != nil {
 				log.Error("Error with sending message-answer to command `checkStream` to user", slog.Int("peer_id", obj.Message.PeerID), slog.Any("error", err))
 			}
@@ -372,15 +486,15 @@ func checkStreamOnce(log *slog.Logger, vk *VkAPI, myUsrVk *MyVk, obj events.Mess
 	return true
 }
 
-func checkLastStream(quit <-chan bool, ticker *time.Ticker, log *slog.Logger, vk *VkAPI, myUsrVk *MyVk, obj events.MessageNewObject) {
+func checkLastStream(quit <-chan bool, ticker *time.Ticker, log *slog.Logger, vk *VkAPI, st *streamState, myUsrVk *MyVk, obj events.MessageNewObject) {
 	defer func() {
 		ticker.Stop()
-		streamCheckMu.Lock()
-		streamChecking = false
-		streamQuit = nil
-		streamTicker = nil
-		streamCheckMu.Unlock()
-		log.Info("End video check")
+		st.mu.Lock()
+		st.checking = false
+		st.quit = nil
+		st.ticker = nil
+		st.mu.Unlock()
+		log.Info("End video check", slog.String("community", st.community.Name))
 	}()
 
 	for {
@@ -389,7 +503,7 @@ func checkLastStream(quit <-chan bool, ticker *time.Ticker, log *slog.Logger, vk
 			return
 		case t := <-ticker.C:
 			log.Info("Video check", slog.String("time", t.UTC().String()))
-			if !checkStreamOnce(log, vk, myUsrVk, obj) {
+			if !checkStreamOnce(log, vk, st, myUsrVk, obj) {
 				return
 			}
 		}
@@ -3,11 +3,16 @@ package vk
 import "regexp"
 
 const (
-	commandGpInfo  eventCommand = `gpPage_\d{1,2}`
-	commandGpList1 eventCommand = `gpListPage_1`
-	commandGpList2 eventCommand = `gpListPage_2`
-	commandGpList3 eventCommand = `gpListPage_3`
-	commandNothing eventCommand = ``
+	commandGpInfo     eventCommand = `gpPage_\d{1,2}`
+	commandGpList1    eventCommand = `gpListPage_1`
+	commandGpList2    eventCommand = `gpListPage_2`
+	commandGpList3    eventCommand = `gpListPage_3`
+	commandPredPick   eventCommand = `predPick_\d+_\d+`
+	commandPredPage   eventCommand = `predPage_\d+_\d+`
+	commandPredUndo   eventCommand = `predUndo_\d+`
+	commandPredCancel eventCommand = `predCancel_\d+`
+	commandPredEdit   eventCommand = `predEdit_\d+_\d+`
+	commandNothing    eventCommand = ``
 )
 
 type eventCommand string
@@ -25,6 +30,11 @@ var compiledEventCommands = func() []struct {
 		{commandGpList1, `gpListPage_1`},
 		{commandGpList2, `gpListPage_2`},
 		{commandGpList3, `gpListPage_3`},
+		{commandPredPick, `\ApredPick_\d+_\d+\z`},
+		{commandPredPage, `\ApredPage_\d+_\d+\z`},
+		{commandPredUndo, `\ApredUndo_\d+\z`},
+		{commandPredCancel, `\ApredCancel_\d+\z`},
+		{commandPredEdit, `\ApredEdit_\d+_\d+\z`},
 	}
 
 	result := make([]struct {
@@ -2,10 +2,13 @@ package vk
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"racebot-vk/models"
-	"strconv"
+	"racebot-vk/service"
+	"racebot-vk/temperrors"
+	"regexp"
 	"strings"
 	"time"
 
@@ -27,6 +30,7 @@ type handlerContext struct {
 	userDate      time.Time
 	userTimestamp int
 	messageText   string
+	args          string // текст после слова команды
 	raceID        string
 }
 
@@ -43,8 +47,13 @@ var messageHandlers = map[command]messageHandlerFunc{
 	commandHello:              handleHello,
 	commandHelp:               handleHelp,
 	commandDrSt:               handleDriverStandings,
+	commandStandingsProgress:  handleStandingsProgression,
+	commandDriverProfile:      handleDriverProfile,
+	commandConstructorProfile: handleConstructorProfile,
+	commandHeadToHead:         handleHeadToHead,
 	commandCld:                handleCalendar,
 	commandNxRc:               handleNextRace,
+	commandCountdown:          handleCountdown,
 	commandConsStFull:         handleConstructorStandings,
 	commandConsSt:             handleConstructorStandings,
 	commandLstRc:              handleLastRace,
@@ -53,15 +62,27 @@ var messageHandlers = map[command]messageHandlerFunc{
 	commandDaysAfterRace:      handleDaysAfterRace,
 	commandDaysAfterRaceСut:   handleDaysAfterRace,
 	commandLstQual:            handleLastQual,
+	commandLstSpr:             handleLastSprint,
 	commandClsKb:              handleCloseKeyboard,
 	commandLvrsList:           handleLiveries,
 	commandPredictionAdmin:    handlePredictionAdmin,
 	commandPredictionUser:     handlePredictionUser,
+	commandPredictionExtras:   handlePredictionExtras,
 	commandClosePrediction:    handleClosePrediction,
 	commandPredictionResult:   handlePredictionResult,
 	commandPredictionSummary:  handlePredictionSummary,
 	commandPredictionRating:   handlePredictionRating,
 	commandMyPredictionRating: handleMyPredictionRating,
+	commandPredictionHistory:  handlePredictionHistory,
+	commandPredictionAudit:    handlePredictionAudit,
+	commandPredictionScoring:  handlePredictionScoring,
+	commandNickname:           handleNickname,
+	commandRoles:              handleRoles,
+	commandGrantRole:          handleGrantRole,
+	commandRevokeRole:         handleRevokeRole,
+	commandSubscribe:          handleSubscribe,
+	commandUnsubscribe:        handleUnsubscribe,
+	commandTimezone:           handleTimezone,
 	commandStartCheckStream:   handleCheckStream,
 	commandEndCheckStream:     handleCheckStream,
 }
@@ -79,6 +100,12 @@ var eventHandlers = map[eventCommand]eventHandlerFunc{
 	commandGpList2: handleGpListPage,
 	commandGpList3: handleGpListPage,
 	commandGpInfo:  handleGpInfo,
+
+	commandPredPick:   handleWizardEvent,
+	commandPredPage:   handleWizardEvent,
+	commandPredUndo:   handleWizardEvent,
+	commandPredCancel: handleWizardEvent,
+	commandPredEdit:   handleWizardEvent,
 }
 
 // ---------- Вспомогательные функции ----------
@@ -106,35 +133,90 @@ func sendEventAnswerAndDelete(log *slog.Logger, vk *VkAPI, peerID int, eventID s
 	}
 }
 
-// parsePredictionNumbers парсит строку "N1 N2 N3" в три числа
-func parsePredictionNumbers(text string) (uint8, uint8, uint8, error) {
-	parts := strings.Fields(text)
-	if len(parts) != 3 {
-		return 0, 0, 0, fmt.Errorf("error in parsing prediction")
-	}
-	top1, err := strconv.ParseUint(parts[0], 10, 8)
-	if err != nil {
-		return 0, 0, 0, fmt.Errorf("Top_1 position is not number")
+// predictionErrorMessage переводит ошибку сохранения прогноза в сообщение для пользователя,
+// дедлайны раундов указываются в часовом поясе loc
+func predictionErrorMessage(err error, loc *time.Location) string {
+	switch {
+	case errors.Is(err, temperrors.ErrPredictionClosed):
+		return "Приём прогнозов на эту гонку уже закрыт."
+	case errors.Is(err, temperrors.ErrPredictionNotFound):
+		return "Сначала отправьте прогноз на подиум гонки, затем ответьте на дополнительные вопросы."
+	case errors.Is(err, temperrors.ErrNotSprintWeekend):
+		return "На этом этапе нет спринта — прогноз на подиум спринта не принимается."
+	case errors.Is(err, temperrors.ErrQuestionClosed):
+		var closed *service.QuestionClosedError
+		if errors.As(err, &closed) {
+			return fmt.Sprintf("Ответы на вопрос «%s» принимались до старта %s — %s.", closed.Question, closed.Session, service.FormatZonedTime(closed.Deadline, loc))
+		}
+		return "Приём ответов на этот вопрос уже закрыт."
+	case errors.Is(err, temperrors.ErrUnknownDriver):
+		var unknown *service.UnknownDriverError
+		if errors.As(err, &unknown) {
+			return fmt.Sprintf("Гонщик «%s» не найден в заявке сезона. Укажите номер машины, код (VER) или фамилию (Ферстаппен).", unknown.Token)
+		}
+		return "Не удалось распознать гонщиков. Укажите номер машины, код (VER) или фамилию (Ферстаппен)."
+	case errors.Is(err, temperrors.ErrDuplicateDriver):
+		var duplicate *service.DuplicateDriverError
+		if errors.As(err, &duplicate) {
+			return fmt.Sprintf("Гонщик №%d указан несколько раз. Выберите трёх разных гонщиков.", duplicate.Number)
+		}
+		return "Гонщики в прогнозе не должны повторяться."
+	case errors.Is(err, temperrors.ErrAmbiguousRound):
+		var ambiguous *service.AmbiguousRoundError
+		if errors.As(err, &ambiguous) {
+			return "Сейчас открыто несколько конкурсов прогнозов. Укажите номер этапа после команды, например «мойпрогноз #" + ambiguous.Rounds[0].Round() + " VER NOR LEC»:\n\n" + service.FormatRoundChoice(ambiguous.Rounds, loc)
+		}
+		return "Сейчас открыто несколько конкурсов прогнозов. Укажите номер этапа после команды, например «#6»."
+	case errors.Is(err, temperrors.ErrRoundNotFound):
+		return "В этом чате нет конкурса прогнозов на указанный этап."
+	case errors.Is(err, temperrors.ErrRoundAlreadyOpen):
+		return "Конкурс прогнозов на эту гонку уже открыт."
+	case errors.Is(err, temperrors.ErrDriverNotOnGrid):
+		var notOnGrid *service.DriverNotOnGridError
+		if errors.As(err, &notOnGrid) {
+			return fmt.Sprintf("Под номером %d никто не выступает в сезоне %d. Проверьте номер по списку гонщиков в объявлении конкурса.", notOnGrid.Number, notOnGrid.Season)
+		}
+		return "Указанного гонщика нет в заявке сезона."
 	}
+	return "Не удалось сохранить прогноз. Повторите попытку позже."
+}
 
-	top2, err := strconv.ParseUint(parts[1], 10, 8)
+// resolveActiveRound выбирает открытый раунд чата по номеру этапа из аргументов команды
+// ("#6 ..."), возвращает раунд и оставшиеся аргументы. Если раунд не выбран однозначно,
+// отправляет пояснение в чат и возвращает nil; если открытых раундов нет — молча возвращает nil.
+func resolveActiveRound(ctx handlerContext, args, commandLabel string) (*models.PredictionRace, string) {
+	selector, rest := service.SplitRoundSelector(args)
+	race, err := ctx.vk.predictionService.ResolveActiveRound(models.PlatformVK, int64(ctx.obj.Message.PeerID), selector)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("Top_2 position is not number")
+		ctx.log.Warn("failed to resolve prediction round", slog.String("selector", selector), slog.Any("error", err))
+		ctx.vk.sendAndLog(ctx.log, predictionErrorMessage(err, userLocation(ctx)), ctx.obj.Message.PeerID, nil, nil, nil, commandLabel)
+		return nil, rest
 	}
+	return race, rest
+}
 
-	top3, err := strconv.ParseUint(parts[2], 10, 8)
+// resolveLatestRound выбирает раунд чата для просмотра: по номеру этапа из аргументов,
+// иначе открытый раунд, иначе последний проведённый. Если раунд не найден, отправляет
+// пояснение в чат и возвращает nil.
+func resolveLatestRound(ctx handlerContext, args, commandLabel string) (*models.PredictionRace, string) {
+	selector, rest := service.SplitRoundSelector(args)
+	race, err := ctx.vk.predictionService.ResolveLatestRound(models.PlatformVK, int64(ctx.obj.Message.PeerID), selector)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("Top_3 position is not number")
+		ctx.log.Warn("failed to resolve prediction round", slog.String("selector", selector), slog.Any("error", err))
+		ctx.vk.sendAndLog(ctx.log, predictionErrorMessage(err, userLocation(ctx)), ctx.obj.Message.PeerID, nil, nil, nil, commandLabel)
+		return nil, rest
 	}
-
-	return uint8(top1), uint8(top2), uint8(top3), nil
+	if race == nil {
+		ctx.vk.sendAndLog(ctx.log, "Конкурсов прогнозов ещё не было.", ctx.obj.Message.PeerID, nil, nil, nil, commandLabel)
+	}
+	return race, rest
 }
 
 // ---------- Обработчики текстовых команд ----------
 
 func handleHello(ctx handlerContext) error {
 	msg := `Привет! Я бот, который делится информацией про F1 :)
-Пока что я могу сказать тебе информацию только о текущем сезоне (но всё ещё впереди).
+Могу рассказать о любом сезоне с 1950 года: зачёты, календарь и результаты гонок, а ещё провести конкурс прогнозов.
 Для того чтобы подробнее познакомиться с моими возможностями напиши мне "Что умеешь?".
 
 Приятного пользования :)`
@@ -144,12 +226,21 @@ func handleHello(ctx handlerContext) error {
 
 func handleHelp(ctx handlerContext) error {
 	msg := `Команды которые я понимаю (могу их прочесть в твоём сообщении среди других слов):
-• календарь сезона - список гран-при F1 текущего сезона
-• кубок конструкторов или кк - текущее положение команд в кубке конструкторов
-• личный зачёт - текущее положение гонщиков в личном зачёте
+• календарь сезона [год] - список гран-при F1 сезона
+• кубок конструкторов или кк [год [этап]] - положение команд в кубке конструкторов
+• личный зачёт [год [этап]] - положение гонщиков в личном зачёте
+• динамика зачёта [год] - топ-10 личного зачёта после каждого этапа
 • следующая гонка - информация о следующем гран-при F1
-• результат гонки - результат последней прошедшей гонки F1
+• отсчёт - обратный отсчёт до ближайшей сессии (в начале сообщения)
+• гонщик ферстаппен - профиль и карьерная статистика гонщика
+• команда феррари - профиль команды и её кубки конструкторов
+• сравнить ферстаппен норрис [год] - сравнение двух гонщиков за сезон
+• результат гонки/квалы/спринта [год [этап]] - результаты сессии, без года - последней прошедшей
 • дней без формулы/F1 - количество дней с последней гонки F1
+• подписка [тема] / отписка тема - темы напоминаний и рассылок беседы (в начале сообщения)
+• пояс [беседа] город - часовой пояс расписания (в начале сообщения)
+
+Без года команды показывают текущий сезон, данные есть с 1950 года.
 
 !Внимание! Информация, связанная с проведённой гонкой может обновляться не сразу.
 Работаем над этим.`
@@ -158,7 +249,11 @@ func handleHelp(ctx handlerContext) error {
 }
 
 func handleDriverStandings(ctx handlerContext) error {
-	msg, err := ctx.vk.messageService.GetDriverStandingsMessage(ctx.userDate)
+	season, round, ok := seasonArgs(ctx, true, "driverStandings")
+	if !ok {
+		return nil
+	}
+	msg, err := ctx.vk.messageService.GetDriverStandingsMessage(season, round)
 	if err != nil {
 		ctx.log.Error("failed to get driver standings", slog.Any("error", err))
 		return err
@@ -167,8 +262,27 @@ func handleDriverStandings(ctx handlerContext) error {
 	return err
 }
 
+// handleStandingsProgression — топ-10 личного зачёта после каждого этапа: "динамика зачёта [год]"
+func handleStandingsProgression(ctx handlerContext) error {
+	season, _, ok := seasonArgs(ctx, false, "standingsProgression")
+	if !ok {
+		return nil
+	}
+	msg, err := ctx.vk.messageService.GetStandingsProgressionMessage(season)
+	if err != nil {
+		ctx.log.Error("failed to get standings progression", slog.Any("error", err))
+		return err
+	}
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "standingsProgression")
+	return err
+}
+
 func handleCalendar(ctx handlerContext) error {
-	msg, err := ctx.vk.messageService.GetCalendarMessage(ctx.userDate.Year())
+	season, _, ok := seasonArgs(ctx, false, "calendar")
+	if !ok {
+		return nil
+	}
+	msg, err := ctx.vk.messageService.GetCalendarMessage(season, userLocation(ctx))
 	if err != nil {
 		ctx.log.Error("failed to get calendar", slog.Any("error", err))
 		return err
@@ -178,7 +292,7 @@ func handleCalendar(ctx handlerContext) error {
 }
 
 func handleNextRace(ctx handlerContext) error {
-	msg, err := ctx.vk.messageService.GetNextRaceMessage(ctx.userDate, ctx.userTimestamp)
+	msg, err := ctx.vk.messageService.GetNextRaceMessage(ctx.userDate, ctx.userTimestamp, userLocation(ctx))
 	if err != nil {
 		ctx.log.Error("failed to get next race", slog.Any("error", err))
 		return err
@@ -187,8 +301,22 @@ func handleNextRace(ctx handlerContext) error {
 	return err
 }
 
+func handleCountdown(ctx handlerContext) error {
+	msg, err := ctx.vk.messageService.GetCountdownMessage(ctx.userDate, userLocation(ctx))
+	if err != nil {
+		ctx.log.Error("failed to get countdown", slog.Any("error", err))
+		return err
+	}
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "countdown")
+	return err
+}
+
 func handleConstructorStandings(ctx handlerContext) error {
-	msg, err := ctx.vk.messageService.GetConstructorStandingsMessage(ctx.userDate)
+	season, round, ok := seasonArgs(ctx, true, "constructorStandings")
+	if !ok {
+		return nil
+	}
+	msg, err := ctx.vk.messageService.GetConstructorStandingsMessage(season, round)
 	if err != nil {
 		ctx.log.Error("failed to get constructor standings", slog.Any("error", err))
 		return err
@@ -198,7 +326,11 @@ func handleConstructorStandings(ctx handlerContext) error {
 }
 
 func handleLastRace(ctx handlerContext) error {
-	msg, err := ctx.vk.messageService.GetRaceResultsMessage(ctx.userDate, ctx.raceID)
+	season, round, ok := seasonArgs(ctx, true, "lastRace")
+	if !ok {
+		return nil
+	}
+	msg, err := ctx.vk.messageService.GetRaceResultsMessage(season, round)
 	if err != nil {
 		ctx.log.Error("failed to get last race result", slog.Any("error", err))
 		return err
@@ -208,7 +340,7 @@ func handleLastRace(ctx handlerContext) error {
 }
 
 func handleLastGP(ctx handlerContext) error {
-	crsl, err := ctx.vk.messageService.GetGPInfoCarousel(ctx.userDate, ctx.raceID)
+	crsl, err := ctx.vk.messageService.GetGPInfoCarousel(ctx.userDate.Year(), ctx.raceID, userLocation(ctx))
 	if err != nil {
 		ctx.log.Error("failed to get GP info carousel", slog.Any("error", err))
 		return err
@@ -218,7 +350,7 @@ func handleLastGP(ctx handlerContext) error {
 }
 
 func handleGPs(ctx handlerContext) error {
-	count, err := ctx.vk.messageService.GetCountOfRaces(ctx.userDate)
+	count, err := ctx.vk.messageService.GetCountOfRaces(ctx.userDate.Year())
 	if err != nil {
 		ctx.log.Error("failed to get count of races", slog.Any("error", err))
 		return err
@@ -251,7 +383,11 @@ func handleDaysAfterRace(ctx handlerContext) error {
 }
 
 func handleLastQual(ctx handlerContext) error {
-	msg, err := ctx.vk.messageService.GetQualifyingResultsMessage(ctx.userDate, ctx.raceID)
+	season, round, ok := seasonArgs(ctx, true, "lastQual")
+	if !ok {
+		return nil
+	}
+	msg, err := ctx.vk.messageService.GetQualifyingResultsMessage(season, round)
 	if err != nil {
 		ctx.log.Error("failed to get qualifying result", slog.Any("error", err))
 		return err
@@ -260,6 +396,16 @@ func handleLastQual(ctx handlerContext) error {
 	return err
 }
 
+func handleLastSprint(ctx handlerContext) error {
+	season, round, ok := seasonArgs(ctx, true, "lastSprint")
+	if !ok {
+		return nil
+	}
+	msg := ctx.vk.messageService.GetSprintResultsMessage(season, round)
+	_, err := ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "lastSprint")
+	return err
+}
+
 func handleCloseKeyboard(ctx handlerContext) error {
 	kb, err := makeKeyboard(0, 0, 0, 0, false)
 	if err != nil {
@@ -286,53 +432,75 @@ func handleCloseKeyboard(ctx handlerContext) error {
 }
 
 func handleLiveries(ctx handlerContext) error {
-	photo := "photo-219009582_457239026"
-	_, err := ctx.vk.sendAndLog(ctx.log, "Ливреи машин 2024 года", ctx.obj.Message.PeerID, nil, nil, &photo, "liveries")
+	photo := ctx.vk.media.LiveriesPhoto
+	_, err := ctx.vk.sendAndLog(ctx.log, ctx.vk.media.LiveriesCaption, ctx.obj.Message.PeerID, nil, nil, &photo, "liveries")
 	return err
 }
 
 // ---------- Обработчики прогнозов ----------
 
-// handlePredictionAdmin — открывает конкурс прогнозов (только для админа)
+// handlePredictionAdmin — открывает конкурс прогнозов (для администраторов).
+// Администратор беседы, не являющийся администратором бота, открывает раунд только для своей беседы.
 func handlePredictionAdmin(ctx handlerContext) error {
-	if ctx.obj.Message.FromID != botAdminId {
+	if !hasRole(ctx, models.RoleAdmin) {
 		return nil
 	}
 
-	// Проверяем, нет ли уже активного раунда
-	activeRace, err := ctx.vk.predictionService.GetActiveRace()
-	if err != nil {
-		ctx.log.Error("failed to check active race", slog.Any("error", err))
-		return err
-	}
-	if activeRace != nil {
-		_, err := ctx.vk.sendAndLog(ctx.log, fmt.Sprintf("Прогноз уже активен для гонки: %s", activeRace.RaceName), ctx.obj.Message.PeerID, nil, nil, nil, "predictionAdmin")
-		return err
-	}
-
 	nxtRc, err := ctx.vk.messageService.GetNextRace(ctx.userDate, ctx.userTimestamp)
 	if err != nil {
 		ctx.log.Error("failed to get next race for prediction", slog.Any("error", err))
 		return err
 	}
 
-	raceID := nxtRc.Season + "_" + nxtRc.Round
+	// Необязательные аргументы: "чат" — раунд только для этого чата,
+	// правило подсчёта очков — "прогноз joker", "прогноз чат joker"
+	scoring := ""
+	var chatID int64
+	for _, arg := range strings.Fields(ctx.args) {
+		if arg == "чат" {
+			chatID = int64(ctx.obj.Message.PeerID)
+			continue
+		}
+		scoring = arg
+	}
+	if chatID == 0 && !ctx.vk.roles.HasRole(models.PlatformVK, ctx.obj.Message.FromID, 0, models.RoleAdmin) {
+		chatID = int64(ctx.obj.Message.PeerID)
+	}
 
 	// Создаём раунд в БД
-	err = ctx.vk.predictionService.StartPrediction(raceID, nxtRc.RaceName)
+	predRace, err := ctx.vk.predictionService.StartPrediction(nxtRc, scoring, models.PlatformVK, chatID)
 	if err != nil {
 		ctx.log.Error("failed to start prediction", slog.Any("error", err))
+		switch {
+		case errors.Is(err, temperrors.ErrUnknownScoringRule):
+			_, err = ctx.vk.sendAndLog(ctx.log, service.GetScoringRulesMessage(), ctx.obj.Message.PeerID, nil, nil, nil, "predictionAdmin")
+		case errors.Is(err, temperrors.ErrRoundAlreadyOpen):
+			_, err = ctx.vk.sendAndLog(ctx.log, predictionErrorMessage(err, userLocation(ctx)), ctx.obj.Message.PeerID, nil, nil, nil, "predictionAdmin")
+		}
 		return err
 	}
 
-	driversMessage, err := ctx.vk.messageService.GetDriversListMessage(ctx.userDate)
+	driversMessage, err := ctx.vk.messageService.GetDriversListMessage(ctx.userDate.Year())
 	if err != nil {
 		ctx.log.Error("failed to get drivers list", slog.Any("error", err))
 	}
 
-	chats := []int{botAdminId}
+	// Общий раунд объявляется в чатах, подписанных на прогнозы, раунд чата — только в этом чате
+	chats := []int{ctx.obj.Message.PeerID}
+	if chatID == 0 {
+		subscribed, err := ctx.vk.subscriptions.Chats(models.PlatformVK, models.TopicPredictions)
+		if err != nil {
+			ctx.log.Error("failed to get subscribed chats", slog.Any("error", err))
+		}
+		if len(subscribed) > 0 {
+			chats = chats[:0]
+			for _, chat := range subscribed {
+				chats = append(chats, int(chat))
+			}
+		}
+	}
 	for _, chat := range chats {
-		msg := "Открываем конкурс прогнозов! Укажите номера гонщиков, которые по вашему мнению займут первые 3 места по итогам будущей гонки. \n\nОтветьте на ЭТО сообщение в формате: №_топ1 №_топ2 №_топ3\nили напишите\n'/мойпрогноз №_топ1 №_топ2 №_топ3'\n\n\n Пример ответа: '23 17 29'"
+		msg := ctx.vk.predictionService.GetAnnouncementMessage(predRace, ctx.vk.timezones.ChatLocation(models.PlatformVK, int64(chat)))
 		msgResp, err := ctx.vk.sendAndLog(ctx.log, msg, chat, nil, nil, nil, "predictionStart")
 		if err != nil {
 			continue
@@ -345,36 +513,45 @@ func handlePredictionAdmin(ctx handlerContext) error {
 			continue
 		}
 	}
-	ctx.log.Info("Prediction started globally", slog.String("race_id", raceID))
+	ctx.log.Info("Prediction started", slog.String("race_id", predRace.RaceID), slog.Int("round_id", predRace.ID), slog.Int64("chat_id", chatID))
 	return nil
 }
 
-// handlePredictionUser — принимает прогноз от пользователя через команду /мойпрогноз
+// handlePredictionUser — принимает прогноз от пользователя через команду /мойпрогноз.
+// Без аргументов открывает выбор гонщиков кнопками (см. predictionWizard.go).
+// Если открыто несколько раундов, номер этапа указывается первым аргументом: "мойпрогноз #6 VER NOR LEC".
 func handlePredictionUser(ctx handlerContext) error {
-	activeRace, err := ctx.vk.predictionService.GetActiveRace()
-	if err != nil {
-		ctx.log.Error("failed to get active race", slog.Any("error", err))
-		return nil
-	}
+	activeRace, args := resolveActiveRound(ctx, ctx.args, "predictionError")
 	if activeRace == nil {
 		return nil
 	}
 
-	d1, d2, d3, err := parsePredictionNumbers(strings.TrimPrefix(ctx.messageText, "/мойпрогноз "))
+	// Без аргументов — выбор гонщиков кнопками
+	if args == "" {
+		return startPredictionWizard(ctx, activeRace)
+	}
+
+	d1, d2, d3, err := ctx.vk.predictionService.ParsePrediction(activeRace.RaceID, args)
 	if err != nil {
-		msg := "Неверный формат сообщения! Повторите попытку и укажите номера гонщиков, которые на ваш взгляд займут первые 3 места, в формате:\n\n/мойпрогноз №_топ1 №_топ2 №_топ3"
+		msg := "Неверный формат сообщения! Повторите попытку и укажите гонщиков, которые на ваш взгляд займут первые 3 места (номер, код или фамилия), в формате:\n\n/мойпрогноз №_топ1 №_топ2 №_топ3\n\nПример: /мойпрогноз VER NOR LEC"
+		if errors.Is(err, temperrors.ErrUnknownDriver) {
+			msg = predictionErrorMessage(err, userLocation(ctx))
+		}
 		ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionError")
 		return nil
 	}
 
-	err = ctx.vk.predictionService.SubmitPrediction(ctx.obj.Message.FromID, activeRace.RaceID, d1, d2, d3)
+	err = ctx.vk.predictionService.SubmitPrediction(models.PlatformVK, ctx.obj.Message.FromID, int64(ctx.obj.Message.PeerID), activeRace.ID, d1, d2, d3)
 	if err != nil {
 		ctx.log.Error("failed to save prediction", slog.Any("error", err))
-		msg := "Не удалось сохранить прогноз. Возможно вы уже отправляли прогноз на эту гонку."
-		ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionSaveError")
+		ctx.vk.sendAndLog(ctx.log, predictionErrorMessage(err, userLocation(ctx)), ctx.obj.Message.PeerID, nil, nil, nil, "predictionSaveError")
 		return nil
 	}
 
+	if err := ctx.vk.predictionService.EnsureUserProfile(models.PlatformVK, ctx.obj.Message.FromID); err != nil {
+		ctx.log.Warn("failed to update user profile", slog.Int("user_id", ctx.obj.Message.FromID), slog.Any("error", err))
+	}
+
 	msg := fmt.Sprintf("Ваш прогноз принят: 1. №%d, 2. №%d, 3. №%d", d1, d2, d3)
 	resp, err := ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionConfirm")
 	if err == nil && len(resp) > 0 {
@@ -383,23 +560,55 @@ func handlePredictionUser(ctx handlerContext) error {
 	return nil
 }
 
-// handleClosePrediction — закрывает приём прогнозов (только для админа)
-func handleClosePrediction(ctx handlerContext) error {
-	if ctx.obj.Message.FromID != botAdminId {
+// handlePredictionExtras — принимает ответы на дополнительные вопросы: "допрогноз [#этап] поул=1 команда=ferrari"
+func handlePredictionExtras(ctx handlerContext) error {
+	activeRace, args := resolveActiveRound(ctx, ctx.args, "predictionExtrasError")
+	if activeRace == nil {
 		return nil
 	}
 
-	activeRace, err := ctx.vk.predictionService.GetActiveRace()
+	extras, err := ctx.vk.predictionService.ParseExtras(activeRace.RaceID, args)
 	if err != nil {
-		ctx.log.Error("failed to get active race", slog.Any("error", err))
-		return err
+		msg := "Неверный формат сообщения! Укажите ответы на дополнительные вопросы в формате:\n\nдопрогноз поул=№ круг=№ сход=№ команда=название спринт=№,№,№\n\nЛюбой вопрос можно пропустить."
+		if errors.Is(err, temperrors.ErrUnknownDriver) {
+			msg = predictionErrorMessage(err, userLocation(ctx))
+		}
+		ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionExtrasError")
+		return nil
 	}
+
+	pred, err := ctx.vk.predictionService.SubmitExtras(models.PlatformVK, ctx.obj.Message.FromID, activeRace.ID, extras)
+	if err != nil {
+		ctx.log.Error("failed to save prediction extras", slog.Any("error", err))
+		ctx.vk.sendAndLog(ctx.log, predictionErrorMessage(err, userLocation(ctx)), ctx.obj.Message.PeerID, nil, nil, nil, "predictionSaveError")
+		return nil
+	}
+
+	msg := fmt.Sprintf("Ответы на дополнительные вопросы приняты: %s", service.FormatExtras(pred.Extras))
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionExtrasConfirm")
+	if err == nil {
+		ctx.log.Info("Prediction extras recorded", slog.Int("user_id", ctx.obj.Message.FromID))
+	}
+	return nil
+}
+
+// handleClosePrediction — закрывает приём прогнозов (для модераторов)
+func handleClosePrediction(ctx handlerContext) error {
+	if !hasRole(ctx, models.RoleModerator) {
+		return nil
+	}
+
+	activeRace, _ := resolveActiveRound(ctx, ctx.args, "closePrediction")
 	if activeRace == nil {
 		_, err := ctx.vk.sendAndLog(ctx.log, "Нет активного конкурса прогнозов.", ctx.obj.Message.PeerID, nil, nil, nil, "closePrediction")
 		return err
 	}
+	if !hasRoundRole(ctx, activeRace, models.RoleModerator) {
+		denyRoundAccess(ctx, "closePrediction")
+		return nil
+	}
 
-	err = ctx.vk.predictionService.ClosePrediction(activeRace.RaceID)
+	err := ctx.vk.predictionService.ClosePrediction(activeRace.ID)
 	if err != nil {
 		ctx.log.Error("failed to close prediction", slog.Any("error", err))
 		return err
@@ -409,43 +618,41 @@ func handleClosePrediction(ctx handlerContext) error {
 	return err
 }
 
-// handlePredictionResult — сохраняет реальные результаты гонки (только для админа)
+// handlePredictionResult — сохраняет реальные результаты гонки (для администраторов бота:
+// результаты общие для всех раундов гонки)
 func handlePredictionResult(ctx handlerContext) error {
-	if ctx.obj.Message.FromID != botAdminId {
+	if !hasRole(ctx, models.RoleAdmin) {
 		return nil
 	}
+	if !ctx.vk.roles.HasRole(models.PlatformVK, ctx.obj.Message.FromID, 0, models.RoleAdmin) {
+		_, err := ctx.vk.sendAndLog(ctx.log, "Недостаточно прав: результаты гонки вводят администраторы бота.", ctx.obj.Message.PeerID, nil, nil, nil, "predictionResult")
+		return err
+	}
 
 	// Ищем последнюю закрытую гонку без результатов
-	allRaces, err := ctx.vk.predictionService.GetAllRaces()
+	targetRace, err := ctx.vk.predictionService.GetRaceAwaitingResults(models.PlatformVK, int64(ctx.obj.Message.PeerID))
 	if err != nil {
-		ctx.log.Error("failed to get all races", slog.Any("error", err))
+		ctx.log.Error("failed to get race awaiting results", slog.Any("error", err))
 		return err
 	}
 
-	var targetRace *models.PredictionRace
-	for i := range allRaces {
-		r := &allRaces[i]
-		if !r.IsActive && r.Driver1 == nil {
-			targetRace = r
-			break
-		}
-	}
-
 	if targetRace == nil {
 		_, err := ctx.vk.sendAndLog(ctx.log, "Нет закрытых гонок без результатов.", ctx.obj.Message.PeerID, nil, nil, nil, "predictionResult")
 		return err
 	}
 
 	// Парсим "N1 N2 N3" из сообщения (убираем префикс команды)
-	text := strings.TrimPrefix(ctx.messageText, "результатпрогноза ")
-	d1, d2, d3, err := parsePredictionNumbers(text)
+	d1, d2, d3, err := ctx.vk.predictionService.ParsePrediction(targetRace.RaceID, ctx.args)
 	if err != nil {
 		msg := "Неверный формат. Используйте: результатпрогноза №_топ1 №_топ2 №_топ3"
+		if errors.Is(err, temperrors.ErrUnknownDriver) {
+			msg = predictionErrorMessage(err, userLocation(ctx))
+		}
 		ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionResultError")
 		return nil
 	}
 
-	err = ctx.vk.predictionService.SetRaceResult(targetRace.RaceID, d1, d2, d3)
+	err = ctx.vk.predictionService.SetRaceResult(targetRace.RaceID, d1, d2, d3, nil)
 	if err != nil {
 		ctx.log.Error("failed to set race result", slog.Any("error", err))
 		return err
@@ -455,161 +662,257 @@ func handlePredictionResult(ctx handlerContext) error {
 	return err
 }
 
-// handlePredictionSummary — подводит итоги прогнозов (только для админа)
+// handlePredictionSummary — подводит итоги прогнозов (для администраторов)
 func handlePredictionSummary(ctx handlerContext) error {
-	if ctx.obj.Message.FromID != botAdminId {
+	if !hasRole(ctx, models.RoleAdmin) {
 		return nil
 	}
 
 	// Ищем последнюю закрытую гонку с результатами, но без подсчитанных очков
-	allRaces, err := ctx.vk.predictionService.GetAllRaces()
+	targetRace, err := ctx.vk.predictionService.GetRaceAwaitingSummary(models.PlatformVK, int64(ctx.obj.Message.PeerID))
 	if err != nil {
-		ctx.log.Error("failed to get all races", slog.Any("error", err))
+		ctx.log.Error("failed to get race awaiting summary", slog.Any("error", err))
 		return err
 	}
 
-	var targetRace *models.PredictionRace
-	for i := range allRaces {
-		r := &allRaces[i]
-		if !r.IsActive && r.Driver1 != nil {
-			targetRace = r
-			break
-		}
-	}
-
 	if targetRace == nil {
 		_, err := ctx.vk.sendAndLog(ctx.log, "Нет закрытых гонок с результатами.", ctx.obj.Message.PeerID, nil, nil, nil, "predictionSummary")
 		return err
 	}
+	if !hasRoundRole(ctx, targetRace, models.RoleAdmin) {
+		denyRoundAccess(ctx, "predictionSummary")
+		return nil
+	}
 
-	results, err := ctx.vk.predictionService.CalculateResults(targetRace.RaceID)
+	results, err := ctx.vk.predictionService.CalculateResults(targetRace.ID)
 	if err != nil {
 		ctx.log.Error("failed to calculate results", slog.Any("error", err))
 		return err
 	}
 
-	if len(results) == 0 {
-		_, err := ctx.vk.sendAndLog(ctx.log, fmt.Sprintf("На гонку '%s' не было прогнозов.", targetRace.RaceName), ctx.obj.Message.PeerID, nil, nil, nil, "predictionSummary")
+	msg := ctx.vk.predictionService.GetSummaryMessage(targetRace, results)
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionSummary")
+	return err
+}
+
+// handlePredictionRating — показывает таблицу лидеров: "рейтингпрогнозов [сезон | чат | всё | дата дата]"
+func handlePredictionRating(ctx handlerContext) error {
+	scope, err := service.ParseLeaderboardScope(strings.Fields(ctx.args), models.PlatformVK, int64(ctx.obj.Message.PeerID), ctx.userDate, userLocation(ctx))
+	if err != nil {
+		msg := service.LeaderboardUsage
+		if errors.Is(err, temperrors.ErrSeasonOutOfRange) {
+			msg = service.SeasonRangeMessage(ctx.userDate) + "\n\n" + msg
+		}
+		_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionRating")
 		return err
 	}
 
-	// Формируем сообщение с результатами
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("🏁 Итоги конкурса прогнозов на гонку '%s'\n\n", targetRace.RaceName))
-	sb.WriteString(fmt.Sprintf("Результаты гонки: 1. №%d, 2. №%d, 3. №%d\n\n", *targetRace.Driver1, *targetRace.Driver2, *targetRace.Driver3))
-
-	for i, r := range results {
-		place := i + 1
-		sb.WriteString(fmt.Sprintf("%d. Пользователь [id%d|]\n", place, r.UserID))
-		sb.WriteString(fmt.Sprintf("   Прогноз: %s\n", r.Details))
-		sb.WriteString(fmt.Sprintf("   Очки: %d\n\n", r.Points))
+	msg, err := ctx.vk.predictionService.GetLeaderboardMessage(scope)
+	if err != nil {
+		ctx.log.Error("failed to get leaderboard", slog.Any("error", err))
+		return err
 	}
 
-	_, err = ctx.vk.sendAndLog(ctx.log, sb.String(), ctx.obj.Message.PeerID, nil, nil, nil, "predictionSummary")
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionRating")
 	return err
 }
 
-// handlePredictionRating — показывает таблицу лидеров
-func handlePredictionRating(ctx handlerContext) error {
-	leaderboard, err := ctx.vk.predictionService.GetLeaderboard()
-	if err != nil {
-		ctx.log.Error("failed to get leaderboard", slog.Any("error", err))
+// handleNickname — задаёт никнейм вместо настоящего имени в рейтингах: "никнейм Ник" / "никнейм сброс"
+func handleNickname(ctx handlerContext) error {
+	userID := ctx.obj.Message.FromID
+
+	// Берём исходный текст, чтобы сохранить регистр никнейма
+	fields := strings.Fields(stripLeadingMention(ctx.obj.Message.Text))
+	if len(fields) < 2 {
+		msg, err := ctx.vk.predictionService.GetNicknameMessage(models.PlatformVK, userID)
+		if err != nil {
+			ctx.log.Error("failed to get nickname", slog.Any("error", err))
+			return err
+		}
+		_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "nickname")
 		return err
 	}
 
-	if len(leaderboard) == 0 {
-		_, err := ctx.vk.sendAndLog(ctx.log, "Таблица лидеров пока пуста.", ctx.obj.Message.PeerID, nil, nil, nil, "predictionRating")
+	nickname, err := ctx.vk.predictionService.SetNickname(models.PlatformVK, userID, strings.Join(fields[1:], " "))
+	if err != nil {
+		ctx.log.Error("failed to set nickname", slog.Any("error", err))
+		_, err = ctx.vk.sendAndLog(ctx.log, service.NicknameUsage, ctx.obj.Message.PeerID, nil, nil, nil, "nickname")
 		return err
 	}
 
-	var sb strings.Builder
-	sb.WriteString("🏆 Таблица лидеров прогнозов:\n\n")
-	for i, st := range leaderboard {
-		place := i + 1
-		sb.WriteString(fmt.Sprintf("%d. Пользователь [id%d|] — %d очков (%d гонок, среднее: %.1f)\n",
-			place, st.UserID, st.TotalPoints, st.TotalRaces, st.AvgPoints))
+	msg := fmt.Sprintf("Теперь в рейтингах и итогах прогнозов вместо вашего имени будет показан никнейм «%s».", nickname)
+	if nickname == "" {
+		if err := ctx.vk.predictionService.EnsureUserProfile(models.PlatformVK, userID); err != nil {
+			ctx.log.Warn("failed to update user profile", slog.Int("user_id", userID), slog.Any("error", err))
+		}
+		msg = "Никнейм сброшен: в рейтингах снова будет показано ваше имя."
 	}
-
-	_, err = ctx.vk.sendAndLog(ctx.log, sb.String(), ctx.obj.Message.PeerID, nil, nil, nil, "predictionRating")
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "nickname")
 	return err
 }
 
 // handleMyPredictionRating — показывает статистику пользователя
 func handleMyPredictionRating(ctx handlerContext) error {
-	stats, err := ctx.vk.predictionService.GetUserStats(ctx.obj.Message.FromID)
+	msg, err := ctx.vk.predictionService.GetUserStatsMessage(models.PlatformVK, ctx.obj.Message.FromID)
 	if err != nil {
 		ctx.log.Error("failed to get user stats", slog.Any("error", err))
 		return err
 	}
 
-	if stats.TotalRaces == 0 {
-		_, err := ctx.vk.sendAndLog(ctx.log, "У вас пока нет прогнозов.", ctx.obj.Message.PeerID, nil, nil, nil, "myPredictionRating")
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "myPredictionRating")
+	return err
+}
+
+// handlePredictionHistory — показывает пользователю историю его прогноза на текущую гонку
+func handlePredictionHistory(ctx handlerContext) error {
+	race, _ := resolveLatestRound(ctx, ctx.args, "predictionHistory")
+	if race == nil {
+		return nil
+	}
+
+	msg, err := ctx.vk.predictionService.GetUserHistoryMessage(models.PlatformVK, ctx.obj.Message.FromID, race, userLocation(ctx))
+	if err != nil {
+		ctx.log.Error("failed to get prediction history", slog.Any("error", err))
 		return err
 	}
 
-	msg := fmt.Sprintf("📊 Ваша статистика прогнозов:\n\nВсего очков: %d\nУчастий в гонках: %d\nСреднее очков: %.1f\nЛучший результат: %d (гонка: %s)",
-		stats.TotalPoints, stats.TotalRaces, stats.AvgPoints, stats.BestPoints, stats.BestRaceID)
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionHistory")
+	return err
+}
 
-	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "myPredictionRating")
+// handlePredictionAudit — показывает все прогнозы на текущую гонку со временем правок (для модераторов)
+func handlePredictionAudit(ctx handlerContext) error {
+	if !hasRole(ctx, models.RoleModerator) {
+		return nil
+	}
+
+	race, _ := resolveLatestRound(ctx, ctx.args, "predictionAudit")
+	if race == nil {
+		return nil
+	}
+	if !hasRoundRole(ctx, race, models.RoleModerator) {
+		denyRoundAccess(ctx, "predictionAudit")
+		return nil
+	}
+
+	msg, err := ctx.vk.predictionService.GetRaceAuditMessage(race, userLocation(ctx))
+	if err != nil {
+		ctx.log.Error("failed to get prediction audit", slog.Any("error", err))
+		return err
+	}
+
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionAudit")
 	return err
 }
 
-func handleCheckStream(ctx handlerContext) error {
-	streamCheckMu.Lock()
+// handlePredictionScoring — показывает правила подсчёта очков или меняет правило
+// текущего раунда: "правилапрогноза joker" (для администраторов)
+func handlePredictionScoring(ctx handlerContext) error {
+	if !hasRole(ctx, models.RoleAdmin) {
+		return nil
+	}
 
-	if ctx.messageText == "strstart" {
-		if streamChecking {
-			streamCheckMu.Unlock()
-			ctx.vk.sendAndLog(ctx.log, "Отслеживание уже запущено! Сначала завершите текущее отслеживание.", ctx.obj.Message.PeerID, nil, nil, nil, "checkStreamStart")
-			return nil
-		}
+	args := ctx.args
+	if args == "" {
+		_, err := ctx.vk.sendAndLog(ctx.log, service.GetScoringRulesMessage(), ctx.obj.Message.PeerID, nil, nil, nil, "predictionScoring")
+		return err
+	}
 
-		lastVideo, err := getLastVideos(*ctx.myUsrVk, 2)
-		if err != nil {
-			streamCheckMu.Unlock()
-			ctx.log.Error("failed to get last videos", slog.Any("error", err))
-			return err
-		}
-		if len(lastVideo) == 0 {
-			streamCheckMu.Unlock()
-			ctx.vk.sendAndLog(ctx.log, "Не удалось получить список видео — отслеживание не запущено.", ctx.obj.Message.PeerID, nil, nil, nil, "checkStreamStart")
-			return nil
+	race, args := resolveLatestRound(ctx, args, "predictionScoring")
+	if race == nil {
+		return nil
+	}
+	if !hasRoundRole(ctx, race, models.RoleAdmin) {
+		denyRoundAccess(ctx, "predictionScoring")
+		return nil
+	}
+
+	rule, err := ctx.vk.predictionService.SetRaceScoring(race.ID, args)
+	if err != nil {
+		ctx.log.Error("failed to set race scoring", slog.Any("error", err))
+		if errors.Is(err, temperrors.ErrUnknownScoringRule) {
+			_, err = ctx.vk.sendAndLog(ctx.log, service.GetScoringRulesMessage(), ctx.obj.Message.PeerID, nil, nil, nil, "predictionScoring")
 		}
-		lastStreamId = lastVideo[0].ID
+		return err
+	}
 
-		// Немедленная первая проверка: если она упадёт, тикер не запускаем
-		if !checkStreamOnce(ctx.log, ctx.vk, ctx.myUsrVk, ctx.obj) {
-			streamCheckMu.Unlock()
-			ctx.log.Info("Video check aborted on start")
-			return nil
+	msg := fmt.Sprintf("Для гонки '%s' установлено правило подсчёта очков: %s — %s.", race.RaceName, rule.Name(), rule.Description())
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionScoring")
+	return err
+}
+
+// handleCheckStream — запускает (strstart) или останавливает (strend) отслеживание трансляций.
+// В беседе сообщества команда действует на это сообщество, в остальных чатах — на все.
+func handleCheckStream(ctx handlerContext) error {
+	for _, st := range ctx.vk.streamsForPeer(ctx.obj.Message.PeerID) {
+		if ctx.messageText == "strstart" {
+			startStreamCheck(ctx, st)
+		} else {
+			stopStreamCheck(ctx, st)
 		}
+	}
+	return nil
+}
 
-		streamChecking = true
-		streamQuit = make(chan bool)
-		streamTicker = time.NewTicker(5 * time.Minute)
+// startStreamCheck запускает отслеживание трансляций сообщества
+func startStreamCheck(ctx handlerContext, st *streamState) {
+	st.mu.Lock()
 
-		ctx.vk.sendAndLog(ctx.log, "Команда принята!", ctx.obj.Message.PeerID, nil, nil, nil, "checkStreamStart")
-		ctx.log.Info("Start video check")
+	if st.checking {
+		st.mu.Unlock()
+		ctx.vk.sendAndLog(ctx.log, fmt.Sprintf("Отслеживание «%s» уже запущено! Сначала завершите текущее отслеживание.", st.community.Name), ctx.obj.Message.PeerID, nil, nil, nil, "checkStreamStart")
+		return
+	}
 
-		// Снимаем блокировку ДО запуска горутины, иначе checkLastStream
-		// при завершении не сможет захватить мьютекс в своём defer
-		// (это приводило к взаимной блокировке и зависанию обработки всех команд).
-		streamCheckMu.Unlock()
-		go checkLastStream(streamQuit, streamTicker, ctx.log, ctx.vk, ctx.myUsrVk, ctx.obj)
-		return nil
+	lastVideo, err := getLastVideos(*ctx.myUsrVk, st.community.GroupID, 2)
+	if err != nil {
+		st.mu.Unlock()
+		ctx.log.Error("failed to get last videos", slog.String("community", st.community.Name), slog.Any("error", err))
+		return
 	}
+	if len(lastVideo) == 0 {
+		st.mu.Unlock()
+		ctx.vk.sendAndLog(ctx.log, fmt.Sprintf("Не удалось получить список видео «%s» — отслеживание не запущено.", st.community.Name), ctx.obj.Message.PeerID, nil, nil, nil, "checkStreamStart")
+		return
+	}
+	st.lastStreamID = lastVideo[0].ID
 
-	if !streamChecking || streamQuit == nil {
-		streamCheckMu.Unlock()
-		ctx.vk.sendAndLog(ctx.log, "Отслеживание уже остановлено! Нечего завершать.", ctx.obj.Message.PeerID, nil, nil, nil, "checkStreamEnd")
-		return nil
+	// Немедленная первая проверка: если она упадёт, тикер не запускаем
+	if !checkStreamOnce(ctx.log, ctx.vk, st, ctx.myUsrVk, ctx.obj) {
+		st.mu.Unlock()
+		ctx.log.Info("Video check aborted on start", slog.String("community", st.community.Name))
+		return
+	}
+
+	st.checking = true
+	st.quit = make(chan bool)
+	st.ticker = time.NewTicker(5 * time.Minute)
+
+	ctx.vk.sendAndLog(ctx.log, fmt.Sprintf("Команда принята! Отслеживаю трансляции «%s».", st.community.Name), ctx.obj.Message.PeerID, nil, nil, nil, "checkStreamStart")
+	ctx.log.Info("Start video check", slog.String("community", st.community.Name))
+
+	// Снимаем блокировку ДО запуска горутины, иначе checkLastStream
+	// при завершении не сможет захватить мьютекс в своём defer
+	// (это приводило к взаимной блокировке и зависанию обработки всех команд).
+	st.mu.Unlock()
+	go checkLastStream(st.quit, st.ticker, ctx.log, ctx.vk, st, ctx.myUsrVk, ctx.obj)
+}
+
+// stopStreamCheck останавливает отслеживание трансляций сообщества
+func stopStreamCheck(ctx handlerContext, st *streamState) {
+	st.mu.Lock()
+
+	if !st.checking || st.quit == nil {
+		st.mu.Unlock()
+		ctx.vk.sendAndLog(ctx.log, fmt.Sprintf("Отслеживание «%s» уже остановлено! Нечего завершать.", st.community.Name), ctx.obj.Message.PeerID, nil, nil, nil, "checkStreamEnd")
+		return
 	}
 
 	// Фиксируем канал и снимаем мьютекс до сигнала остановки: функция
 	// checkLastStream при выходе блокирует тот же мьютекс, поэтому держать
 	// его во время отправки в канал — гарантированный дедлок.
-	quit := streamQuit
-	streamCheckMu.Unlock()
+	quit := st.quit
+	st.mu.Unlock()
 
 	// Неблокирующая отправка сигнала: если горутина уже завершилась,
 	// не зависаем навсегда в ожидании читателя канала.
@@ -618,14 +921,13 @@ func handleCheckStream(ctx handlerContext) error {
 	default:
 	}
 
-	ctx.vk.sendAndLog(ctx.log, "Команда принята!", ctx.obj.Message.PeerID, nil, nil, nil, "checkStreamEnd")
-	return nil
+	ctx.vk.sendAndLog(ctx.log, fmt.Sprintf("Команда принята! Отслеживание «%s» остановлено.", st.community.Name), ctx.obj.Message.PeerID, nil, nil, nil, "checkStreamEnd")
 }
 
 // ---------- Обработчики команд из payload (кнопки) ----------
 
 func handleRaceRes(ctx handlerContext) error {
-	msg, err := ctx.vk.messageService.GetRaceResultsMessage(ctx.userDate, ctx.raceID)
+	msg, err := ctx.vk.messageService.GetRaceResultsMessage(ctx.userDate.Year(), ctx.raceID)
 	if err != nil {
 		ctx.log.Error("failed to get race result", slog.Any("error", err))
 		return err
@@ -635,7 +937,7 @@ func handleRaceRes(ctx handlerContext) error {
 }
 
 func handleQualRes(ctx handlerContext) error {
-	msg, err := ctx.vk.messageService.GetQualifyingResultsMessage(ctx.userDate, ctx.raceID)
+	msg, err := ctx.vk.messageService.GetQualifyingResultsMessage(ctx.userDate.Year(), ctx.raceID)
 	if err != nil {
 		ctx.log.Error("failed to get qualifying result", slog.Any("error", err))
 		return err
@@ -645,7 +947,7 @@ func handleQualRes(ctx handlerContext) error {
 }
 
 func handleSprRes(ctx handlerContext) error {
-	msg := ctx.vk.messageService.GetSprintResultsMessage(ctx.userDate, ctx.raceID)
+	msg := ctx.vk.messageService.GetSprintResultsMessage(ctx.userDate.Year(), ctx.raceID)
 	_, err := ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "sprRes")
 	return err
 }
@@ -688,7 +990,7 @@ func handleGpInfo(ctx eventHandlerContext) error {
 	timeNow := time.Now()
 	number := strings.Split(ctx.payload, "_")
 
-	curRace, err := ctx.vk.eventService.GetGPInfoCarousel(timeNow, number[1])
+	curRace, err := ctx.vk.eventService.GetGPInfoCarousel(timeNow.Year(), number[1], ctx.vk.timezones.Location(models.PlatformVK, ctx.obj.UserID, int64(ctx.obj.PeerID)))
 	if err != nil {
 		ctx.log.Error("failed to get GP info carousel", slog.Any("error", err))
 		return err
@@ -707,31 +1009,52 @@ func handleGpInfo(ctx eventHandlerContext) error {
 
 // ---------- Обработчик неизвестной команды (reply-прогноз) ----------
 
+// announcementRoundRe находит номер этапа в объявлении конкурса прогнозов
+var announcementRoundRe = regexp.MustCompile(`Номер этапа: #(\d+)`)
+
 func handleUnknownWithPrediction(ctx handlerContext) error {
-	activeRace, err := ctx.vk.predictionService.GetActiveRace()
-	if err != nil {
-		ctx.log.Error("failed to get active race", slog.Any("error", err))
+	// Прогнозом считается только ответ на объявление конкурса от самого бота, иначе бот
+	// отвечал бы на обычную переписку в чате с открытым раундом
+	reply := ctx.obj.Message.ReplyMessage
+	if reply == nil || reply.FromID != -ctx.vk.lp.GroupID {
 		return nil
 	}
-	if activeRace == nil || ctx.obj.Message.ReplyMessage == nil {
+	m := announcementRoundRe.FindStringSubmatch(reply.Text)
+	if m == nil {
 		return nil
 	}
 
-	d1, d2, d3, err := parsePredictionNumbers(ctx.messageText)
+	// Номер этапа берём из сообщения или из объявления конкурса, на которое ответил пользователь
+	args := ctx.messageText
+	if !strings.HasPrefix(args, "#") {
+		args = "#" + m[1] + " " + args
+	}
+	activeRace, args := resolveActiveRound(ctx, args, "predictionParseError")
+	if activeRace == nil {
+		return nil
+	}
+
+	d1, d2, d3, err := ctx.vk.predictionService.ParsePrediction(activeRace.RaceID, args)
 	if err != nil {
-		msg := "Неверный формат сообщения! Повторите попытку и укажите только номера гонщиков, которые на ваш взгляд займут первые 3 места."
+		msg := "Неверный формат сообщения! Повторите попытку и укажите только гонщиков (номер, код или фамилия), которые на ваш взгляд займут первые 3 места."
+		if errors.Is(err, temperrors.ErrUnknownDriver) {
+			msg = predictionErrorMessage(err, userLocation(ctx))
+		}
 		ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionParseError")
 		return nil
 	}
 
-	err = ctx.vk.predictionService.SubmitPrediction(ctx.obj.Message.FromID, activeRace.RaceID, d1, d2, d3)
+	err = ctx.vk.predictionService.SubmitPrediction(models.PlatformVK, ctx.obj.Message.FromID, int64(ctx.obj.Message.PeerID), activeRace.ID, d1, d2, d3)
 	if err != nil {
 		ctx.log.Error("failed to save prediction", slog.Any("error", err))
-		msg := "Не удалось сохранить прогноз. Возможно вы уже отправляли прогноз на эту гонку."
-		ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionSaveError")
+		ctx.vk.sendAndLog(ctx.log, predictionErrorMessage(err, userLocation(ctx)), ctx.obj.Message.PeerID, nil, nil, nil, "predictionSaveError")
 		return nil
 	}
 
+	if err := ctx.vk.predictionService.EnsureUserProfile(models.PlatformVK, ctx.obj.Message.FromID); err != nil {
+		ctx.log.Warn("failed to update user profile", slog.Int("user_id", ctx.obj.Message.FromID), slog.Any("error", err))
+	}
+
 	msg := fmt.Sprintf("Ваш прогноз принят: 1. №%d, 2. №%d, 3. №%d", d1, d2, d3)
 
 	resp, err := ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionReplyConfirm")
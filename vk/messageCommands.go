@@ -1,11 +1,18 @@
 package vk
 
-import "regexp"
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 const (
 	commandDrSt               command = `личн.*зач[её]т`
+	commandStandingsProgress  command = `динамик.*зач[её]т`
 	commandCld                command = `календар.*сезона`
 	commandNxRc               command = `следующ.*гонк`
+	commandCountdown          command = `\A(?:отсч[её]т|сколько до)`
 	commandConsStFull         command = `куб.*конструктор`
 	commandConsSt             command = `кк`
 	commandLstRc              command = `результат.?\sгонк`
@@ -26,11 +33,25 @@ const (
 	commandLvrsList           command = `ливреи`
 	commandPredictionAdmin    command = `\Aпрогноз`
 	commandPredictionUser     command = `мойпрогноз`
+	commandPredictionExtras   command = `\Aдопрогноз`
 	commandClosePrediction    command = `закрытьпрогноз`
 	commandPredictionResult   command = `результатпрогноза`
 	commandPredictionSummary  command = `итогипрогноза`
 	commandPredictionRating   command = `рейтингпрогнозов`
 	commandMyPredictionRating command = `мойрейтинг`
+	commandPredictionHistory  command = `историяпрогноза`
+	commandPredictionAudit    command = `прогнозыгонки`
+	commandPredictionScoring  command = `правилапрогноза`
+	commandNickname           command = `\Aникнейм`
+	commandRoles              command = `\Aроли\z`
+	commandGrantRole          command = `\Aназначитьроль`
+	commandRevokeRole         command = `\Aснятьроль`
+	commandSubscribe          command = `\Aподписка`
+	commandUnsubscribe        command = `\Aотписка`
+	commandTimezone           command = `\A(?:часовой\s+)?пояс`
+	commandDriverProfile      command = `\Aгонщик(?:\s|\z)`
+	commandConstructorProfile command = `\Aкоманда(?:\s|\z)`
+	commandHeadToHead         command = `\Aсравни(?:ть)?(?:\s|\z)`
 	commandUnknown            command = ``
 )
 
@@ -45,8 +66,23 @@ var compiledCommands = func() []struct {
 		cmd   command
 		regex string
 	}{
+		// Аргументы этих команд — произвольный текст (названия команд, никнеймы, упоминания, темы подписки, города, гонщики, команды),
+		// поэтому они проверяются раньше остальных
+		{commandPredictionExtras, `\Aдопрогноз`},
+		{commandNickname, `\Aникнейм`},
+		{commandGrantRole, `\Aназначитьроль`},
+		{commandRevokeRole, `\Aснятьроль`},
+		{commandRoles, `\Aроли\z`},
+		{commandSubscribe, `\Aподписка`},
+		{commandUnsubscribe, `\Aотписка`},
+		{commandTimezone, `\A(?:часовой\s+)?пояс`},
+		{commandDriverProfile, `\Aгонщик(?:\s|\z)`},
+		{commandConstructorProfile, `\Aкоманда(?:\s|\z)`},
+		{commandHeadToHead, `\Aсравни(?:ть)?(?:\s|\z)`},
+		{commandStandingsProgress, `динамик.*зач[её]т`},
 		{commandDrSt, `личн.*зач[её]т`},
 		{commandCld, `календар.*сезона`},
+		{commandCountdown, `\A(?:отсч[её]т|сколько до)`},
 		{commandNxRc, `следующ.*гонк`},
 		{commandConsStFull, `куб.*конструктор`},
 		{commandConsSt, `кк`},
@@ -66,8 +102,16 @@ var compiledCommands = func() []struct {
 		{commandSprRes, `sprRes_\d{1,2}`},
 		{commandClsKb, `выклкб`},
 		{commandLvrsList, `ливреи`},
+		{commandPredictionAudit, `прогнозыгонки`},
 		{commandPredictionAdmin, `\Aпрогноз`},
 		{commandPredictionUser, `мойпрогноз`},
+		{commandClosePrediction, `закрытьпрогноз`},
+		{commandPredictionResult, `результатпрогноза`},
+		{commandPredictionSummary, `итогипрогноза`},
+		{commandPredictionRating, `рейтингпрогнозов`},
+		{commandMyPredictionRating, `мойрейтинг`},
+		{commandPredictionHistory, `историяпрогноза`},
+		{commandPredictionScoring, `правилапрогноза`},
 	}
 
 	result := make([]struct {
@@ -84,11 +128,36 @@ var compiledCommands = func() []struct {
 	return result
 }()
 
-func getCommand(message string) command {
+// leadingMention — упоминания сообщества в начале сообщения: "[club211183989|@f1bot], "
+var leadingMention = regexp.MustCompile(`\A\s*(?:\[(?:club|public)\d+\|[^\]]*\][\s,.:!]*)+`)
+
+// getCommand возвращает команду сообщения и текст после слова, в котором она найдена
+// ("результат гонки 2021 22" → "2021 22"). Текст до команды (упоминания бота) в аргументы не попадает.
+// Упоминания в начале сообщения отбрасываются до поиска, поэтому команды, которые должны
+// стоять первыми (\A), распознаются и в обращении к боту в беседе.
+func getCommand(message string) (command, string) {
+	message = stripLeadingMention(message)
 	for _, entry := range compiledCommands {
-		if entry.regex.MatchString(message) {
-			return entry.cmd
+		if loc := entry.regex.FindStringIndex(message); loc != nil {
+			return entry.cmd, argsAfter(message, loc[1])
+		}
+	}
+	return commandUnknown, ""
+}
+
+// stripLeadingMention убирает упоминания сообщества в начале сообщения
+func stripLeadingMention(message string) string {
+	return leadingMention.ReplaceAllString(message, "")
+}
+
+// argsAfter возвращает текст после слова, на котором закончилось совпадение команды
+func argsAfter(message string, end int) string {
+	if last, _ := utf8.DecodeLastRuneInString(message[:end]); end > 0 && !unicode.IsSpace(last) {
+		if i := strings.IndexFunc(message[end:], unicode.IsSpace); i >= 0 {
+			end += i
+		} else {
+			end = len(message)
 		}
 	}
-	return commandUnknown
+	return strings.TrimSpace(message[end:])
 }
@@ -0,0 +1,54 @@
+package vk
+
+import "testing"
+
+func TestGetCommand(t *testing.T) {
+	tests := []struct {
+		message string
+		cmd     command
+		args    string
+	}{
+		{"личный зачёт", commandDrSt, ""},
+		{"личный зачёт 2008 5", commandDrSt, "2008 5"},
+		{"[club211183989|@f1bot] личный зачёт 2008", commandDrSt, "2008"},
+		{"результат гонки 2021 22", commandLstRc, "2021 22"},
+		{"результаты квалы 2021", commandLstQual, "2021"},
+		{"кк", commandConsSt, ""},
+		{"кк 2 раза", commandConsSt, "2 раза"},
+		{"динамика зачёта 2021", commandStandingsProgress, "2021"},
+		{"гонщик ферстаппен", commandDriverProfile, "ферстаппен"},
+		{"сравнить ham ros 2016", commandHeadToHead, "ham ros 2016"},
+		{"рейтингпрогнозов сезон 2025", commandPredictionRating, "сезон 2025"},
+		{"отсчёт", commandCountdown, ""},
+		{"сколько до гонки?", commandCountdown, "гонки?"},
+		{"не знаю, сколько до конца сезона", commandUnknown, ""},
+		{"запустил отсчет таймера", commandUnknown, ""},
+		{"гонщики", commandUnknown, ""},
+		// Команды, которые должны стоять первыми, распознаются и после упоминания бота
+		{"[club211183989|@f1bot] допрогноз поул=1", commandPredictionExtras, "поул=1"},
+		{"[club211183989|@f1bot], прогноз", commandPredictionAdmin, ""},
+		{"[club211183989|@f1bot] никнейм Макс", commandNickname, "Макс"},
+		{"[club211183989|@f1bot] роли", commandRoles, ""},
+		{"[club211183989|@f1bot] назначитьроль [id1|Макс] модератор", commandGrantRole, "[id1|Макс] модератор"},
+		{"[club211183989|@f1bot] снятьроль [id1|Макс] модератор", commandRevokeRole, "[id1|Макс] модератор"},
+		{"[club211183989|@f1bot] подписка результаты", commandSubscribe, "результаты"},
+		{"[club211183989|@f1bot] отписка результаты", commandUnsubscribe, "результаты"},
+		{"[club211183989|@f1bot] пояс екатеринбург", commandTimezone, "екатеринбург"},
+		{"[public211183989|@f1bot]: часовой пояс", commandTimezone, ""},
+		{"[club211183989|@f1bot] отсчёт", commandCountdown, ""},
+		{"[club211183989|@f1bot] гонщик ферстаппен", commandDriverProfile, "ферстаппен"},
+		{"[club211183989|@f1bot] команда феррари", commandConstructorProfile, "феррари"},
+		{"[club211183989|@f1bot] сравнить ham ros 2016", commandHeadToHead, "ham ros 2016"},
+		{"привет [club211183989|@f1bot] пояс", commandUnknown, ""},
+		{"привет", commandUnknown, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			cmd, args := getCommand(tt.message)
+			if cmd != tt.cmd || args != tt.args {
+				t.Errorf("getCommand(%q) = %q, %q; want %q, %q", tt.message, cmd, args, tt.cmd, tt.args)
+			}
+		})
+	}
+}
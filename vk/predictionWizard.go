@@ -0,0 +1,372 @@
+package vk
+
+import (
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	"racebot-vk/service"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Пошаговый ввод прогноза кнопками: P1 → P2 → P3 → отправка.
+// Черновик хранится на сервере для пары (чат, пользователь), в payload кнопок
+// передаётся только владелец черновика и выбранный номер.
+
+const (
+	wizardDraftTTL = 30 * time.Minute
+	// В inline-клавиатуре VK не больше 10 кнопок: 6 гонщиков и до 4 кнопок навигации
+	wizardRows        = 2 // рядов с гонщиками на странице
+	wizardCols        = 3 // гонщиков в ряду
+	wizardPageSize    = wizardRows * wizardCols
+	wizardPayloadBase = `{"command" : "%s"}`
+)
+
+// predictionDraft — незавершённый прогноз пользователя
+type predictionDraft struct {
+	roundID   int // раунд прогнозов (prediction_races.id)
+	raceID    string
+	raceName  string
+	picks     []uint8
+	page      int
+	messageID int // conversation_message_id сообщения с клавиатурой
+	updatedAt time.Time
+}
+
+type wizardKey struct {
+	peerID int
+	userID int
+}
+
+// predictionWizard — черновики прогнозов, вводимых кнопками
+type predictionWizard struct {
+	mu     sync.Mutex
+	drafts map[wizardKey]*predictionDraft
+}
+
+func newPredictionWizard() *predictionWizard {
+	return &predictionWizard{drafts: make(map[wizardKey]*predictionDraft)}
+}
+
+// start создаёт новый черновик (прежний черновик пользователя в этом чате отбрасывается)
+func (w *predictionWizard) start(peerID, userID int, race *models.PredictionRace) *predictionDraft {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.cleanup()
+	draft := &predictionDraft{roundID: race.ID, raceID: race.RaceID, raceName: race.RaceName, updatedAt: time.Now()}
+	w.drafts[wizardKey{peerID, userID}] = draft
+	return draft
+}
+
+// update применяет fn к черновику пользователя, если кнопка нажата на сообщении черновика
+// (messageID 0 — без проверки). Возвращает копию черновика после изменения
+// и false, если черновика нет или клавиатура устарела.
+func (w *predictionWizard) update(peerID, userID, messageID int, fn func(d *predictionDraft)) (predictionDraft, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.cleanup()
+	draft, ok := w.drafts[wizardKey{peerID, userID}]
+	if !ok || (messageID != 0 && draft.messageID != 0 && draft.messageID != messageID) {
+		return predictionDraft{}, false
+	}
+	fn(draft)
+	draft.updatedAt = time.Now()
+	result := *draft
+	result.picks = append([]uint8(nil), draft.picks...)
+	return result, true
+}
+
+func (w *predictionWizard) finish(peerID, userID int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.drafts, wizardKey{peerID, userID})
+}
+
+// cleanup удаляет брошенные черновики. Вызывается под w.mu.
+func (w *predictionWizard) cleanup() {
+	for key, draft := range w.drafts {
+		if time.Since(draft.updatedAt) > wizardDraftTTL {
+			delete(w.drafts, key)
+		}
+	}
+}
+
+// wizardKeyboard строит inline-клавиатуру выбора гонщика на позицию len(picks)+1.
+// Уже выбранные гонщики на клавиатуре не показываются.
+func wizardKeyboard(owner int, grid []service.GridDriver, picks []uint8, page int) Kb {
+	available := make([]service.GridDriver, 0, len(grid))
+	for _, g := range grid {
+		if !containsNumber(picks, g.Number) {
+			available = append(available, g)
+		}
+	}
+
+	pages := (len(available) + wizardPageSize - 1) / wizardPageSize
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+
+	buttons := [][]Button{}
+	var row []Button
+	end := min((page+1)*wizardPageSize, len(available))
+	for i := page * wizardPageSize; i < end; i++ {
+		g := available[i]
+		row = append(row, Button{Action: ActionBtn{
+			TypeAction: "callback",
+			Label:      fmt.Sprintf("%d %s", g.Number, g.Label()),
+			Payload:    fmt.Sprintf(wizardPayloadBase, fmt.Sprintf("predPick_%d_%d", owner, g.Number)),
+		}})
+		if len(row) == wizardCols {
+			buttons = append(buttons, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		buttons = append(buttons, row)
+	}
+
+	var nav []Button
+	if page > 0 {
+		nav = append(nav, wizardButton("◀", fmt.Sprintf("predPage_%d_%d", owner, page-1), "primary"))
+	}
+	if len(picks) > 0 {
+		nav = append(nav, wizardButton("Назад", fmt.Sprintf("predUndo_%d", owner), "secondary"))
+	}
+	nav = append(nav, wizardButton("Отмена", fmt.Sprintf("predCancel_%d", owner), "negative"))
+	if page < pages-1 {
+		nav = append(nav, wizardButton("▶", fmt.Sprintf("predPage_%d_%d", owner, page+1), "primary"))
+	}
+	buttons = append(buttons, nav)
+
+	return Kb{Inline: true, Buttons: buttons}
+}
+
+// wizardEditKeyboard — кнопка "Изменить" под принятым прогнозом
+func wizardEditKeyboard(owner, roundID int) Kb {
+	return Kb{Inline: true, Buttons: [][]Button{{wizardButton("Изменить", fmt.Sprintf("predEdit_%d_%d", owner, roundID), "primary")}}}
+}
+
+func wizardButton(label, cmd, color string) Button {
+	return Button{Action: ActionBtn{TypeAction: "callback", Label: label, Payload: fmt.Sprintf(wizardPayloadBase, cmd)}, Color: color}
+}
+
+// wizardStepMessage — текст шага: уже выбранные гонщики и позиция для выбора
+func wizardStepMessage(raceName string, grid []service.GridDriver, picks []uint8) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Прогноз на %s\n", raceName))
+	for i, num := range picks {
+		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, service.GridDriverName(grid, num)))
+	}
+	sb.WriteString(fmt.Sprintf("\nВыберите гонщика на %d место:", len(picks)+1))
+	return sb.String()
+}
+
+func containsNumber(numbers []uint8, number uint8) bool {
+	for _, n := range numbers {
+		if n == number {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWizardPayload разбирает "predPick_<владелец>_<номер>" на владельца и необязательное число
+func parseWizardPayload(payload string) (owner int, value int, err error) {
+	parts := strings.Split(payload, "_")
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("invalid wizard payload %q", payload)
+	}
+	owner, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid wizard owner in %q: %w", payload, err)
+	}
+	if len(parts) > 2 {
+		value, err = strconv.Atoi(parts[2])
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid wizard value in %q: %w", payload, err)
+		}
+	}
+	return owner, value, nil
+}
+
+// ---------- Обработчики ----------
+
+// startPredictionWizard отправляет сообщение с клавиатурой выбора победителя
+func startPredictionWizard(ctx handlerContext, race *models.PredictionRace) error {
+	grid, err := ctx.vk.predictionService.GetRaceGrid(race.RaceID)
+	if err != nil || len(grid) == 0 {
+		ctx.log.Error("failed to get race grid", slog.String("race_id", race.RaceID), slog.Any("error", err))
+		msg := "Не удалось загрузить список гонщиков. Отправьте прогноз текстом:\n\n/мойпрогноз №_топ1 №_топ2 №_топ3"
+		ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "predictionWizard")
+		return err
+	}
+
+	peerID, userID := ctx.obj.Message.PeerID, ctx.obj.Message.FromID
+	ctx.vk.wizard.start(peerID, userID, race)
+
+	strKb, err := marshalKeyboard(wizardKeyboard(userID, grid, nil, 0))
+	if err != nil {
+		ctx.log.Error("failed to marshal keyboard", slog.Any("error", err))
+		return err
+	}
+
+	resp, err := ctx.vk.sendAndLog(ctx.log, wizardStepMessage(race.RaceName, grid, nil), peerID, strKb, nil, nil, "predictionWizard")
+	if err != nil {
+		ctx.vk.wizard.finish(peerID, userID)
+		return err
+	}
+	if len(resp) > 0 {
+		ctx.vk.wizard.update(peerID, userID, 0, func(d *predictionDraft) { d.messageID = resp[0].ConversationMessageID })
+	}
+	return nil
+}
+
+// handleWizardEvent обрабатывает нажатия кнопок мастера прогноза
+func handleWizardEvent(ctx eventHandlerContext) error {
+	owner, value, err := parseWizardPayload(ctx.payload)
+	if err != nil {
+		ctx.log.Error("failed to parse wizard payload", slog.Any("error", err))
+		return err
+	}
+	peerID, userID := ctx.obj.PeerID, ctx.obj.UserID
+	if owner != userID {
+		ctx.vk.answerEvent(ctx, "Это чужой прогноз. Напишите «мойпрогноз», чтобы сделать свой.")
+		return nil
+	}
+
+	if strings.HasPrefix(ctx.payload, "predEdit_") {
+		// value — раунд, на который был отправлен прогноз
+		round, err := ctx.vk.predictionService.GetRound(value)
+		if err != nil {
+			ctx.log.Error("failed to get prediction round", slog.Int("round_id", value), slog.Any("error", err))
+			ctx.vk.answerEvent(ctx, "Не удалось получить раунд прогнозов, повторите попытку позже.")
+			return err
+		}
+		if round == nil || !round.IsActive {
+			ctx.vk.answerEvent(ctx, "Приём прогнозов закрыт.")
+			ctx.vk.editWizardMessage(ctx, "Приём прогнозов на эту гонку уже закрыт.", nil)
+			return nil
+		}
+		ctx.vk.wizard.start(peerID, userID, round)
+	}
+
+	cmID := ctx.obj.ConversationMessageID
+	var draft predictionDraft
+	var ok bool
+	switch {
+	case strings.HasPrefix(ctx.payload, "predCancel_"):
+		ctx.vk.wizard.finish(peerID, userID)
+		ctx.vk.answerEvent(ctx, "")
+		ctx.vk.editWizardMessage(ctx, "Ввод прогноза отменён.", nil)
+		return nil
+	case strings.HasPrefix(ctx.payload, "predPick_"):
+		draft, ok = ctx.vk.wizard.update(peerID, userID, cmID, func(d *predictionDraft) {
+			if len(d.picks) < 3 && !containsNumber(d.picks, uint8(value)) {
+				d.picks = append(d.picks, uint8(value))
+				d.page = 0
+			}
+		})
+	case strings.HasPrefix(ctx.payload, "predUndo_"):
+		draft, ok = ctx.vk.wizard.update(peerID, userID, cmID, func(d *predictionDraft) {
+			if len(d.picks) > 0 {
+				d.picks = d.picks[:len(d.picks)-1]
+			}
+		})
+	case strings.HasPrefix(ctx.payload, "predPage_"):
+		draft, ok = ctx.vk.wizard.update(peerID, userID, cmID, func(d *predictionDraft) { d.page = value })
+	default:
+		draft, ok = ctx.vk.wizard.update(peerID, userID, cmID, func(d *predictionDraft) { d.messageID = ctx.obj.ConversationMessageID })
+	}
+
+	if !ok {
+		ctx.vk.answerEvent(ctx, "Эта клавиатура устарела. Напишите «мойпрогноз», чтобы начать заново.")
+		return nil
+	}
+
+	round, err := ctx.vk.predictionService.GetRound(draft.roundID)
+	if err != nil {
+		ctx.log.Error("failed to get prediction round", slog.Int("round_id", draft.roundID), slog.Any("error", err))
+		ctx.vk.answerEvent(ctx, "Не удалось получить раунд прогнозов, повторите попытку позже.")
+		return err
+	}
+	if round == nil || !round.IsActive {
+		ctx.vk.wizard.finish(peerID, userID)
+		ctx.vk.answerEvent(ctx, "Приём прогнозов закрыт.")
+		ctx.vk.editWizardMessage(ctx, "Приём прогнозов на эту гонку уже закрыт.", nil)
+		return nil
+	}
+
+	grid, err := ctx.vk.predictionService.GetRaceGrid(draft.raceID)
+	if err != nil {
+		ctx.log.Error("failed to get race grid", slog.String("race_id", draft.raceID), slog.Any("error", err))
+		ctx.vk.answerEvent(ctx, "Не удалось загрузить список гонщиков, повторите попытку позже.")
+		return err
+	}
+
+	if len(draft.picks) < 3 {
+		ctx.vk.answerEvent(ctx, "")
+		kb := wizardKeyboard(userID, grid, draft.picks, draft.page)
+		ctx.vk.editWizardMessage(ctx, wizardStepMessage(draft.raceName, grid, draft.picks), &kb)
+		return nil
+	}
+
+	// Выбраны все три позиции — отправляем прогноз
+	ctx.vk.wizard.finish(peerID, userID)
+	d1, d2, d3 := draft.picks[0], draft.picks[1], draft.picks[2]
+	err = ctx.vk.predictionService.SubmitPrediction(models.PlatformVK, userID, int64(peerID), draft.roundID, d1, d2, d3)
+	if err != nil {
+		ctx.log.Error("failed to save prediction", slog.Any("error", err))
+		ctx.vk.answerEvent(ctx, "")
+		kb := wizardEditKeyboard(userID, draft.roundID)
+		ctx.vk.editWizardMessage(ctx, predictionErrorMessage(err, ctx.vk.timezones.Location(models.PlatformVK, userID, int64(peerID))), &kb)
+		return nil
+	}
+
+	if err := ctx.vk.predictionService.EnsureUserProfile(models.PlatformVK, userID); err != nil {
+		ctx.log.Warn("failed to update user profile", slog.Int("user_id", userID), slog.Any("error", err))
+	}
+	ctx.log.Info("Prediction recorded", slog.Int("user_id", userID), slog.Int("d1", int(d1)), slog.Int("d2", int(d2)), slog.Int("d3", int(d3)), slog.String("source", "keyboard"))
+
+	ctx.vk.answerEvent(ctx, "Прогноз принят!")
+	msg := fmt.Sprintf("Ваш прогноз на %s принят:\n1. %s\n2. %s\n3. %s", draft.raceName,
+		service.GridDriverName(grid, d1), service.GridDriverName(grid, d2), service.GridDriverName(grid, d3))
+	kb := wizardEditKeyboard(userID, draft.roundID)
+	ctx.vk.editWizardMessage(ctx, msg, &kb)
+	return nil
+}
+
+// answerEvent отвечает на нажатие callback-кнопки; непустой текст показывается всплывающим уведомлением
+func (vk *VkAPI) answerEvent(ctx eventHandlerContext, text string) {
+	var err error
+	if text == "" {
+		_, err = sendEventMessageToUser(vk.lp.VK, ctx.obj.PeerID, ctx.obj.EventID, ctx.obj.UserID)
+	} else {
+		_, err = sendEventSnackbar(vk.lp.VK, ctx.obj.PeerID, ctx.obj.EventID, ctx.obj.UserID, text)
+	}
+	if err != nil {
+		ctx.log.Error("failed to send event answer", slog.Int("peer_id", ctx.obj.PeerID), slog.Any("error", err))
+	}
+}
+
+// editWizardMessage заменяет текст и клавиатуру сообщения, на котором нажата кнопка
+func (vk *VkAPI) editWizardMessage(ctx eventHandlerContext, message string, kb *Kb) {
+	// Пустая inline-клавиатура убирает кнопки с сообщения
+	if kb == nil {
+		kb = &Kb{Inline: true, Buttons: [][]Button{}}
+	}
+	strKb, err := marshalKeyboard(*kb)
+	if err != nil {
+		ctx.log.Error("failed to marshal keyboard", slog.Any("error", err))
+		return
+	}
+	if err := editMessage(vk.lp.VK, ctx.obj.PeerID, ctx.obj.ConversationMessageID, message, strKb); err != nil {
+		ctx.log.Error("failed to edit message", slog.Int("peer_id", ctx.obj.PeerID), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,78 @@
+package vk
+
+import (
+	"errors"
+	"log/slog"
+	"racebot-vk/service"
+	"racebot-vk/temperrors"
+	"strings"
+)
+
+const driverProfileUsage = `Профиль гонщика: гонщик номер|код|фамилия
+гонщик 1, гонщик VER, гонщик ферстаппен
+Гонщиков прошлых лет можно найти по фамилии латиницей: гонщик senna, гонщик michael schumacher`
+
+const constructorProfileUsage = `Профиль команды: команда название
+команда феррари, команда red bull, команда mclaren
+Команды прошлых лет ищутся по названию латиницей: команда tyrrell, команда brabham`
+
+const headToHeadUsage = `Сравнение гонщиков: сравнить гонщик гонщик [год]
+сравнить ферстаппен норрис — текущий сезон
+сравнить HAM ROS 2016
+Гонщики указываются номером, кодом или фамилией.`
+
+// handleDriverProfile — профиль и карьерная статистика гонщика: "гонщик ферстаппен"
+func handleDriverProfile(ctx handlerContext) error {
+	query := ctx.args
+	if query == "" {
+		_, err := ctx.vk.sendAndLog(ctx.log, driverProfileUsage, ctx.obj.Message.PeerID, nil, nil, nil, "driverProfile")
+		return err
+	}
+
+	msg, err := ctx.vk.messageService.GetDriverProfileMessage(query, ctx.userDate)
+	if err != nil {
+		ctx.log.Error("failed to get driver profile", slog.String("query", query), slog.Any("error", err))
+		return err
+	}
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "driverProfile")
+	return err
+}
+
+// handleConstructorProfile — профиль команды и история кубков конструкторов: "команда феррари"
+func handleConstructorProfile(ctx handlerContext) error {
+	query := ctx.args
+	if query == "" {
+		_, err := ctx.vk.sendAndLog(ctx.log, constructorProfileUsage, ctx.obj.Message.PeerID, nil, nil, nil, "constructorProfile")
+		return err
+	}
+
+	msg, err := ctx.vk.messageService.GetConstructorProfileMessage(query, ctx.userDate)
+	if err != nil {
+		ctx.log.Error("failed to get constructor profile", slog.String("query", query), slog.Any("error", err))
+		return err
+	}
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "constructorProfile")
+	return err
+}
+
+// handleHeadToHead — сравнение двух гонщиков за сезон: "сравнить ферстаппен норрис [год]"
+func handleHeadToHead(ctx handlerContext) error {
+	args := strings.Fields(ctx.args)
+	first, second, season, err := service.ParseHeadToHeadArgs(args, ctx.userDate)
+	if err != nil {
+		msg := headToHeadUsage
+		if errors.Is(err, temperrors.ErrSeasonOutOfRange) {
+			msg = service.SeasonRangeMessage(ctx.userDate) + "\n\n" + headToHeadUsage
+		}
+		_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "headToHead")
+		return err
+	}
+
+	msg, err := ctx.vk.messageService.GetHeadToHeadMessage(first, second, season)
+	if err != nil {
+		ctx.log.Error("failed to get head-to-head", slog.String("first", first), slog.String("second", second), slog.Any("error", err))
+		return err
+	}
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "headToHead")
+	return err
+}
@@ -0,0 +1,181 @@
+package vk
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	"racebot-vk/service"
+	"racebot-vk/temperrors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/SevereCloud/vksdk/v3/api/params"
+)
+
+// peer_id бесед начинается с 2000000000, меньшие peer_id — личные диалоги
+const chatPeerIDOffset = 2000000000
+
+// Упоминание пользователя: "[id123|Имя]", "@id123", "id123" или просто "123"
+var mentionRe = regexp.MustCompile(`\A(?:\[id(\d+)\|[^\]]*\]|@?id(\d+)|(\d+))\z`)
+
+const rolesUsage = `Назначение ролей:
+назначитьроль @пользователь модератор|админ [везде]
+снятьроль @пользователь [везде]
+
+Пользователя можно указать упоминанием, id или ответом на его сообщение.
+Без «везде» роль действует только в этой беседе.`
+
+// GetChatAdmins возвращает администраторов и создателя беседы (для service.RoleService).
+// Работает, только если сообщество — администратор беседы.
+func (vk *VkAPI) GetChatAdmins(chatID int64) ([]int, error) {
+	if chatID < chatPeerIDOffset {
+		return nil, nil
+	}
+
+	b := params.NewMessagesGetConversationMembersBuilder()
+	b.PeerID(int(chatID))
+	resp, err := vk.lp.VK.MessagesGetConversationMembers(b.Params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation members: %w", err)
+	}
+
+	var admins []int
+	for _, member := range resp.Items {
+		if member.MemberID > 0 && (bool(member.IsAdmin) || bool(member.IsOwner)) {
+			admins = append(admins, member.MemberID)
+		}
+	}
+	return admins, nil
+}
+
+// hasRole проверяет роль автора сообщения в текущем чате
+func hasRole(ctx handlerContext, role models.Role) bool {
+	return ctx.vk.roles.HasRole(models.PlatformVK, ctx.obj.Message.FromID, int64(ctx.obj.Message.PeerID), role)
+}
+
+// hasRoundRole проверяет роль автора сообщения для управления раундом:
+// общим раундом управляют роли бота, раундом чата — ещё и роли этого чата
+func hasRoundRole(ctx handlerContext, race *models.PredictionRace, role models.Role) bool {
+	return ctx.vk.roles.HasRole(models.PlatformVK, ctx.obj.Message.FromID, race.ChatID, role)
+}
+
+// denyRoundAccess сообщает, что раундом управляют только роли бота
+func denyRoundAccess(ctx handlerContext, commandLabel string) {
+	ctx.vk.sendAndLog(ctx.log, "Недостаточно прав: общим конкурсом прогнозов управляют администраторы бота.", ctx.obj.Message.PeerID, nil, nil, nil, commandLabel)
+}
+
+// roleScope возвращает чат, в котором выдаётся роль: "везде" или личный диалог — все чаты
+func roleScope(ctx handlerContext, args []string) (int64, []string) {
+	if n := len(args); n > 0 && args[n-1] == "везде" {
+		return 0, args[:n-1]
+	}
+	if ctx.obj.Message.PeerID < chatPeerIDOffset {
+		return 0, args
+	}
+	return int64(ctx.obj.Message.PeerID), args
+}
+
+// roleTarget определяет пользователя команды: упоминание первым аргументом или автор
+// сообщения, на которое ответили. Возвращает 0, если пользователь не указан.
+func roleTarget(ctx handlerContext, args []string) (int, []string) {
+	if len(args) > 0 {
+		if m := mentionRe.FindStringSubmatch(args[0]); m != nil {
+			for _, group := range m[1:] {
+				if id, err := strconv.Atoi(group); err == nil {
+					return id, args[1:]
+				}
+			}
+		}
+	}
+	if reply := ctx.obj.Message.ReplyMessage; reply != nil && reply.FromID > 0 {
+		return reply.FromID, args
+	}
+	return 0, args
+}
+
+// roleErrorMessage переводит ошибку назначения роли в сообщение для пользователя
+func roleErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, temperrors.ErrPermissionDenied):
+		return "Недостаточно прав: можно назначать и снимать только роли младше своей."
+	case errors.Is(err, temperrors.ErrRoleNotFound):
+		return "У пользователя нет назначенной роли в этой области."
+	case errors.Is(err, temperrors.ErrUnknownRole):
+		return rolesUsage
+	}
+	return "Не удалось изменить роль. Повторите попытку позже."
+}
+
+// handleRoles — показывает роли бота и роль пользователя в чате
+func handleRoles(ctx handlerContext) error {
+	msg, err := ctx.vk.roles.GetRolesMessage(models.PlatformVK, ctx.obj.Message.FromID, int64(ctx.obj.Message.PeerID))
+	if err != nil {
+		ctx.log.Error("failed to get roles", slog.Any("error", err))
+		return err
+	}
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "roles")
+	return err
+}
+
+// handleGrantRole — назначает роль: "назначитьроль @пользователь модератор [везде]"
+func handleGrantRole(ctx handlerContext) error {
+	if !hasRole(ctx, models.RoleAdmin) {
+		return nil
+	}
+
+	chatID, args := roleScope(ctx, strings.Fields(ctx.args))
+	userID, args := roleTarget(ctx, args)
+	if userID == 0 || len(args) != 1 {
+		_, err := ctx.vk.sendAndLog(ctx.log, rolesUsage, ctx.obj.Message.PeerID, nil, nil, nil, "grantRole")
+		return err
+	}
+
+	role, err := service.ParseRole(args[0])
+	if err == nil {
+		err = ctx.vk.roles.GrantRole(models.PlatformVK, ctx.obj.Message.FromID, userID, chatID, role)
+	}
+	if err != nil {
+		ctx.log.Warn("failed to grant role", slog.Int("user_id", userID), slog.Any("error", err))
+		_, err = ctx.vk.sendAndLog(ctx.log, roleErrorMessage(err), ctx.obj.Message.PeerID, nil, nil, nil, "grantRole")
+		return err
+	}
+
+	if err := ctx.vk.predictionService.EnsureUserProfile(models.PlatformVK, userID); err != nil {
+		ctx.log.Warn("failed to update user profile", slog.Int("user_id", userID), slog.Any("error", err))
+	}
+	ctx.log.Info("Role granted", slog.Int("user_id", userID), slog.String("role", string(role)), slog.Int64("chat_id", chatID), slog.Int("granted_by", ctx.obj.Message.FromID))
+
+	scope := "в этой беседе"
+	if chatID == 0 {
+		scope = "во всех чатах"
+	}
+	_, err = ctx.vk.sendAndLog(ctx.log, fmt.Sprintf("Пользователь назначен: %s %s.", role.Title(), scope), ctx.obj.Message.PeerID, nil, nil, nil, "grantRole")
+	return err
+}
+
+// handleRevokeRole — снимает роль: "снятьроль @пользователь [везде]"
+func handleRevokeRole(ctx handlerContext) error {
+	if !hasRole(ctx, models.RoleAdmin) {
+		return nil
+	}
+
+	chatID, args := roleScope(ctx, strings.Fields(ctx.args))
+	userID, args := roleTarget(ctx, args)
+	if userID == 0 || len(args) != 0 {
+		_, err := ctx.vk.sendAndLog(ctx.log, rolesUsage, ctx.obj.Message.PeerID, nil, nil, nil, "revokeRole")
+		return err
+	}
+
+	role, err := ctx.vk.roles.RevokeRole(models.PlatformVK, ctx.obj.Message.FromID, userID, chatID)
+	if err != nil {
+		ctx.log.Warn("failed to revoke role", slog.Int("user_id", userID), slog.Any("error", err))
+		_, err = ctx.vk.sendAndLog(ctx.log, roleErrorMessage(err), ctx.obj.Message.PeerID, nil, nil, nil, "revokeRole")
+		return err
+	}
+	ctx.log.Info("Role revoked", slog.Int("user_id", userID), slog.String("role", string(role)), slog.Int64("chat_id", chatID), slog.Int("revoked_by", ctx.obj.Message.FromID))
+
+	_, err = ctx.vk.sendAndLog(ctx.log, fmt.Sprintf("Роль «%s» снята.", role.Title()), ctx.obj.Message.PeerID, nil, nil, nil, "revokeRole")
+	return err
+}
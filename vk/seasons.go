@@ -0,0 +1,42 @@
+package vk
+
+import (
+	"errors"
+	"racebot-vk/service"
+	"racebot-vk/temperrors"
+	"strings"
+)
+
+const seasonUsage = `Сезон и этап указываются числами после команды:
+личный зачёт 2008, личный зачёт 2008 5 — после 5-го этапа
+кубок конструкторов 2010
+динамика зачёта 2021
+календарь сезона 1988
+результат гонки 2021 22 — сезон и номер этапа
+результат квалы 2021, результат спринта 2023 6`
+
+// seasonArgs возвращает сезон и этап из слов после команды: без аргументов — текущий сезон
+// и последний этап, этап из кнопки сохраняется. withRound = false — команда принимает только сезон.
+// При ошибке отвечает подсказкой и возвращает ok = false.
+func seasonArgs(ctx handlerContext, withRound bool, commandLabel string) (season int, round string, ok bool) {
+	args := strings.Fields(ctx.args)
+	if !withRound && len(args) > 1 {
+		ctx.vk.sendAndLog(ctx.log, seasonUsage, ctx.obj.Message.PeerID, nil, nil, nil, commandLabel)
+		return 0, "", false
+	}
+
+	season, round, err := service.ParseSeasonArgs(args, ctx.userDate)
+	if err != nil {
+		msg := seasonUsage
+		if errors.Is(err, temperrors.ErrSeasonOutOfRange) {
+			msg = service.SeasonRangeMessage(ctx.userDate) + "\n\n" + seasonUsage
+		}
+		ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, commandLabel)
+		return 0, "", false
+	}
+
+	if len(args) < 2 {
+		round = ctx.raceID
+	}
+	return season, round, true
+}
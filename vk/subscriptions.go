@@ -0,0 +1,76 @@
+package vk
+
+import (
+	"errors"
+	"log/slog"
+	"racebot-vk/models"
+	"racebot-vk/service"
+	"racebot-vk/temperrors"
+	"strings"
+)
+
+const subscriptionUsage = `Темы: гонка, квала, спринт, практики, результаты, прогнозы, все.
+
+подписка гонка квала — добавить темы
+подписка только гонка — оставить только эти темы
+отписка спринт — убрать темы
+отписка — отписаться от всего
+
+В беседе подписку меняют администраторы беседы.`
+
+// subscriptionErrorMessage переводит ошибку изменения подписки в сообщение для пользователя
+func subscriptionErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, temperrors.ErrPermissionDenied):
+		return "Подписку беседы меняют администраторы беседы. Подписаться лично можно в сообщениях сообщества."
+	case errors.Is(err, temperrors.ErrUnknownTopic):
+		return subscriptionUsage
+	}
+	return "Не удалось изменить подписку. Повторите попытку позже."
+}
+
+// handleSubscribe — показывает подписку чата или добавляет темы: "подписка [только] гонка квала"
+func handleSubscribe(ctx handlerContext) error {
+	chatID := int64(ctx.obj.Message.PeerID)
+	args := strings.Fields(ctx.args)
+
+	if len(args) == 0 {
+		sub, err := ctx.vk.subscriptions.Get(models.PlatformVK, chatID)
+		if err != nil {
+			ctx.log.Error("failed to get subscription", slog.Any("error", err))
+			return err
+		}
+		_, err = ctx.vk.sendAndLog(ctx.log, service.FormatSubscription(sub)+"\n\n"+subscriptionUsage, ctx.obj.Message.PeerID, nil, nil, nil, "subscribe")
+		return err
+	}
+
+	topics, only, err := service.ParseTopics(args)
+	var sub *models.Subscription
+	if err == nil {
+		sub, err = ctx.vk.subscriptions.Subscribe(models.PlatformVK, ctx.obj.Message.FromID, chatID, topics, only)
+	}
+	return replySubscription(ctx, sub, err, "subscribe")
+}
+
+// handleUnsubscribe — убирает темы из подписки чата: "отписка [темы]", без тем — от всего
+func handleUnsubscribe(ctx handlerContext) error {
+	topics, _, err := service.ParseTopics(strings.Fields(ctx.args))
+	var sub *models.Subscription
+	if err == nil {
+		sub, err = ctx.vk.subscriptions.Unsubscribe(models.PlatformVK, ctx.obj.Message.FromID, int64(ctx.obj.Message.PeerID), topics)
+	}
+	return replySubscription(ctx, sub, err, "unsubscribe")
+}
+
+// replySubscription отвечает новой подпиской чата или ошибкой её изменения
+func replySubscription(ctx handlerContext, sub *models.Subscription, err error, commandLabel string) error {
+	if err != nil {
+		ctx.log.Warn("failed to update subscription", slog.Any("error", err))
+		_, err = ctx.vk.sendAndLog(ctx.log, subscriptionErrorMessage(err), ctx.obj.Message.PeerID, nil, nil, nil, commandLabel)
+		return err
+	}
+
+	ctx.log.Info("Subscription updated", slog.Int("peer_id", ctx.obj.Message.PeerID), slog.Any("topics", sub.Topics), slog.Int("user_id", ctx.obj.Message.FromID))
+	_, err = ctx.vk.sendAndLog(ctx.log, service.FormatSubscription(sub), ctx.obj.Message.PeerID, nil, nil, nil, commandLabel)
+	return err
+}
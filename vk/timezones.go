@@ -0,0 +1,78 @@
+package vk
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"racebot-vk/models"
+	"racebot-vk/service"
+	"racebot-vk/temperrors"
+	"strings"
+	"time"
+)
+
+const timezoneUsage = `Часовой пояс расписания:
+пояс екатеринбург — задать свой пояс (город, Asia/Yekaterinburg, UTC+5 или МСК+2)
+пояс беседа владивосток — задать пояс беседы (администраторы беседы)
+пояс сброс / пояс беседа сброс — вернуть пояс по умолчанию
+
+Без личного пояса время показывается в поясе беседы, а без него — по Москве.`
+
+// userLocation возвращает часовой пояс автора сообщения в текущем чате
+func userLocation(ctx handlerContext) *time.Location {
+	return ctx.vk.timezones.Location(models.PlatformVK, ctx.obj.Message.FromID, int64(ctx.obj.Message.PeerID))
+}
+
+// timezoneErrorMessage переводит ошибку смены часового пояса в сообщение для пользователя
+func timezoneErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, temperrors.ErrPermissionDenied):
+		return "Часовой пояс беседы меняют администраторы беседы. Свой пояс можно задать командой «пояс город»."
+	case errors.Is(err, temperrors.ErrUnknownTimezone):
+		return "Не знаю такого часового пояса.\n\n" + timezoneUsage
+	}
+	return "Не удалось изменить часовой пояс. Повторите попытку позже."
+}
+
+// handleTimezone — показывает или меняет часовой пояс: "пояс [беседа] город|сброс"
+func handleTimezone(ctx handlerContext) error {
+	userID, chatID := ctx.obj.Message.FromID, int64(ctx.obj.Message.PeerID)
+	args := ctx.args
+
+	if args == "" {
+		msg := ctx.vk.timezones.GetTimezoneMessage(models.PlatformVK, userID, chatID) + "\n\n" + timezoneUsage
+		_, err := ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "timezone")
+		return err
+	}
+
+	forChat := false
+	if rest, ok := strings.CutPrefix(args, "беседа"); ok {
+		forChat, args = true, strings.TrimSpace(rest)
+	}
+
+	var loc *time.Location
+	var err error
+	switch {
+	case args == "сброс" && forChat:
+		err = ctx.vk.timezones.ResetChatTimezone(models.PlatformVK, userID, chatID)
+	case args == "сброс":
+		err = ctx.vk.timezones.ResetUserTimezone(models.PlatformVK, userID)
+	case forChat:
+		loc, err = ctx.vk.timezones.SetChatTimezone(models.PlatformVK, userID, chatID, args)
+	default:
+		loc, err = ctx.vk.timezones.SetUserTimezone(models.PlatformVK, userID, args)
+	}
+	if err != nil {
+		ctx.log.Warn("failed to change timezone", slog.String("timezone", args), slog.Any("error", err))
+		_, err = ctx.vk.sendAndLog(ctx.log, timezoneErrorMessage(err), ctx.obj.Message.PeerID, nil, nil, nil, "timezone")
+		return err
+	}
+
+	msg := "Часовой пояс сброшен. " + ctx.vk.timezones.GetTimezoneMessage(models.PlatformVK, userID, chatID)
+	if loc != nil {
+		ctx.log.Info("Timezone changed", slog.String("timezone", loc.String()), slog.Bool("chat", forChat), slog.Int("user_id", userID))
+		msg = fmt.Sprintf("Часовой пояс установлен: %s.", service.FormatTimezone(loc))
+	}
+	_, err = ctx.vk.sendAndLog(ctx.log, msg, ctx.obj.Message.PeerID, nil, nil, nil, "timezone")
+	return err
+}